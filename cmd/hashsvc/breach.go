@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/plar/hash/infra/breach"
+	"github.com/plar/hash/server/config"
+)
+
+// buildBreachFilter converts the HIBP raw dump into the compact breach filter
+func buildBreachFilter(args []string) {
+	flags := flag.NewFlagSet("build-breach-filter", flag.ExitOnError)
+	input := flags.String("input", "", "the HIBP raw dump in the <SHA-1 hex>:<count> format, one hash per line")
+	output := flags.String("output", "breach.filter", "the breach filter file")
+	fp := flags.Float64("fp", 0.001, "the false positive rate of the filter")
+	flags.Parse(args)
+
+	if *input == "" {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	dump, err := os.Open(*input)
+	if err != nil {
+		log.Fatalf("Cannot open the dump: %v\n", err)
+	}
+	defer dump.Close()
+
+	// the first pass sizes the filter
+	n, err := breach.CountLines(dump)
+	if err != nil {
+		log.Fatalf("Cannot read the dump: %v\n", err)
+	}
+	if _, err := dump.Seek(0, io.SeekStart); err != nil {
+		log.Fatalf("Cannot read the dump: %v\n", err)
+	}
+
+	fmt.Printf("Building the breach filter, hashes=%v, false positive rate=%v...\n", n, *fp)
+	filter, added, err := breach.BuildBloomFilter(dump, n, *fp)
+	if err != nil {
+		log.Fatalf("Cannot build the breach filter: %v\n", err)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		log.Fatalf("Cannot create the breach filter: %v\n", err)
+	}
+	size, err := filter.WriteTo(file)
+	if err == nil {
+		err = file.Close()
+	}
+	if err != nil {
+		log.Fatalf("Cannot write the breach filter: %v\n", err)
+	}
+
+	fmt.Printf("The breach filter %v has been built, hashes=%v, hash functions=%v, size=%vKiB\n", *output, added, filter.Hashes(), size/1024)
+}
+
+// loadBreachCorpus loads the breach corpus from HASH_BREACH_DIR or HASH_BREACH_FILTER,
+// nil if the breach check is disabled
+func loadBreachCorpus(cfg config.Config) (breach.Corpus, error) {
+	if dir := cfg.BreachDir(); dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read HASH_BREACH_DIR '%v': %w", dir, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("Invalid HASH_BREACH_DIR '%v': not a directory", dir)
+		}
+		return breach.NewPrefixCorpus(dir), nil
+	}
+	if filter := cfg.BreachFilter(); filter != "" {
+		f, err := breach.LoadBloomFilter(filter)
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read HASH_BREACH_FILTER '%v': %w", filter, err)
+		}
+		return f, nil
+	}
+	return nil, nil
+}
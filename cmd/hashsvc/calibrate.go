@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/plar/hash/server/config"
+	"github.com/plar/hash/service/hasher"
+)
+
+// calibrate prints the recommended cost parameters without starting the server
+func calibrate() {
+	cfg, err := config.New()
+	if err != nil {
+		log.Fatalf("Configuration error: %v\n", err)
+	}
+
+	fmt.Printf("Calibrating the cost parameters, target=%v, max memory=%vKiB...\n\n", cfg.CalibrationTarget(), cfg.CalibrationMaxMemory())
+
+	registry := hasher.NewRegistryFromConfig(cfg)
+	calibration := registry.Calibrate(cfg.CalibrationTarget(), cfg.CalibrationMaxMemory())
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ALGORITHM\tPARAMS\tDURATION\tMEMORY")
+	for _, c := range calibration {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%vKiB\n", c.Algorithm, c.Params, c.Duration, c.Memory)
+	}
+	w.Flush()
+}
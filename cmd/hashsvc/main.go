@@ -14,6 +14,9 @@ import (
 	"github.com/plar/hash/server/config"
 	"github.com/plar/hash/service/hasher"
 	"github.com/plar/hash/service/health"
+	"github.com/plar/hash/service/kdf"
+	"github.com/plar/hash/service/probe"
+	"github.com/plar/hash/service/srp"
 	"github.com/plar/hash/service/stats"
 )
 
@@ -23,9 +26,24 @@ var (
 	statsSvc  stats.Service
 	hashRepo  repository.HashRepository
 	hasherSvc hasher.Service
+	srpSvc    srp.Service
+	kdfSvc    kdf.Service
 )
 
 func main() {
+	// handle subcommands, the remaining arguments are parsed as usual
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "calibrate":
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+			calibrate()
+			return
+		case "build-breach-filter":
+			buildBreachFilter(os.Args[2:])
+			return
+		}
+	}
+
 	cfg, err := config.New()
 	if err != nil {
 		log.Fatalf("Configuration error: %v\n", err)
@@ -40,20 +58,45 @@ func main() {
 
 	hashRepo = memory.NewHashRepository()
 
-	hasherSvc = hasher.New(hashRepo, cfg)
+	breachCorpus, err := loadBreachCorpus(cfg)
+	if err != nil {
+		log.Fatalf("Configuration error: %v\n", err)
+	}
+
+	hasherSvc = hasher.NewWithBreachCorpus(hashRepo, cfg, statsSvc, breachCorpus)
 	hasherSvc = hasher.NewInstrumentingService(hasherSvc, statsSvc)
 	hasherSvc = hasher.NewLoggingService(hasherSvc)
 
+	srpSvc = srp.New(cfg, hashRepo)
+	srpSvc = srp.NewInstrumentingService(srpSvc, statsSvc)
+	srpSvc = srp.NewLoggingService(srpSvc)
+
+	kdfSvc = kdf.New(cfg)
+	kdfSvc = kdf.NewInstrumentingService(kdfSvc, statsSvc)
+	kdfSvc = kdf.NewLoggingService(kdfSvc)
+
 	healthSvc = health.NewService()
 
+	// the service stays unhealthy unless all algorithms reproduce their known answers
+	if report := hasherSvc.SelfTest(); !report.Passed() {
+		hasherSvc.Stop()
+		log.Fatalf("Self-test error: %v\n", report.Err())
+	}
+
 	// create server
-	server, done := server.New(cfg, hasherSvc, statsSvc, healthSvc)
+	server, done := server.New(cfg, hasherSvc, srpSvc, kdfSvc, statsSvc, healthSvc)
+
+	// probe the hasher service continuously
+	prober := probe.New(cfg, hasherSvc, statsSvc, healthSvc)
+	prober.Start()
+	defer prober.Stop()
 
 	// handle OS signals...
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		log.Printf("Signal received: %v\n", <-quit)
+		prober.Stop()
 		server.Shutdown()
 	}()
 
@@ -0,0 +1,93 @@
+package domain
+
+// Algorithm is the password hashing algorithm identifier
+type Algorithm string
+
+const (
+	AlgorithmSHA512   Algorithm = "sha512"
+	AlgorithmArgon2id Algorithm = "argon2id"
+	AlgorithmBcrypt   Algorithm = "bcrypt"
+	AlgorithmScrypt   Algorithm = "scrypt"
+
+	// AlgorithmSSHA512 is the LDAP salted SHA512, i.e. sha512(password || salt).
+	// It is accepted for imported hashes only, the hashes can be exported in the LDAP format.
+	AlgorithmSSHA512 Algorithm = "ssha512"
+
+	AlgorithmPBKDF2SHA256 Algorithm = "pbkdf2-sha256"
+	AlgorithmPBKDF2SHA512 Algorithm = "pbkdf2-sha512"
+
+	// SCRAM verifiers of RFC 5802 and RFC 7677, the hash is StoredKey || ServerKey
+	AlgorithmSCRAMSHA1   Algorithm = "scram-sha-1"
+	AlgorithmSCRAMSHA256 Algorithm = "scram-sha-256"
+
+	// Legacy algorithms are accepted for imported hashes only
+	AlgorithmSHA512Crypt Algorithm = "sha512-crypt"
+	AlgorithmMD5Crypt    Algorithm = "md5-crypt"
+	AlgorithmAPR1        Algorithm = "apr1"
+
+	// AlgorithmSRP6a is an SRP-6a verifier registered by a client, the hash is the verifier v = g^x
+	// and the parameters are the RFC 5054 group size, e.g. n=2048. It cannot be verified with a plaintext password.
+	AlgorithmSRP6a Algorithm = "srp-6a"
+
+	// AlgorithmArgon2idSHA512 is a stored salted SHA512 hash wrapped with Argon2id,
+	// i.e. argon2id(sha512(salt || password)). It is produced by the wrap migration only.
+	AlgorithmArgon2idSHA512 Algorithm = "argon2id-sha512"
+)
+
+var algorithms = []Algorithm{
+	AlgorithmSHA512,
+	AlgorithmArgon2id,
+	AlgorithmBcrypt,
+	AlgorithmScrypt,
+	AlgorithmPBKDF2SHA256,
+	AlgorithmPBKDF2SHA512,
+	AlgorithmSCRAMSHA1,
+	AlgorithmSCRAMSHA256,
+}
+
+var legacyAlgorithms = []Algorithm{
+	AlgorithmSHA512Crypt,
+	AlgorithmMD5Crypt,
+	AlgorithmAPR1,
+}
+
+// Algorithms returns all supported algorithms
+func Algorithms() []Algorithm {
+	return append([]Algorithm(nil), algorithms...)
+}
+
+// IsValid reports whether the algorithm is supported
+func (a Algorithm) IsValid() bool {
+	for _, algorithm := range algorithms {
+		if a == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+// IsLegacy reports whether the algorithm is supported for imported hashes only
+func (a Algorithm) IsLegacy() bool {
+	for _, algorithm := range legacyAlgorithms {
+		if a == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSCRAM reports whether hashes of the algorithm are SCRAM verifiers
+func (a Algorithm) IsSCRAM() bool {
+	return a == AlgorithmSCRAMSHA1 || a == AlgorithmSCRAMSHA256
+}
+
+// IsSRP reports whether hashes of the algorithm are SRP verifiers
+func (a Algorithm) IsSRP() bool {
+	return a == AlgorithmSRP6a
+}
+
+// IsModularCrypt reports whether hashes of the algorithm are stored in the modular crypt format,
+// i.e. the hash embeds the algorithm, parameters and salt.
+func (a Algorithm) IsModularCrypt() bool {
+	return a == AlgorithmBcrypt || a.IsLegacy()
+}
@@ -0,0 +1,50 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/plar/hash/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlgorithm(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(domain.AlgorithmSHA512.IsValid())
+	assert.True(domain.AlgorithmArgon2id.IsValid())
+	assert.True(domain.AlgorithmBcrypt.IsValid())
+	assert.True(domain.AlgorithmScrypt.IsValid())
+	assert.False(domain.AlgorithmSSHA512.IsValid())
+	assert.True(domain.AlgorithmPBKDF2SHA256.IsValid())
+	assert.True(domain.AlgorithmPBKDF2SHA512.IsValid())
+	assert.True(domain.AlgorithmSCRAMSHA1.IsValid())
+	assert.True(domain.AlgorithmSCRAMSHA256.IsValid())
+	assert.False(domain.Algorithm("").IsValid())
+	assert.False(domain.Algorithm("md5").IsValid())
+
+	algorithms := domain.Algorithms()
+	assert.Len(algorithms, 8)
+	for _, a := range algorithms {
+		assert.True(a.IsValid())
+	}
+
+	// legacy algorithms are accepted for imported hashes only
+	assert.False(domain.AlgorithmSHA512Crypt.IsValid())
+	assert.True(domain.AlgorithmSHA512Crypt.IsLegacy())
+	assert.True(domain.AlgorithmMD5Crypt.IsLegacy())
+	assert.True(domain.AlgorithmAPR1.IsLegacy())
+	assert.False(domain.AlgorithmSHA512.IsLegacy())
+
+	assert.True(domain.AlgorithmBcrypt.IsModularCrypt())
+	assert.True(domain.AlgorithmAPR1.IsModularCrypt())
+	assert.False(domain.AlgorithmArgon2id.IsModularCrypt())
+
+	assert.True(domain.AlgorithmSCRAMSHA1.IsSCRAM())
+	assert.True(domain.AlgorithmSCRAMSHA256.IsSCRAM())
+	assert.False(domain.AlgorithmPBKDF2SHA256.IsSCRAM())
+
+	// SRP verifiers are registered by clients only
+	assert.False(domain.AlgorithmSRP6a.IsValid())
+	assert.True(domain.AlgorithmSRP6a.IsSRP())
+	assert.False(domain.AlgorithmSCRAMSHA256.IsSRP())
+}
@@ -0,0 +1,121 @@
+package domain
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrFormatMismatch is returned when the hash cannot be represented in the requested output format
+var ErrFormatMismatch = errors.New("Hash does not match the format")
+
+// aspNetPRFs are the ASP.NET Identity KeyDerivationPrf values of the PBKDF2 algorithms
+var aspNetPRFs = map[Algorithm]uint32{
+	AlgorithmPBKDF2SHA256: 1,
+	AlgorithmPBKDF2SHA512: 2,
+}
+
+// aspNetMinLen is the minimum salt and subkey length in bytes accepted by ASP.NET Identity
+const aspNetMinLen = 16
+
+// djangoKeyLen is the PBKDF2-SHA256 key length of Django, the size of the SHA256 digest
+const djangoKeyLen = 32
+
+// LDAP returns the hash in the LDAP {SSHA512} format, i.e. {SSHA512}base64(sha512(password || salt) || salt)
+func (h Hash) LDAP() (string, error) {
+	if err := h.checkFormat("ldap", AlgorithmSSHA512); err != nil {
+		return "", err
+	}
+
+	value := append(append([]byte(nil), h.Hash...), h.Salt...)
+	return "{SSHA512}" + base64.StdEncoding.EncodeToString(value), nil
+}
+
+// Django returns the hash in the Django password format, i.e. pbkdf2_sha256$<iterations>$<salt>$<base64 hash>
+func (h Hash) Django() (string, error) {
+	if err := h.checkFormat("django", AlgorithmPBKDF2SHA256); err != nil {
+		return "", err
+	}
+
+	iterations, err := h.iterations()
+	if err != nil {
+		return "", fmt.Errorf("%w 'django': bad parameters '%v'", ErrFormatMismatch, h.Params)
+	}
+	if len(h.Hash) != djangoKeyLen {
+		return "", fmt.Errorf("%w 'django': the key length must be %v bytes", ErrFormatMismatch, djangoKeyLen)
+	}
+	// Django keeps the salt as text
+	if len(h.Salt) == 0 || strings.IndexFunc(string(h.Salt), func(r rune) bool { return r <= ' ' || r > '~' || r == '$' }) >= 0 {
+		return "", fmt.Errorf("%w 'django': the salt is not printable text", ErrFormatMismatch)
+	}
+
+	return fmt.Sprintf("pbkdf2_sha256$%v$%s$%v", iterations, h.Salt, base64.StdEncoding.EncodeToString(h.Hash)), nil
+}
+
+// ASPNetIdentity returns the hash in the ASP.NET Identity v3 format, i.e. base64 of
+//
+//	0x01 || prf || iterations || salt length || salt || subkey
+//
+// where the integers are 32-bit big-endian.
+func (h Hash) ASPNetIdentity() (string, error) {
+	if err := h.checkFormat("aspnet", AlgorithmPBKDF2SHA256, AlgorithmPBKDF2SHA512); err != nil {
+		return "", err
+	}
+
+	iterations, err := h.iterations()
+	if err != nil {
+		return "", fmt.Errorf("%w 'aspnet': bad parameters '%v'", ErrFormatMismatch, h.Params)
+	}
+	if len(h.Salt) < aspNetMinLen || len(h.Hash) < aspNetMinLen {
+		return "", fmt.Errorf("%w 'aspnet': the salt and key lengths must be at least %v bytes", ErrFormatMismatch, aspNetMinLen)
+	}
+
+	value := make([]byte, 13, 13+len(h.Salt)+len(h.Hash))
+	value[0] = 0x01
+	binary.BigEndian.PutUint32(value[1:], aspNetPRFs[h.Algorithm])
+	binary.BigEndian.PutUint32(value[5:], uint32(iterations))
+	binary.BigEndian.PutUint32(value[9:], uint32(len(h.Salt)))
+	value = append(append(value, h.Salt...), h.Hash...)
+	return base64.StdEncoding.EncodeToString(value), nil
+}
+
+// checkFormat ensures the hash is calculated by one of the algorithms and can be verified by a third-party system,
+// which knows nothing about the pepper, pipelines and normalization of the service
+func (h Hash) checkFormat(format string, algorithms ...Algorithm) error {
+	supported := false
+	for _, algorithm := range algorithms {
+		supported = supported || h.Algorithm == algorithm
+	}
+
+	switch {
+	case !supported:
+		return fmt.Errorf("%w '%v': the algorithm is '%v', expected '%v'", ErrFormatMismatch, format, h.Algorithm, joinAlgorithms(algorithms))
+	case h.Relief:
+		return fmt.Errorf("%w '%v': the hash is a server relief hash", ErrFormatMismatch, format)
+	case h.KeyID != "":
+		return fmt.Errorf("%w '%v': the hash is peppered", ErrFormatMismatch, format)
+	case h.Pipeline != "":
+		return fmt.Errorf("%w '%v': the hash is calculated by the pipeline '%v'", ErrFormatMismatch, format, h.Pipeline)
+	case h.Normalization != "":
+		return fmt.Errorf("%w '%v': the password is normalized with '%v'", ErrFormatMismatch, format, h.Normalization)
+	}
+	return nil
+}
+
+// iterations returns the number of iterations of the hash parameters in the i=<iterations> format
+func (h Hash) iterations() (int, error) {
+	if !strings.HasPrefix(h.Params, "i=") {
+		return 0, fmt.Errorf("%w: '%v'", ErrInvalidPHC, h.Params)
+	}
+	return parsePHCDecimal(strings.TrimPrefix(h.Params, "i="))
+}
+
+func joinAlgorithms(algorithms []Algorithm) string {
+	names := make([]string, len(algorithms))
+	for i, algorithm := range algorithms {
+		names[i] = string(algorithm)
+	}
+	return strings.Join(names, "' or '")
+}
@@ -0,0 +1,115 @@
+package domain_test
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/plar/hash/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashLDAP(t *testing.T) {
+	assert := assert.New(t)
+
+	hash, _ := hex.DecodeString("618bc907a999172fa81a019a11b98935f7f28d5ef5ec8bebf29822f2536eb5f7b42b323c1ad0464be65e67395556c3a5985578116a5d02bc6466c9d4c8f4e691")
+	h := domain.Hash{
+		Algorithm: domain.AlgorithmSSHA512,
+		Salt:      []byte("saltsaltsaltsalt"),
+		Hash:      hash,
+	}
+	value, err := h.LDAP()
+	assert.NoError(err)
+	assert.Equal("{SSHA512}YYvJB6mZFy+oGgGaEbmJNffyjV717Ivr8pgi8lNutfe0KzI8GtBGS+ZeZzlVVsOlmFV4EWpdArxkZsnUyPTmkXNhbHRzYWx0c2FsdHNhbHQ=", value)
+
+	// the salted SHA512 of the service hashes salt || password
+	h.Algorithm = domain.AlgorithmSHA512
+	_, err = h.LDAP()
+	assert.True(errors.Is(err, domain.ErrFormatMismatch))
+	assert.EqualError(err, "Hash does not match the format 'ldap': the algorithm is 'sha512', expected 'ssha512'")
+}
+
+func TestHashDjango(t *testing.T) {
+	assert := assert.New(t)
+
+	hash, _ := hex.DecodeString("f275fb870144cc807c68f6a325360af3078741ce4d833d2915500abd2bb88d00")
+	h := domain.Hash{
+		Algorithm: domain.AlgorithmPBKDF2SHA256,
+		Params:    "i=1000",
+		Salt:      []byte("saltsaltsaltsalt"),
+		Hash:      hash,
+	}
+	value, err := h.Django()
+	assert.NoError(err)
+	assert.Equal("pbkdf2_sha256$1000$saltsaltsaltsalt$8nX7hwFEzIB8aPajJTYK8weHQc5Ngz0pFVAKvSu4jQA=", value)
+
+	mismatches := []domain.Hash{
+		{Algorithm: domain.AlgorithmPBKDF2SHA512, Params: "i=1000", Salt: h.Salt, Hash: hash},
+		{Algorithm: domain.AlgorithmPBKDF2SHA256, Params: "i=1000", Salt: []byte{0x00, 0xff}, Hash: hash},
+		{Algorithm: domain.AlgorithmPBKDF2SHA256, Params: "i=1000", Salt: []byte("salt$"), Hash: hash},
+		{Algorithm: domain.AlgorithmPBKDF2SHA256, Params: "i=1000", Salt: h.Salt, Hash: hash[:16]},
+		{Algorithm: domain.AlgorithmPBKDF2SHA256, Params: "m=1000", Salt: h.Salt, Hash: hash},
+	}
+	for _, m := range mismatches {
+		_, err := m.Django()
+		assert.True(errors.Is(err, domain.ErrFormatMismatch), m)
+	}
+}
+
+func TestHashASPNetIdentity(t *testing.T) {
+	assert := assert.New(t)
+
+	hash, _ := hex.DecodeString("f275fb870144cc807c68f6a325360af3078741ce4d833d2915500abd2bb88d00")
+	h := domain.Hash{
+		Algorithm: domain.AlgorithmPBKDF2SHA256,
+		Params:    "i=1000",
+		Salt:      []byte("saltsaltsaltsalt"),
+		Hash:      hash,
+	}
+	value, err := h.ASPNetIdentity()
+	assert.NoError(err)
+	assert.Equal("AQAAAAEAAAPoAAAAEHNhbHRzYWx0c2FsdHNhbHTydfuHAUTMgHxo9qMlNgrzB4dBzk2DPSkVUAq9K7iNAA==", value)
+
+	h.Algorithm = domain.AlgorithmPBKDF2SHA512
+	value, err = h.ASPNetIdentity()
+	assert.NoError(err)
+	assert.Equal("AQAAAAIAAAPoAAAAEHNhbHRzYWx0c2FsdHNhbHTydfuHAUTMgHxo9qMlNgrzB4dBzk2DPSkVUAq9K7iNAA==", value)
+
+	h.Salt = []byte("salt")
+	_, err = h.ASPNetIdentity()
+	assert.True(errors.Is(err, domain.ErrFormatMismatch))
+
+	h.Algorithm = domain.AlgorithmScrypt
+	_, err = h.ASPNetIdentity()
+	assert.EqualError(err, "Hash does not match the format 'aspnet': the algorithm is 'scrypt', expected 'pbkdf2-sha256' or 'pbkdf2-sha512'")
+}
+
+func TestHashFormatNotPortable(t *testing.T) {
+	assert := assert.New(t)
+
+	h := domain.Hash{
+		Algorithm: domain.AlgorithmSSHA512,
+		Salt:      []byte("saltsaltsaltsalt"),
+		Hash:      make([]byte, 64),
+	}
+
+	peppered := h
+	peppered.KeyID = "k1"
+	_, err := peppered.LDAP()
+	assert.EqualError(err, "Hash does not match the format 'ldap': the hash is peppered")
+
+	pipeline := h
+	pipeline.Pipeline = "sha512+argon2id"
+	_, err = pipeline.LDAP()
+	assert.EqualError(err, "Hash does not match the format 'ldap': the hash is calculated by the pipeline 'sha512+argon2id'")
+
+	relief := h
+	relief.Relief = true
+	_, err = relief.LDAP()
+	assert.EqualError(err, "Hash does not match the format 'ldap': the hash is a server relief hash")
+
+	normalized := h
+	normalized.Normalization = domain.StageNFKC
+	_, err = normalized.LDAP()
+	assert.EqualError(err, "Hash does not match the format 'ldap': the password is normalized with 'nfkc'")
+}
@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
 )
@@ -8,9 +9,29 @@ import (
 // HashID is the hash identifier
 type HashID int64
 
+// Hash is a password hash record
 type Hash struct {
 	ID   HashID
 	Hash []byte
+
+	// Salt is the random salt used to calculate the hash,
+	// empty if the salt is embedded into the hash by the algorithm itself
+	Salt []byte
+	// Algorithm is the algorithm used to calculate the hash
+	Algorithm Algorithm
+	// Params are the algorithm parameters in the PHC string format, e.g. m=65536,t=1,p=2
+	Params string
+	// KeyID is the identifier of the pepper key mixed into the password, empty if none
+	KeyID string
+	// Pipeline is the identifier of the pipeline around the algorithm, empty if the algorithm is used as is
+	Pipeline string
+	// Normalization is the Unicode normalization mode applied to the password, empty if none
+	Normalization Stage
+	// Probe marks a synthetic record of the end-to-end probe, probe records are excluded from listings
+	Probe bool
+	// Relief marks a server relief hash: the client computes the KDF and the hash is the final hash of the client key,
+	// so it cannot be verified with a plaintext password
+	Relief bool
 }
 
 func (h HashID) Bytes() []byte {
@@ -23,9 +44,23 @@ func (h Hash) Base64() []byte {
 	return encHash
 }
 
+// Equal reports whether both hash records are the same
+func (h Hash) Equal(other Hash) bool {
+	return h.ID == other.ID &&
+		h.Algorithm == other.Algorithm &&
+		h.Params == other.Params &&
+		h.KeyID == other.KeyID &&
+		h.Pipeline == other.Pipeline &&
+		h.Normalization == other.Normalization &&
+		h.Probe == other.Probe &&
+		h.Relief == other.Relief &&
+		bytes.Equal(h.Salt, other.Salt) &&
+		bytes.Equal(h.Hash, other.Hash)
+}
+
 func (h Hash) String() string {
 	if h.ID == 0 {
 		return "hash{}"
 	}
-	return fmt.Sprintf("hash{ID: %v, Hash: %s}", h.ID, h.Base64())
+	return fmt.Sprintf("hash{ID: %v, Algorithm: %v, Hash: %s}", h.ID, h.Algorithm, h.Base64())
 }
@@ -1,6 +1,8 @@
 package domain_test
 
 import (
+	"encoding/base64"
+	"strings"
 	"testing"
 
 	"github.com/plar/hash/domain"
@@ -19,11 +21,63 @@ func TestHashID(t *testing.T) {
 func TestHash(t *testing.T) {
 	assert := assert.New(t)
 	hash := domain.Hash{
-		ID:   123,
-		Hash: []byte{0xde, 0xad, 0xbe, 0xaf}}
+		ID:        123,
+		Hash:      []byte{0xde, 0xad, 0xbe, 0xaf},
+		Salt:      []byte{0x01, 0x02},
+		Algorithm: domain.AlgorithmSHA512}
 
 	assert.Equal([]byte("3q2+rw=="), hash.Base64())
-	assert.Equal("hash{ID: 123, Hash: 3q2+rw==}", hash.String())
+	assert.Equal("hash{ID: 123, Algorithm: sha512, Hash: 3q2+rw==}", hash.String())
 
 	assert.Equal("hash{}", domain.Hash{}.String())
 }
+
+func TestHashEqual(t *testing.T) {
+	assert := assert.New(t)
+
+	hash := domain.Hash{
+		ID:        123,
+		Hash:      []byte{0xde, 0xad, 0xbe, 0xaf},
+		Salt:      []byte{0x01, 0x02},
+		Algorithm: domain.AlgorithmSHA512}
+
+	other := hash
+	assert.True(hash.Equal(other))
+
+	other.Salt = []byte{0x01, 0x03}
+	assert.False(hash.Equal(other))
+
+	other = hash
+	other.Params = "i=1"
+	assert.False(hash.Equal(other))
+
+	other = hash
+	other.Relief = true
+	assert.False(hash.Equal(other))
+}
+
+func TestHashSCRAM(t *testing.T) {
+	assert := assert.New(t)
+
+	// RFC 7677 test vector
+	salt, _ := base64.StdEncoding.DecodeString("W22ZaJ0SNY7soEsUEjb6gQ==")
+	storedKey, _ := base64.StdEncoding.DecodeString("WG5d8oPm3OtcPnkdi4Uo7BkeZkBFzpcXkuLmtbsT4qY=")
+	serverKey, _ := base64.StdEncoding.DecodeString("wfPLwcE6nTWhTAmQ7tl2KeoiWGPlZqQxSrmfPwDl2dU=")
+	hash := domain.Hash{
+		Algorithm: domain.AlgorithmSCRAMSHA256,
+		Params:    "i=4096",
+		Salt:      salt,
+		Hash:      append(storedKey, serverKey...),
+	}
+	assert.Equal("SCRAM-SHA-256$4096:W22ZaJ0SNY7soEsUEjb6gQ==$WG5d8oPm3OtcPnkdi4Uo7BkeZkBFzpcXkuLmtbsT4qY=:wfPLwcE6nTWhTAmQ7tl2KeoiWGPlZqQxSrmfPwDl2dU=", hash.SCRAM())
+
+	hash.Algorithm = domain.AlgorithmSCRAMSHA1
+	assert.True(strings.HasPrefix(hash.SCRAM(), "SCRAM-SHA-1$4096:"))
+
+	hash.Params = "m=4096"
+	assert.Equal("", hash.SCRAM())
+
+	hash.Params = "i=4096"
+	hash.Algorithm = domain.AlgorithmSHA512
+	assert.Equal("", hash.SCRAM())
+}
@@ -0,0 +1,213 @@
+package domain
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidPHC is returned when a string is not a valid PHC string
+var ErrInvalidPHC = errors.New("invalid PHC string")
+
+// argon2Version is the Argon2 version implemented by golang.org/x/crypto/argon2
+const argon2Version = 19
+
+// phcMaxNameLen is the maximum length of the algorithm identifier and parameter names
+const phcMaxNameLen = 32
+
+// phcEncoding is the B64 encoding used by the PHC string format, i.e. the standard base64 without padding
+var phcEncoding = base64.RawStdEncoding.Strict()
+
+// PHC is a password hash in the PHC string format:
+//
+//	$<id>[$v=<version>][$<param>=<value>(,<param>=<value>)*][$<salt>[$<hash>]]
+//
+// See https://github.com/P-H-C/phc-string-format/blob/master/phc-sf-spec.md
+type PHC struct {
+	ID string
+	// Version is the algorithm version, zero if absent
+	Version int
+	// Params are the comma separated parameters, e.g. m=65536,t=1,p=2
+	Params string
+	Salt   []byte
+	Hash   []byte
+}
+
+// String encodes the PHC string
+func (p PHC) String() string {
+	var sb strings.Builder
+	sb.WriteString("$")
+	sb.WriteString(p.ID)
+	if p.Version != 0 {
+		fmt.Fprintf(&sb, "$v=%d", p.Version)
+	}
+	if p.Params != "" {
+		sb.WriteString("$")
+		sb.WriteString(p.Params)
+	}
+	if len(p.Salt) > 0 || len(p.Hash) > 0 {
+		sb.WriteString("$")
+		sb.WriteString(phcEncoding.EncodeToString(p.Salt))
+	}
+	if len(p.Hash) > 0 {
+		sb.WriteString("$")
+		sb.WriteString(phcEncoding.EncodeToString(p.Hash))
+	}
+	return sb.String()
+}
+
+// ParsePHC parses a PHC string.
+// The parser is strict, any deviation from the format returns ErrInvalidPHC.
+func ParsePHC(s string) (PHC, error) {
+	if !strings.HasPrefix(s, "$") {
+		return PHC{}, fmt.Errorf("%w: missing '$' prefix", ErrInvalidPHC)
+	}
+
+	fields := strings.Split(s[1:], "$")
+	if !isPHCName(fields[0]) {
+		return PHC{}, fmt.Errorf("%w: bad identifier '%v'", ErrInvalidPHC, fields[0])
+	}
+
+	p := PHC{ID: fields[0]}
+	fields = fields[1:]
+
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "v=") {
+		version, err := parsePHCDecimal(fields[0][2:])
+		if err != nil {
+			return PHC{}, fmt.Errorf("%w: bad version '%v'", ErrInvalidPHC, fields[0])
+		}
+		p.Version = version
+		fields = fields[1:]
+	}
+
+	if len(fields) > 0 && strings.Contains(fields[0], "=") {
+		if err := validatePHCParams(fields[0]); err != nil {
+			return PHC{}, err
+		}
+		p.Params = fields[0]
+		fields = fields[1:]
+	}
+
+	if len(fields) > 0 {
+		salt, err := phcEncoding.DecodeString(fields[0])
+		if err != nil || len(salt) == 0 {
+			return PHC{}, fmt.Errorf("%w: bad salt '%v'", ErrInvalidPHC, fields[0])
+		}
+		p.Salt = salt
+		fields = fields[1:]
+	}
+
+	if len(fields) > 0 {
+		hash, err := phcEncoding.DecodeString(fields[0])
+		if err != nil || len(hash) == 0 {
+			return PHC{}, fmt.Errorf("%w: bad hash '%v'", ErrInvalidPHC, fields[0])
+		}
+		p.Hash = hash
+		fields = fields[1:]
+	}
+
+	if len(fields) > 0 {
+		return PHC{}, fmt.Errorf("%w: unexpected field '%v'", ErrInvalidPHC, fields[0])
+	}
+
+	return p, nil
+}
+
+func validatePHCParams(params string) error {
+	seen := make(map[string]bool)
+	for _, param := range strings.Split(params, ",") {
+		kv := strings.SplitN(param, "=", 2)
+		if len(kv) != 2 || !isPHCName(kv[0]) || !isPHCValue(kv[1]) {
+			return fmt.Errorf("%w: bad parameter '%v'", ErrInvalidPHC, param)
+		}
+		if seen[kv[0]] {
+			return fmt.Errorf("%w: duplicate parameter '%v'", ErrInvalidPHC, kv[0])
+		}
+		seen[kv[0]] = true
+	}
+	return nil
+}
+
+// parsePHCDecimal parses a decimal number without sign and leading zeros
+func parsePHCDecimal(s string) (int, error) {
+	if s == "" || (len(s) > 1 && s[0] == '0') {
+		return 0, strconv.ErrSyntax
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return 0, strconv.ErrSyntax
+		}
+	}
+	return strconv.Atoi(s)
+}
+
+func isPHCName(s string) bool {
+	if s == "" || len(s) > phcMaxNameLen {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+func isPHCValue(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' ||
+			c == '/' || c == '+' || c == '.' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// PHC returns the hash in the PHC string format.
+// bcrypt hashes are returned in their own modular crypt format, e.g. $2a$10$...
+// Peppered, pipelined and pre-hashed hashes and the algorithms without a PHC identifier known to third-party systems,
+// e.g. SCRAM and SRP verifiers, cannot be verified by third-party systems, ErrFormatMismatch is returned.
+func (h Hash) PHC() (string, error) {
+	switch {
+	case h.Algorithm.IsSCRAM(), h.Algorithm.IsSRP(), h.Algorithm == AlgorithmArgon2idSHA512, h.Algorithm == AlgorithmSSHA512:
+		return "", fmt.Errorf("%w 'phc': the algorithm '%v' has no standard PHC identifier", ErrFormatMismatch, h.Algorithm)
+	case h.Relief:
+		return "", fmt.Errorf("%w 'phc': the hash is a server relief hash", ErrFormatMismatch)
+	case h.KeyID != "":
+		return "", fmt.Errorf("%w 'phc': the hash is peppered", ErrFormatMismatch)
+	case h.Pipeline != "":
+		return "", fmt.Errorf("%w 'phc': the hash is calculated by the pipeline '%v'", ErrFormatMismatch, h.Pipeline)
+	case h.preHash() != "":
+		return "", fmt.Errorf("%w 'phc': the password is pre-hashed with '%v'", ErrFormatMismatch, h.preHash())
+	}
+
+	if h.Algorithm.IsModularCrypt() {
+		return string(h.Hash), nil
+	}
+
+	p := PHC{
+		ID:     string(h.Algorithm),
+		Params: h.Params,
+		Salt:   h.Salt,
+		Hash:   h.Hash,
+	}
+	if h.Algorithm == AlgorithmArgon2id {
+		p.Version = argon2Version
+	}
+	return p.String(), nil
+}
+
+// preHash returns the pre-hash function of the h=<function> parameter, e.g. c=10,h=sha512 of bcrypt, empty if none
+func (h Hash) preHash() string {
+	for _, param := range strings.Split(h.Params, ",") {
+		if strings.HasPrefix(param, "h=") {
+			return strings.TrimPrefix(param, "h=")
+		}
+	}
+	return ""
+}
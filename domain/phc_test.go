@@ -0,0 +1,123 @@
+package domain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/plar/hash/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPHCString(t *testing.T) {
+	assert := assert.New(t)
+
+	p := domain.PHC{
+		ID:      "argon2id",
+		Version: 19,
+		Params:  "m=65536,t=1,p=2",
+		Salt:    []byte{0xde, 0xad, 0xbe, 0xaf},
+		Hash:    []byte{0x01, 0x02, 0x03},
+	}
+	assert.Equal("$argon2id$v=19$m=65536,t=1,p=2$3q2+rw$AQID", p.String())
+	assert.Equal("$sha512", domain.PHC{ID: "sha512"}.String())
+	assert.Equal("$sha512$3q2+rw", domain.PHC{ID: "sha512", Salt: p.Salt}.String())
+}
+
+func TestParsePHC(t *testing.T) {
+	assert := assert.New(t)
+
+	valid := []struct {
+		s        string
+		expected domain.PHC
+	}{
+		{"$argon2id$v=19$m=65536,t=1,p=2$3q2+rw$AQID", domain.PHC{ID: "argon2id", Version: 19, Params: "m=65536,t=1,p=2", Salt: []byte{0xde, 0xad, 0xbe, 0xaf}, Hash: []byte{0x01, 0x02, 0x03}}},
+		{"$scrypt$ln=15,r=8,p=1$3q2+rw$AQID", domain.PHC{ID: "scrypt", Params: "ln=15,r=8,p=1", Salt: []byte{0xde, 0xad, 0xbe, 0xaf}, Hash: []byte{0x01, 0x02, 0x03}}},
+		{"$sha512$3q2+rw$AQID", domain.PHC{ID: "sha512", Salt: []byte{0xde, 0xad, 0xbe, 0xaf}, Hash: []byte{0x01, 0x02, 0x03}}},
+		{"$sha512$3q2+rw", domain.PHC{ID: "sha512", Salt: []byte{0xde, 0xad, 0xbe, 0xaf}}},
+		{"$pbkdf2-sha256$i=1000", domain.PHC{ID: "pbkdf2-sha256", Params: "i=1000"}},
+		{"$sha512", domain.PHC{ID: "sha512"}},
+	}
+	for _, tt := range valid {
+		p, err := domain.ParsePHC(tt.s)
+		assert.NoError(err, tt.s)
+		assert.Equal(tt.expected, p, tt.s)
+		assert.Equal(tt.s, p.String(), tt.s)
+	}
+
+	invalid := []string{
+		"",
+		"argon2id",
+		"$",
+		"$Argon2id",
+		"$argon2id$v=",
+		"$argon2id$v=019",
+		"$argon2id$v=1a",
+		"$argon2id$m=65536,,p=2",
+		"$argon2id$m=65536,m=1",
+		"$argon2id$m=",
+		"$argon2id$m=a*b",
+		"$argon2id$m=1$3q2+rw==",
+		"$argon2id$m=1$3q2+rx",
+		"$argon2id$m=1$$AQID",
+		"$argon2id$m=1$3q2+rw$AQID$",
+		"$argon2id$m=1$3q2+rw$AQID$AQID",
+		"$thisidentifieristoolongtobeavalidname",
+	}
+	for _, s := range invalid {
+		_, err := domain.ParsePHC(s)
+		assert.True(errors.Is(err, domain.ErrInvalidPHC), s)
+	}
+}
+
+func TestHashPHC(t *testing.T) {
+	assert := assert.New(t)
+
+	hash := domain.Hash{
+		ID:        1,
+		Hash:      []byte{0x01, 0x02, 0x03},
+		Salt:      []byte{0xde, 0xad, 0xbe, 0xaf},
+		Algorithm: domain.AlgorithmArgon2id,
+		Params:    "m=65536,t=1,p=2",
+	}
+	phc, err := hash.PHC()
+	assert.NoError(err)
+	assert.Equal("$argon2id$v=19$m=65536,t=1,p=2$3q2+rw$AQID", phc)
+
+	hash.Algorithm = domain.AlgorithmSHA512
+	hash.Params = ""
+	phc, err = hash.PHC()
+	assert.NoError(err)
+	assert.Equal("$sha512$3q2+rw$AQID", phc)
+
+	hash = domain.Hash{
+		ID:        1,
+		Hash:      []byte("$2a$04$abcdefghijklmnopqrstuu"),
+		Algorithm: domain.AlgorithmBcrypt,
+		Params:    "c=4",
+	}
+	phc, err = hash.PHC()
+	assert.NoError(err)
+	assert.Equal("$2a$04$abcdefghijklmnopqrstuu", phc)
+}
+
+func TestHashPHCMismatch(t *testing.T) {
+	assert := assert.New(t)
+
+	hashes := []domain.Hash{
+		{ID: 1, Hash: []byte{1}, Salt: []byte{2}, Algorithm: domain.AlgorithmArgon2id, Params: "m=65536,t=1,p=2", KeyID: "k1"},
+		{ID: 2, Hash: []byte{1}, Salt: []byte{2}, Algorithm: domain.AlgorithmArgon2id, Params: "m=65536,t=1,p=2", Pipeline: "strict"},
+		{ID: 3, Hash: []byte("$2a$04$abcdefghijklmnopqrstuu"), Algorithm: domain.AlgorithmBcrypt, Params: "c=4,h=sha512"},
+		{ID: 4, Hash: []byte{1}, Salt: []byte{2}, Algorithm: domain.AlgorithmArgon2id, Params: "m=65536,t=1,p=2", Relief: true},
+		// no PHC consumer knows these identifiers
+		{ID: 5, Hash: []byte{1}, Salt: []byte{2}, Algorithm: domain.AlgorithmArgon2idSHA512, Params: "m=65536,t=1,p=2"},
+		{ID: 6, Hash: []byte{1}, Salt: []byte{2}, Algorithm: domain.AlgorithmSCRAMSHA1, Params: "i=4096"},
+		{ID: 7, Hash: []byte{1}, Salt: []byte{2}, Algorithm: domain.AlgorithmSCRAMSHA256, Params: "i=4096"},
+		{ID: 8, Hash: []byte{1}, Salt: []byte{2}, Algorithm: domain.AlgorithmSRP6a, Params: "n=2048"},
+		{ID: 9, Hash: []byte{1}, Salt: []byte{2}, Algorithm: domain.AlgorithmSSHA512},
+	}
+	for _, hash := range hashes {
+		phc, err := hash.PHC()
+		assert.True(errors.Is(err, domain.ErrFormatMismatch), hash.ID)
+		assert.Empty(phc)
+	}
+}
@@ -0,0 +1,138 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidPipeline is returned when a pipeline cannot be parsed
+var ErrInvalidPipeline = errors.New("Invalid pipeline")
+
+// Stage is a named step of a hashing pipeline
+type Stage string
+
+// Normalization stages
+const (
+	StageNFC  Stage = "nfc"
+	StageNFKC Stage = "nfkc"
+	// StageOpaqueString is the RFC 8265 OpaqueString profile for passwords
+	StageOpaqueString Stage = "opaquestring"
+)
+
+// Pre-hash stages
+const (
+	StageSHA256 Stage = "sha256"
+	StageSHA512 Stage = "sha512"
+)
+
+// HMAC stages, the key is the pepper key of the hash
+const (
+	StageHMACSHA256 Stage = "hmac-sha256"
+	StageHMACSHA512 Stage = "hmac-sha512"
+)
+
+// IsNormalization reports whether the stage is a normalization stage
+func (s Stage) IsNormalization() bool {
+	return s == StageNFC || s == StageNFKC || s == StageOpaqueString
+}
+
+// pipelineSeparator separates stages of the pipeline identifier
+const pipelineSeparator = "+"
+
+// Pipeline is a sequence of stages: an optional normalization, an optional pre-hash,
+// the KDF algorithm and an optional HMAC of the KDF output.
+// The zero value is an empty pipeline, i.e. the plain algorithm of the hash.
+type Pipeline struct {
+	Normalize Stage
+	PreHash   Stage
+	KDF       Algorithm
+	HMAC      Stage
+}
+
+// IsZero reports whether the pipeline is empty
+func (p Pipeline) IsZero() bool {
+	return p == Pipeline{}
+}
+
+// Stages returns the non-empty stages in the execution order
+func (p Pipeline) Stages() []Stage {
+	var stages []Stage
+	for _, stage := range []Stage{p.Normalize, p.PreHash, Stage(p.KDF), p.HMAC} {
+		if stage != "" {
+			stages = append(stages, stage)
+		}
+	}
+	return stages
+}
+
+// ID returns the stable pipeline identifier, e.g. nfkc+sha512+argon2id+hmac-sha256.
+// The identifier is stored with the hash and is parsed back with ParsePipeline.
+func (p Pipeline) ID() string {
+	stages := p.Stages()
+	ids := make([]string, len(stages))
+	for i, stage := range stages {
+		ids[i] = string(stage)
+	}
+	return strings.Join(ids, pipelineSeparator)
+}
+
+func (p Pipeline) String() string {
+	return p.ID()
+}
+
+// ParsePipeline parses the pipeline identifier, the stages must be in the execution order.
+// The empty identifier is the empty pipeline.
+func ParsePipeline(id string) (Pipeline, error) {
+	var p Pipeline
+	if id == "" {
+		return p, nil
+	}
+
+	invalid := func(reason string) (Pipeline, error) {
+		return Pipeline{}, fmt.Errorf("%w '%v': %v", ErrInvalidPipeline, id, reason)
+	}
+
+	// the stages position: 0 - normalize, 1 - pre-hash, 2 - KDF, 3 - HMAC
+	pos := 0
+	for _, raw := range strings.Split(id, pipelineSeparator) {
+		stage := Stage(raw)
+		switch {
+		case stage.IsNormalization():
+			if pos > 0 {
+				return invalid(fmt.Sprintf("unexpected normalization stage '%v'", stage))
+			}
+			p.Normalize, pos = stage, 1
+		case stage == StageSHA256 || stage == StageSHA512:
+			if pos > 1 {
+				return invalid(fmt.Sprintf("unexpected pre-hash stage '%v'", stage))
+			}
+			p.PreHash, pos = stage, 2
+		case Algorithm(stage).IsValid():
+			if pos > 2 {
+				return invalid(fmt.Sprintf("unexpected KDF stage '%v'", stage))
+			}
+			p.KDF, pos = Algorithm(stage), 3
+		case stage == StageHMACSHA256 || stage == StageHMACSHA512:
+			if pos != 3 {
+				return invalid(fmt.Sprintf("unexpected HMAC stage '%v'", stage))
+			}
+			p.HMAC, pos = stage, 4
+		default:
+			return invalid(fmt.Sprintf("unknown stage '%v'", stage))
+		}
+	}
+
+	if p.KDF == "" {
+		return invalid("the KDF stage is required")
+	}
+	// modular crypt hashes embed a random salt and are verified by the algorithm itself
+	if p.HMAC != "" && p.KDF.IsModularCrypt() {
+		return invalid(fmt.Sprintf("the HMAC stage cannot follow '%v'", p.KDF))
+	}
+	// SCRAM verifiers are consumed by external servers which know nothing about the other stages
+	if p.KDF.IsSCRAM() {
+		return invalid(fmt.Sprintf("'%v' cannot be a pipeline stage", p.KDF))
+	}
+	return p, nil
+}
@@ -0,0 +1,59 @@
+package domain_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/plar/hash/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePipeline(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		id       string
+		expected domain.Pipeline
+	}{
+		{"", domain.Pipeline{}},
+		{"argon2id", domain.Pipeline{KDF: domain.AlgorithmArgon2id}},
+		{"nfkc+sha512+argon2id+hmac-sha256", domain.Pipeline{Normalize: domain.StageNFKC, PreHash: domain.StageSHA512, KDF: domain.AlgorithmArgon2id, HMAC: domain.StageHMACSHA256}},
+		{"opaquestring+sha256+scrypt", domain.Pipeline{Normalize: domain.StageOpaqueString, PreHash: domain.StageSHA256, KDF: domain.AlgorithmScrypt}},
+		{"nfc+scrypt", domain.Pipeline{Normalize: domain.StageNFC, KDF: domain.AlgorithmScrypt}},
+		{"sha256+bcrypt", domain.Pipeline{PreHash: domain.StageSHA256, KDF: domain.AlgorithmBcrypt}},
+		{"pbkdf2-sha512+hmac-sha512", domain.Pipeline{KDF: domain.AlgorithmPBKDF2SHA512, HMAC: domain.StageHMACSHA512}},
+	}
+
+	for _, test := range tests {
+		p, err := domain.ParsePipeline(test.id)
+		assert.NoError(err, test.id)
+		assert.Equal(test.expected, p)
+		assert.Equal(test.id, p.ID())
+	}
+	assert.True(domain.Pipeline{}.IsZero())
+	assert.Equal([]domain.Stage{"nfc", "scrypt"}, tests[4].expected.Stages())
+
+	invalid := []string{
+		"+",
+		"md5",
+		"nfkc",
+		"sha512",
+		"nfkc+sha512",
+		"argon2id+nfkc",
+		"sha512+nfkc+argon2id",
+		"argon2id+sha512",
+		"argon2id+scrypt",
+		"hmac-sha256+argon2id",
+		"argon2id+hmac-sha256+hmac-sha512",
+		"bcrypt+hmac-sha256",
+		"md5-crypt",
+		"scram-sha-256",
+		"nfkc+ssha512",
+		"nfkc+scram-sha-1",
+		"argon2id+",
+	}
+	for _, id := range invalid {
+		_, err := domain.ParsePipeline(id)
+		assert.True(errors.Is(err, domain.ErrInvalidPipeline), id)
+	}
+}
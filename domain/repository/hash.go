@@ -8,15 +8,31 @@ import (
 
 var ErrHashNotFound = errors.New("hash not found")
 
+var ErrHashModified = errors.New("hash has been modified")
+
 // HashRepository represents a persistence layer.
 type HashRepository interface {
 	// NewID generates a new HashID.
 	NewID() domain.HashID
 
-	// Load loads a password hash from the repository.
+	// Load loads a password hash record from the repository.
 	// If the repository does not contain a password hash then ErrHashNotFound is returned.
 	Load(id domain.HashID) (domain.Hash, error)
 
-	// Save saves a new password hash to the repository.
-	Save(id domain.HashID, hash []byte)
+	// Save saves a new password hash record to the repository.
+	Save(hash domain.Hash)
+
+	// Replace atomically replaces the old password hash record with the new one.
+	// If the repository does not contain a password hash then ErrHashNotFound is returned,
+	// if the stored record is not equal to the old one then ErrHashModified is returned.
+	Replace(old, hash domain.Hash) error
+
+	// Delete deletes a password hash record from the repository.
+	// If the repository does not contain a password hash then ErrHashNotFound is returned.
+	Delete(id domain.HashID) error
+
+	// Range calls fn sequentially for each password hash record in the repository, probe records are skipped.
+	// If fn returns false, Range stops the iteration.
+	// fn may modify the repository, the records saved during the iteration might not be visited.
+	Range(fn func(hash domain.Hash) bool)
 }
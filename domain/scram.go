@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"encoding/base64"
+	"fmt"
+)
+
+// scramMechanisms are the SASL mechanism names of the SCRAM algorithms
+var scramMechanisms = map[Algorithm]string{
+	AlgorithmSCRAMSHA1:   "SCRAM-SHA-1",
+	AlgorithmSCRAMSHA256: "SCRAM-SHA-256",
+}
+
+// SCRAM returns the SCRAM verifier in the PostgreSQL format, empty if the hash is not a SCRAM verifier:
+//
+//	SCRAM-SHA-256$<iterations>:<salt>$<StoredKey>:<ServerKey>
+//
+// The salt and the keys are base64 encoded.
+func (h Hash) SCRAM() string {
+	mechanism, ok := scramMechanisms[h.Algorithm]
+	if !ok || len(h.Hash)%2 != 0 {
+		return ""
+	}
+
+	iterations, err := h.iterations()
+	if err != nil {
+		return ""
+	}
+
+	keyLen := len(h.Hash) / 2
+	return fmt.Sprintf("%v$%v:%v$%v:%v", mechanism, iterations,
+		base64.StdEncoding.EncodeToString(h.Salt),
+		base64.StdEncoding.EncodeToString(h.Hash[:keyLen]),
+		base64.StdEncoding.EncodeToString(h.Hash[keyLen:]))
+}
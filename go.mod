@@ -2,4 +2,8 @@ module github.com/plar/hash
 
 go 1.14
 
-require github.com/stretchr/testify v1.6.1
+require (
+	github.com/stretchr/testify v1.6.1
+	golang.org/x/crypto v0.0.0-20200728195943-123391ffb6de
+	golang.org/x/text v0.3.3
+)
@@ -0,0 +1,247 @@
+package breach
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// ErrInvalidFilter is returned when a breach filter cannot be read
+var ErrInvalidFilter = errors.New("Invalid breach filter")
+
+// bloomMagic identifies the breach filter file format:
+// magic, k uint32, m uint64 and m bits as big-endian uint64 words.
+const bloomMagic = "HBF1"
+
+// maxBloomHashes is the maximum number of hash functions of a filter
+const maxBloomHashes = 64
+
+// bloomHeaderLen is the length of the magic, k and m
+const bloomHeaderLen = 16
+
+// bloomReadWords is the maximum number of words allocated ahead of the read data,
+// the header of a truncated or corrupted file cannot allocate the memory of the whole filter
+const bloomReadWords = 1 << 16
+
+// BloomFilter is a compact probabilistic set of password digests.
+// It has no false negatives, the false positive rate is chosen when the filter is built.
+type BloomFilter struct {
+	k    uint32
+	m    uint64
+	bits []uint64
+}
+
+var _ Corpus = &BloomFilter{}
+
+// NewBloomFilter creates an empty filter sized for n digests with the false positive rate fp
+func NewBloomFilter(n uint64, fp float64) (*BloomFilter, error) {
+	if fp <= 0 || fp >= 1 {
+		return nil, fmt.Errorf("Invalid false positive rate '%v', valid range (0..1)", fp)
+	}
+	if n == 0 {
+		n = 1
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(fp) / (math.Ln2 * math.Ln2)))
+	k := uint32(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	if k > maxBloomHashes {
+		k = maxBloomHashes
+	}
+	return newBloomFilter(k, m), nil
+}
+
+func newBloomFilter(k uint32, m uint64) *BloomFilter {
+	return &BloomFilter{
+		k:    k,
+		m:    m,
+		bits: make([]uint64, (m+63)/64),
+	}
+}
+
+// locations calls fn for every bit of the digest. SHA-1 is uniform,
+// so the bits are derived from the digest itself with double hashing.
+func (f *BloomFilter) locations(digest Digest, fn func(bit uint64) bool) {
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16]) | 1
+	for i := uint64(0); i < uint64(f.k); i++ {
+		if !fn((h1 + i*h2) % f.m) {
+			return
+		}
+	}
+}
+
+// Add adds the digest to the filter
+func (f *BloomFilter) Add(digest Digest) {
+	f.locations(digest, func(bit uint64) bool {
+		f.bits[bit/64] |= 1 << (bit % 64)
+		return true
+	})
+}
+
+// Contains reports whether the digest is probably in the filter
+func (f *BloomFilter) Contains(digest Digest) (bool, error) {
+	found := true
+	f.locations(digest, func(bit uint64) bool {
+		found = f.bits[bit/64]&(1<<(bit%64)) != 0
+		return found
+	})
+	return found, nil
+}
+
+// Hashes returns the number of hash functions
+func (f *BloomFilter) Hashes() uint32 {
+	return f.k
+}
+
+// Size returns the filter size in bits
+func (f *BloomFilter) Size() uint64 {
+	return f.m
+}
+
+// WriteTo writes the filter in the binary format
+func (f *BloomFilter) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var header [16]byte
+	copy(header[:4], bloomMagic)
+	binary.BigEndian.PutUint32(header[4:8], f.k)
+	binary.BigEndian.PutUint64(header[8:16], f.m)
+	if _, err := bw.Write(header[:]); err != nil {
+		return 0, err
+	}
+
+	var word [8]byte
+	for _, bits := range f.bits {
+		binary.BigEndian.PutUint64(word[:], bits)
+		if _, err := bw.Write(word[:]); err != nil {
+			return 0, err
+		}
+	}
+	return int64(len(header) + 8*len(f.bits)), bw.Flush()
+}
+
+// ReadBloomFilter reads a filter written by WriteTo
+func ReadBloomFilter(r io.Reader) (*BloomFilter, error) {
+	br := bufio.NewReader(r)
+	var header [bloomHeaderLen]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+	k, m, err := parseBloomHeader(header)
+	if err != nil {
+		return nil, err
+	}
+
+	// the bits grow with the read data, not with the size declared in the header
+	words := (m + 63) / 64
+	f := &BloomFilter{k: k, m: m, bits: make([]uint64, 0, minWords(words, bloomReadWords))}
+	var word [8]byte
+	for i := uint64(0); i < words; i++ {
+		if _, err := io.ReadFull(br, word[:]); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, io.ErrUnexpectedEOF)
+		}
+		f.bits = append(f.bits, binary.BigEndian.Uint64(word[:]))
+	}
+	if _, err := br.ReadByte(); err != io.EOF {
+		return nil, fmt.Errorf("%w: trailing data", ErrInvalidFilter)
+	}
+	return f, nil
+}
+
+func parseBloomHeader(header [bloomHeaderLen]byte) (uint32, uint64, error) {
+	if string(header[:4]) != bloomMagic {
+		return 0, 0, fmt.Errorf("%w: unknown format", ErrInvalidFilter)
+	}
+	k := binary.BigEndian.Uint32(header[4:8])
+	m := binary.BigEndian.Uint64(header[8:16])
+	if k < 1 || k > maxBloomHashes || m == 0 || m > math.MaxUint64-63 {
+		return 0, 0, fmt.Errorf("%w: k=%v, m=%v", ErrInvalidFilter, k, m)
+	}
+	return k, m, nil
+}
+
+func minWords(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// LoadBloomFilter reads the filter from the file,
+// the file length is checked against the header before the filter is read
+func LoadBloomFilter(path string) (*BloomFilter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	var header [bloomHeaderLen]byte
+	if _, err := io.ReadFull(file, header[:]); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFilter, err)
+	}
+	_, m, err := parseBloomHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	if words := (m + 63) / 64; words > uint64(info.Size()-bloomHeaderLen)/8 || uint64(info.Size()-bloomHeaderLen) != 8*words {
+		return nil, fmt.Errorf("%w: m=%v does not match the file size %v", ErrInvalidFilter, m, info.Size())
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return ReadBloomFilter(file)
+}
+
+// BuildBloomFilter builds a filter sized for n digests from the HIBP raw dump,
+// empty lines and zero count entries are skipped. Returns the filter and the number of added digests.
+func BuildBloomFilter(r io.Reader, n uint64, fp float64) (*BloomFilter, uint64, error) {
+	f, err := NewBloomFilter(n, fp)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	added := uint64(0)
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		digest, count, err := ParseLine(scanner.Text())
+		if err != nil {
+			return nil, 0, fmt.Errorf("line %v: %w", lineNo, err)
+		}
+		if count == 0 {
+			continue
+		}
+		f.Add(digest)
+		added++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	return f, added, nil
+}
+
+// CountLines returns the number of non-empty lines, used to size the filter before BuildBloomFilter
+func CountLines(r io.Reader) (uint64, error) {
+	n := uint64(0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) > 0 {
+			n++
+		}
+	}
+	return n, scanner.Err()
+}
@@ -0,0 +1,57 @@
+package breach
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidLine is returned when a line of a breach dataset cannot be parsed
+var ErrInvalidLine = errors.New("Invalid breach dataset line")
+
+// Digest is the SHA-1 digest of a password, the breach datasets are indexed by SHA-1
+type Digest [sha1.Size]byte
+
+// NewDigest returns the SHA-1 digest of the password
+func NewDigest(password []byte) Digest {
+	return Digest(sha1.Sum(password))
+}
+
+// Hex returns the upper case hex encoding of the digest, as used by HIBP datasets
+func (d Digest) Hex() string {
+	return strings.ToUpper(hex.EncodeToString(d[:]))
+}
+
+// Corpus is a local set of breached passwords
+type Corpus interface {
+	// Contains reports whether the password digest is in the corpus
+	Contains(digest Digest) (bool, error)
+}
+
+// ParseLine parses a line of the HIBP raw dump in the <SHA-1 hex>:<count> format,
+// the count is optional. Entries with zero count are padding and should be ignored.
+func ParseLine(line string) (Digest, uint64, error) {
+	var d Digest
+	line = strings.TrimSpace(line)
+
+	count := uint64(1)
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		var err error
+		count, err = strconv.ParseUint(line[i+1:], 10, 64)
+		if err != nil {
+			return d, 0, fmt.Errorf("%w: '%v'", ErrInvalidLine, line)
+		}
+		line = line[:i]
+	}
+
+	if len(line) != hex.EncodedLen(sha1.Size) {
+		return d, 0, fmt.Errorf("%w: '%v'", ErrInvalidLine, line)
+	}
+	if _, err := hex.Decode(d[:], []byte(line)); err != nil {
+		return d, 0, fmt.Errorf("%w: '%v'", ErrInvalidLine, line)
+	}
+	return d, count, nil
+}
@@ -0,0 +1,155 @@
+package breach
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// SHA-1 of "password"
+const passwordSHA1 = "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8"
+
+func TestParseLine(t *testing.T) {
+	assert := assert.New(t)
+
+	d, count, err := ParseLine(passwordSHA1 + ":9545824\r")
+	assert.NoError(err)
+	assert.Equal(NewDigest([]byte("password")), d)
+	assert.Equal(uint64(9545824), count)
+
+	d, count, err = ParseLine(strings.ToLower(passwordSHA1))
+	assert.NoError(err)
+	assert.Equal(passwordSHA1, d.Hex())
+	assert.Equal(uint64(1), count)
+
+	for _, line := range []string{"", "5BAA6", passwordSHA1 + ":x", "ZBAA61E4C9B93F3F0682250B6CF8331B7EE68FD8"} {
+		_, _, err = ParseLine(line)
+		assert.True(errors.Is(err, ErrInvalidLine), line)
+	}
+}
+
+func TestPrefixCorpus(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "breach")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// the suffix of "password" and a padding entry of "angryMonkey"
+	angryMonkey := NewDigest([]byte("angryMonkey")).Hex()
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "5BAA6.txt"), []byte("003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n"), 0600))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, angryMonkey[:5]+".txt"), []byte(angryMonkey[5:]+":0\n"), 0600))
+
+	corpus := NewPrefixCorpus(dir)
+	tests := []struct {
+		password string
+		found    bool
+	}{
+		{"password", true},
+		{"angryMonkey", false},
+		{"correct horse battery staple", false},
+	}
+	for _, test := range tests {
+		found, err := corpus.Contains(NewDigest([]byte(test.password)))
+		assert.NoError(err)
+		assert.Equal(test.found, found, test.password)
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	var dump bytes.Buffer
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&dump, "%v:%v\n", NewDigest([]byte(fmt.Sprintf("password%v", i))).Hex(), i+1)
+	}
+	// padding
+	fmt.Fprintf(&dump, "%v:0\n", NewDigest([]byte("angryMonkey")).Hex())
+
+	n, err := CountLines(bytes.NewReader(dump.Bytes()))
+	assert.NoError(err)
+	assert.Equal(uint64(1001), n)
+
+	f, added, err := BuildBloomFilter(bytes.NewReader(dump.Bytes()), n, 0.001)
+	assert.NoError(err)
+	assert.Equal(uint64(1000), added)
+	assert.Equal(uint32(10), f.Hashes())
+
+	var buf bytes.Buffer
+	written, err := f.WriteTo(&buf)
+	assert.NoError(err)
+	assert.Equal(int64(buf.Len()), written)
+
+	f, err = ReadBloomFilter(&buf)
+	assert.NoError(err)
+
+	// no false negatives
+	for i := 0; i < 1000; i++ {
+		found, err := f.Contains(NewDigest([]byte(fmt.Sprintf("password%v", i))))
+		assert.NoError(err)
+		assert.True(found)
+	}
+
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if found, _ := f.Contains(NewDigest([]byte(fmt.Sprintf("angryMonkey%v", i)))); found {
+			falsePositives++
+		}
+	}
+	assert.Less(falsePositives, 50)
+
+	_, _, err = BuildBloomFilter(strings.NewReader("5BAA6\n"), 1, 0.001)
+	assert.True(errors.Is(err, ErrInvalidLine))
+
+	_, err = NewBloomFilter(1, 1)
+	assert.Error(err)
+}
+
+func TestReadBloomFilterInvalid(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := NewBloomFilter(10, 0.01)
+	assert.NoError(err)
+	var buf bytes.Buffer
+	_, err = f.WriteTo(&buf)
+	assert.NoError(err)
+	data := buf.Bytes()
+
+	for _, invalid := range [][]byte{
+		nil,
+		[]byte("HBF2" + string(data[4:])),
+		data[:len(data)-1],
+		append(append([]byte{}, data...), 0),
+	} {
+		_, err = ReadBloomFilter(bytes.NewReader(invalid))
+		assert.True(errors.Is(err, ErrInvalidFilter))
+	}
+
+	// a huge size in the header of a short file is rejected before the filter is allocated
+	huge := append([]byte{}, data...)
+	binary.BigEndian.PutUint64(huge[8:16], 1<<62)
+	_, err = ReadBloomFilter(bytes.NewReader(huge))
+	assert.True(errors.Is(err, ErrInvalidFilter))
+
+	dir, err := ioutil.TempDir("", "breach")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "filter.bin")
+	assert.NoError(ioutil.WriteFile(path, huge, 0600))
+	_, err = LoadBloomFilter(path)
+	assert.True(errors.Is(err, ErrInvalidFilter))
+
+	assert.NoError(ioutil.WriteFile(path, data, 0600))
+	loaded, err := LoadBloomFilter(path)
+	assert.NoError(err)
+	assert.Equal(f, loaded)
+}
@@ -0,0 +1,59 @@
+package breach
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// prefixLen is the length of the hex SHA-1 prefix of the k-anonymity range files
+const prefixLen = 5
+
+// PrefixCorpus is a directory of HIBP range files partitioned by the first 5 hex characters of SHA-1,
+// e.g. 5BAA6.txt contains the hash suffixes starting with 5BAA6 in the <suffix>:<count> format.
+type PrefixCorpus struct {
+	dir string
+}
+
+var _ Corpus = &PrefixCorpus{}
+
+// NewPrefixCorpus creates a corpus of the range files in the directory
+func NewPrefixCorpus(dir string) *PrefixCorpus {
+	return &PrefixCorpus{
+		dir: dir,
+	}
+}
+
+// Contains scans the range file of the digest prefix, a missing file is an empty range
+func (c *PrefixCorpus) Contains(digest Digest) (bool, error) {
+	h := digest.Hex()
+	prefix, suffix := h[:prefixLen], h[prefixLen:]
+
+	f, err := os.Open(filepath.Join(c.dir, prefix+".txt"))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.IndexByte(line, ':')
+		if i < 0 || !strings.EqualFold(line[:i], suffix) {
+			continue
+		}
+		// zero count entries are padding
+		count, err := strconv.ParseUint(line[i+1:], 10, 64)
+		if err != nil {
+			return false, fmt.Errorf("%w: '%v' in %v.txt", ErrInvalidLine, line, prefix)
+		}
+		return count > 0, nil
+	}
+	return false, scanner.Err()
+}
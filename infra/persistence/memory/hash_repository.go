@@ -15,7 +15,7 @@ const defTotalBuckets = 8
 
 type bucket struct {
 	sync.RWMutex
-	storage map[domain.HashID][]byte
+	storage map[domain.HashID]domain.Hash
 }
 
 type hashRepository struct {
@@ -39,7 +39,7 @@ func NewHashRepositoryWithBuckets(totalBuckets int) repository.HashRepository {
 
 	buckets := make([]bucket, totalBuckets)
 	for i := 0; i < totalBuckets; i++ {
-		buckets[i] = bucket{storage: make(map[domain.HashID][]byte, 0)}
+		buckets[i] = bucket{storage: make(map[domain.HashID]domain.Hash, 0)}
 	}
 
 	return &hashRepository{
@@ -53,7 +53,7 @@ func (r *hashRepository) NewID() domain.HashID {
 	return domain.HashID(atomic.AddInt64(&r.curID, 1))
 }
 
-// Load loads a password hash from the repository.
+// Load loads a password hash record from the repository.
 // If the repository does not contain a password hash then repository.ErrHashNotFound error is returned.
 func (r *hashRepository) Load(id domain.HashID) (hash domain.Hash, err error) {
 	bid := int(id) % r.totalBuckets
@@ -70,17 +70,68 @@ func (r *hashRepository) loadFromBucket(bid int, id domain.HashID) (domain.Hash,
 		return domain.Hash{}, fmt.Errorf("HashID '%v': %w", id, repository.ErrHashNotFound)
 	}
 
-	return domain.Hash{
-		ID:   id,
-		Hash: hash,
-	}, nil
+	return hash, nil
 }
 
-// Save saves a new password hash to the repository.
-func (r *hashRepository) Save(id domain.HashID, hash []byte) {
-	bid := int(id) % r.totalBuckets
+// Save saves a new password hash record to the repository.
+func (r *hashRepository) Save(hash domain.Hash) {
+	bid := int(hash.ID) % r.totalBuckets
 
 	r.buckets[bid].Lock()
-	r.buckets[bid].storage[id] = hash
+	r.buckets[bid].storage[hash.ID] = hash
 	r.buckets[bid].Unlock()
 }
+
+// Replace atomically replaces the old password hash record with the new one.
+func (r *hashRepository) Replace(old, hash domain.Hash) error {
+	bid := int(hash.ID) % r.totalBuckets
+
+	r.buckets[bid].Lock()
+	defer r.buckets[bid].Unlock()
+
+	stored, err := r.loadFromBucket(bid, hash.ID)
+	if err != nil {
+		return err
+	}
+	if !stored.Equal(old) {
+		return fmt.Errorf("HashID '%v': %w", hash.ID, repository.ErrHashModified)
+	}
+
+	r.buckets[bid].storage[hash.ID] = hash
+	return nil
+}
+
+// Delete deletes a password hash record from the repository.
+func (r *hashRepository) Delete(id domain.HashID) error {
+	bid := int(id) % r.totalBuckets
+
+	r.buckets[bid].Lock()
+	defer r.buckets[bid].Unlock()
+
+	if _, err := r.loadFromBucket(bid, id); err != nil {
+		return err
+	}
+	delete(r.buckets[bid].storage, id)
+	return nil
+}
+
+// Range calls fn sequentially for each password hash record in the repository, probe records are skipped.
+// Every bucket is copied before the iteration, so fn may modify the repository.
+func (r *hashRepository) Range(fn func(hash domain.Hash) bool) {
+	for bid := range r.buckets {
+		r.buckets[bid].RLock()
+		hashes := make([]domain.Hash, 0, len(r.buckets[bid].storage))
+		for _, hash := range r.buckets[bid].storage {
+			if !hash.Probe {
+				hashes = append(hashes, hash)
+			}
+		}
+		r.buckets[bid].RUnlock()
+
+		for _, hash := range hashes {
+			if !fn(hash) {
+				return
+			}
+		}
+	}
+}
@@ -9,6 +9,15 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+func newHash(i int) domain.Hash {
+	return domain.Hash{
+		ID:        domain.HashID(i),
+		Hash:      []byte{byte(i)},
+		Salt:      []byte{byte(i), byte(i)},
+		Algorithm: domain.AlgorithmSHA512,
+	}
+}
+
 func TestNewHashRepository(t *testing.T) {
 	assert := assert.New(t)
 
@@ -19,7 +28,8 @@ func TestNewHashRepository(t *testing.T) {
 	assert.Len(ri.buckets, defTotalBuckets)
 	assert.Equal(int64(0), ri.curID)
 
-	for _, b := range ri.buckets {
+	for i := range ri.buckets {
+		b := &ri.buckets[i]
 		assert.NotNil(b)
 		assert.NotNil(b.storage)
 	}
@@ -45,21 +55,19 @@ func TestLoadAndSave(t *testing.T) {
 	assert.NotNil(ri)
 
 	for i := 0; i < 8+4; i++ {
-		id := domain.HashID(i)
-		hash := []byte{byte(i)}
-		r.Save(id, hash)
-		h, err := r.Load(id)
+		hash := newHash(i)
+		r.Save(hash)
+		h, err := r.Load(hash.ID)
 		assert.NoError(err)
-		assert.Equal(domain.Hash{id, hash}, h)
+		assert.Equal(hash, h)
 	}
 
 	// test bucket distribution
 	for i := 0; i < 8+4; i++ {
 		bid := i % 8
-		id := domain.HashID(i)
-		hash := []byte{byte(i)}
-		assert.Contains(ri.buckets[bid].storage, id)
-		shash := ri.buckets[bid].storage[id]
+		hash := newHash(i)
+		assert.Contains(ri.buckets[bid].storage, hash.ID)
+		shash := ri.buckets[bid].storage[hash.ID]
 		assert.Equal(hash, shash)
 	}
 
@@ -70,21 +78,43 @@ func TestLoadAndSave(t *testing.T) {
 	assert.True(errors.Is(err, repository.ErrHashNotFound))
 }
 
+func TestReplace(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewHashRepository()
+	old := newHash(1)
+
+	// missing hash
+	err := r.Replace(old, old)
+	assert.True(errors.Is(err, repository.ErrHashNotFound))
+
+	r.Save(old)
+
+	hash := old
+	hash.Hash = []byte{0xde, 0xad}
+	hash.Algorithm = domain.AlgorithmArgon2id
+	assert.NoError(r.Replace(old, hash))
+
+	h, err := r.Load(old.ID)
+	assert.NoError(err)
+	assert.Equal(hash, h)
+
+	// the stored hash is not the old one anymore
+	err = r.Replace(old, hash)
+	assert.True(errors.Is(err, repository.ErrHashModified))
+}
+
 func BenchmarkHashRepositorySave(b *testing.B) {
 	r := NewHashRepositoryWithBuckets(8)
 	for n := 0; n < b.N; n++ {
-		id := domain.HashID(n)
-		hash := []byte{byte(n)}
-		r.Save(id, hash)
+		r.Save(newHash(n))
 	}
 }
 
 func BenchmarkHashRepositoryLoad(b *testing.B) {
 	r := NewHashRepositoryWithBuckets(8)
 	for n := 0; n < b.N; n++ {
-		id := domain.HashID(n)
-		hash := []byte{byte(n)}
-		r.Save(id, hash)
+		r.Save(newHash(n))
 	}
 
 	b.ResetTimer()
@@ -93,3 +123,69 @@ func BenchmarkHashRepositoryLoad(b *testing.B) {
 		r.Load(id)
 	}
 }
+
+func TestRange(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewHashRepository()
+	for i := 1; i <= 20; i++ {
+		r.Save(newHash(i))
+	}
+
+	// visit all records and modify them during the iteration
+	visited := map[domain.HashID]bool{}
+	r.Range(func(hash domain.Hash) bool {
+		visited[hash.ID] = true
+		updated := hash
+		updated.Algorithm = domain.AlgorithmArgon2id
+		assert.NoError(r.Replace(hash, updated))
+		return true
+	})
+	assert.Len(visited, 20)
+
+	h, err := r.Load(1)
+	assert.NoError(err)
+	assert.Equal(domain.AlgorithmArgon2id, h.Algorithm)
+
+	// stop the iteration
+	count := 0
+	r.Range(func(hash domain.Hash) bool {
+		count++
+		return count < 5
+	})
+	assert.Equal(5, count)
+}
+
+func TestDelete(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewHashRepository()
+	r.Save(newHash(1))
+
+	assert.NoError(r.Delete(1))
+	_, err := r.Load(1)
+	assert.True(errors.Is(err, repository.ErrHashNotFound))
+	assert.True(errors.Is(r.Delete(1), repository.ErrHashNotFound))
+}
+
+func TestRangeSkipsProbes(t *testing.T) {
+	assert := assert.New(t)
+
+	r := NewHashRepository()
+	r.Save(newHash(1))
+	probe := newHash(2)
+	probe.Probe = true
+	r.Save(probe)
+
+	var visited []domain.HashID
+	r.Range(func(hash domain.Hash) bool {
+		visited = append(visited, hash.ID)
+		return true
+	})
+	assert.Equal([]domain.HashID{1}, visited)
+
+	// probe records are still available by ID
+	h, err := r.Load(2)
+	assert.NoError(err)
+	assert.True(h.Probe)
+}
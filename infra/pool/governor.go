@@ -0,0 +1,144 @@
+package pool
+
+import (
+	"sync"
+)
+
+// Governor is a memory budget admission controller.
+// Tasks reserve their memory cost before execution and wait while the budget is exhausted.
+// The waiting tasks are admitted in FIFO order, so big reservations do not starve behind a stream of small ones.
+type Governor struct {
+	lock     sync.Mutex
+	budget   uint64
+	reserved uint64
+	queue    []*reservation
+	// version numbers the reservation changes
+	version uint64
+
+	// observer is notified outside the lock, published is the version of the last notification
+	observer  func(reserved uint64, waiters int)
+	publish   sync.Mutex
+	published uint64
+}
+
+// snapshot is the state of the governor after a reservation change
+type snapshot struct {
+	version  uint64
+	reserved uint64
+	waiters  int
+}
+
+// reservation is a waiting reservation, ready is closed when the memory is reserved
+type reservation struct {
+	memory uint64
+	ready  chan struct{}
+}
+
+// NewGovernor creates a new governor with the memory budget,
+// the optional observer is notified on every reservation change outside the governor lock,
+// a notification older than the last one is skipped.
+func NewGovernor(budget uint64, observer func(reserved uint64, waiters int)) *Governor {
+	return &Governor{
+		budget:   budget,
+		observer: observer,
+	}
+}
+
+// Acquire reserves memory, blocks until the budget is available and all earlier reservations are admitted.
+// A reservation bigger than the whole budget waits for exclusive access.
+func (g *Governor) Acquire(memory uint64) {
+	memory = g.clamp(memory)
+	if memory == 0 {
+		return
+	}
+
+	g.lock.Lock()
+	if len(g.queue) == 0 && g.reserved+memory <= g.budget {
+		g.reserved += memory
+		state := g.snapshot()
+		g.lock.Unlock()
+		g.notify(state)
+		return
+	}
+	r := &reservation{memory: memory, ready: make(chan struct{})}
+	g.queue = append(g.queue, r)
+	state := g.snapshot()
+	g.lock.Unlock()
+	g.notify(state)
+
+	<-r.ready
+}
+
+// Release returns reserved memory to the budget
+func (g *Governor) Release(memory uint64) {
+	memory = g.clamp(memory)
+	if memory == 0 {
+		return
+	}
+
+	g.lock.Lock()
+	g.reserved -= memory
+	g.admit()
+	state := g.snapshot()
+	g.lock.Unlock()
+	g.notify(state)
+}
+
+// admit reserves memory for the waiting reservations in FIFO order while they fit into the budget,
+// must be called under the lock
+func (g *Governor) admit() {
+	for len(g.queue) > 0 && g.reserved+g.queue[0].memory <= g.budget {
+		r := g.queue[0]
+		g.queue[0] = nil
+		g.queue = g.queue[1:]
+		g.reserved += r.memory
+		close(r.ready)
+	}
+}
+
+// Budget returns the memory budget
+func (g *Governor) Budget() uint64 {
+	return g.budget
+}
+
+// Reserved returns the currently reserved memory
+func (g *Governor) Reserved() uint64 {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.reserved
+}
+
+// Waiters returns the number of tasks waiting for memory
+func (g *Governor) Waiters() int {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return len(g.queue)
+}
+
+func (g *Governor) clamp(memory uint64) uint64 {
+	if memory > g.budget {
+		return g.budget
+	}
+	return memory
+}
+
+// snapshot returns the current state with a new version, must be called under the lock
+func (g *Governor) snapshot() snapshot {
+	g.version++
+	return snapshot{version: g.version, reserved: g.reserved, waiters: len(g.queue)}
+}
+
+// notify calls the observer with the state unless a newer state has been published,
+// must be called outside the lock
+func (g *Governor) notify(state snapshot) {
+	if g.observer == nil {
+		return
+	}
+
+	g.publish.Lock()
+	defer g.publish.Unlock()
+	if state.version > g.published {
+		g.published = state.version
+		g.observer(state.reserved, state.waiters)
+	}
+}
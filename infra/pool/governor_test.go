@@ -0,0 +1,190 @@
+package pool
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGovernor(t *testing.T) {
+	assert := assert.New(t)
+
+	var observed int32
+	g := NewGovernor(10, func(reserved uint64, waiters int) {
+		atomic.AddInt32(&observed, 1)
+	})
+	assert.Equal(uint64(10), g.Budget())
+
+	g.Acquire(6)
+	assert.Equal(uint64(6), g.Reserved())
+	assert.Equal(0, g.Waiters())
+
+	acquired := make(chan struct{})
+	go func() {
+		g.Acquire(6)
+		close(acquired)
+	}()
+
+	// the second reservation waits for the budget
+	for g.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(uint64(6), g.Reserved())
+
+	g.Release(6)
+	<-acquired
+	assert.Equal(uint64(6), g.Reserved())
+	assert.Equal(0, g.Waiters())
+
+	// a reservation bigger than the budget waits for exclusive access
+	g.Release(6)
+	g.Acquire(100)
+	assert.Equal(uint64(10), g.Reserved())
+	g.Release(100)
+	assert.Equal(uint64(0), g.Reserved())
+
+	// zero cost tasks are always admitted
+	g.Acquire(0)
+	assert.Equal(uint64(0), g.Reserved())
+
+	assert.True(atomic.LoadInt32(&observed) > 0)
+}
+
+func TestGovernorObserver(t *testing.T) {
+	assert := assert.New(t)
+
+	// the observer is called outside the governor lock, it may read the governor
+	var g *Governor
+	var lock sync.Mutex
+	var last [2]uint64
+	g = NewGovernor(10, func(reserved uint64, waiters int) {
+		g.Reserved()
+		lock.Lock()
+		last = [2]uint64{reserved, uint64(waiters)}
+		lock.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			g.Acquire(3)
+			g.Release(3)
+		}()
+	}
+	wg.Wait()
+
+	// stale notifications do not overwrite the last state
+	assert.Equal([2]uint64{0, 0}, last)
+}
+
+func TestRunWithGovernor(t *testing.T) {
+	assert := assert.New(t)
+
+	var lock sync.Mutex
+	var maxReserved uint64
+	g := NewGovernor(100, func(reserved uint64, waiters int) {
+		lock.Lock()
+		if reserved > maxReserved {
+			maxReserved = reserved
+		}
+		lock.Unlock()
+	})
+
+	taskQueue := make(chan Task, 10)
+	dispatcher := NewDispatcherWithGovernor(taskQueue, 8, g)
+	dispatcher.Run()
+
+	var running, maxRunning int32
+	for i := 0; i < 100; i++ {
+		dispatcher.Dispatch(Task{
+			ID:     int64(i),
+			Memory: 40,
+			Handler: func() {
+				n := atomic.AddInt32(&running, 1)
+				for {
+					max := atomic.LoadInt32(&maxRunning)
+					if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&running, -1)
+			},
+		})
+	}
+	dispatcher.Stop()
+
+	// only two tasks fit into the budget
+	assert.LessOrEqual(atomic.LoadInt32(&maxRunning), int32(2))
+	assert.LessOrEqual(maxReserved, uint64(100))
+	assert.Equal(uint64(0), g.Reserved())
+}
+
+func TestGovernorFIFO(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGovernor(10, nil)
+	g.Acquire(6)
+
+	big := make(chan struct{})
+	go func() {
+		g.Acquire(10)
+		close(big)
+	}()
+	for g.Waiters() < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the small reservation fits into the budget, but waits behind the big one
+	small := make(chan struct{})
+	go func() {
+		g.Acquire(3)
+		close(small)
+	}()
+	for g.Waiters() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(uint64(6), g.Reserved())
+
+	g.Release(6)
+	<-big
+	assert.Equal(uint64(10), g.Reserved())
+	assert.Equal(1, g.Waiters())
+
+	g.Release(10)
+	<-small
+	assert.Equal(uint64(3), g.Reserved())
+	assert.Equal(0, g.Waiters())
+}
+
+func TestDispatchDelayBeforeReservation(t *testing.T) {
+	assert := assert.New(t)
+
+	g := NewGovernor(10, nil)
+	taskQueue := make(chan Task, 1)
+	dispatcher := NewDispatcherWithGovernor(taskQueue, 1, g)
+	dispatcher.Run()
+
+	var reserved uint64
+	dispatcher.Dispatch(Task{
+		ID:     1,
+		Memory: 10,
+		Delay:  50 * time.Millisecond,
+		Handler: func() {
+			reserved = g.Reserved()
+		},
+	})
+
+	// the delayed task does not hold its memory
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(uint64(0), g.Reserved())
+
+	dispatcher.Stop()
+	assert.Equal(uint64(10), reserved)
+	assert.Equal(uint64(0), g.Reserved())
+}
@@ -1,15 +1,23 @@
 package pool
 
 import (
+	"errors"
 	"log"
 	"sync"
-	"sync/atomic"
 	"time"
 )
 
+// ErrStopped is returned when a task is dispatched to a stopped pool
+var ErrStopped = errors.New("Pool is stopped")
+
 type Task struct {
 	ID      int64
 	Handler func()
+
+	// Memory is the memory cost of the task reserved in the governor before execution
+	Memory uint64
+	// Delay is the time to wait before the memory reservation and execution
+	Delay time.Duration
 }
 
 type TaskQueue chan Task
@@ -31,8 +39,11 @@ type Dispatcher struct {
 	workerPool chan TaskQueue
 	taskQueue  TaskQueue
 	quitCh     chan struct{}
+	governor   *Governor
 
-	shutdown int32
+	// lock orders the accepted tasks before the shutdown
+	lock     sync.RWMutex
+	shutdown bool
 	debug    bool
 }
 
@@ -79,11 +90,18 @@ func (w Worker) start() {
 
 // NewDispatcher creates and returns a new Dispatcher.
 func NewDispatcher(taskQueue TaskQueue, maxWorkers uint) *Dispatcher {
+	return NewDispatcherWithGovernor(taskQueue, maxWorkers, nil)
+}
+
+// NewDispatcherWithGovernor creates and returns a new Dispatcher
+// which admits tasks for execution through the memory governor.
+func NewDispatcherWithGovernor(taskQueue TaskQueue, maxWorkers uint, governor *Governor) *Dispatcher {
 	return &Dispatcher{
 		maxWorkers: maxWorkers,
 		workerPool: make(chan TaskQueue, maxWorkers),
 		taskQueue:  taskQueue,
 		quitCh:     make(chan struct{}),
+		governor:   governor,
 		debug:      false,
 	}
 }
@@ -104,7 +122,9 @@ func (d *Dispatcher) Stop() {
 	if d.debug {
 		log.Println("the dispatcher is stopping")
 	}
-	atomic.StoreInt32(&d.shutdown, 1)
+	d.lock.Lock()
+	d.shutdown = true
+	d.lock.Unlock()
 	d.wg.Wait()
 	close(d.quitCh)
 
@@ -114,23 +134,36 @@ func (d *Dispatcher) Stop() {
 }
 
 // Dispatch queues a new task into the task queue.
-func (d *Dispatcher) Dispatch(task Task) {
-	if atomic.LoadInt32(&d.shutdown) != 0 {
-		return // pool is stopped
+// A stopped pool does not accept new tasks and ErrStopped is returned.
+func (d *Dispatcher) Dispatch(task Task) error {
+	d.lock.RLock()
+	if d.shutdown {
+		d.lock.RUnlock()
+		return ErrStopped
 	}
+	d.wg.Add(1)
+	d.lock.RUnlock()
 
 	if d.debug {
 		log.Printf("dispatch a new task %#v", task.ID)
 	}
 
-	d.wg.Add(1)
 	d.taskQueue <- Task{
 		ID: task.ID,
 		Handler: func() {
 			defer d.wg.Done()
+			// the memory is reserved for the execution only
+			time.Sleep(task.Delay)
+			if d.governor != nil {
+				d.governor.Acquire(task.Memory)
+				defer d.governor.Release(task.Memory)
+			}
 			task.Handler()
 		},
+		Memory: task.Memory,
+		Delay:  task.Delay,
 	}
+	return nil
 }
 
 func (d *Dispatcher) dispatch() {
@@ -73,3 +73,14 @@ func BenchmarkPoolDispatch(b *testing.B) {
 	wg.Wait()
 	dispatcher.Stop()
 }
+
+func TestDispatchStopped(t *testing.T) {
+	assert := assert.New(t)
+
+	dispatcher := NewDispatcher(make(chan Task, 1), 1)
+	dispatcher.Run()
+	dispatcher.Stop()
+
+	err := dispatcher.Dispatch(Task{ID: 1, Handler: func() {}})
+	assert.Equal(ErrStopped, err)
+}
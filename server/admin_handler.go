@@ -0,0 +1,139 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/health"
+)
+
+type adminHandler struct {
+	svc       hasher.Service
+	healthSvc health.Service
+	// token is the bearer token of the admin requests, empty if the admin endpoints are disabled
+	token string
+}
+
+// authorize allows only the requests with the admin bearer token to the next handler
+func (h adminHandler) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if h.token == "" {
+			http.Error(w, "Admin endpoints are disabled", http.StatusForbidden)
+			return
+		}
+
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), []byte(h.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Invalid admin token", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+type calibrationResponse struct {
+	Algorithm string `json:"algorithm"`
+	Params    string `json:"params"`
+	Duration  int64  `json:"duration"`
+	Memory    uint64 `json:"memory"`
+}
+
+func (h adminHandler) calibration(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	resp := []calibrationResponse{}
+	for _, c := range h.svc.Calibration() {
+		resp = append(resp, calibrationResponse{
+			Algorithm: string(c.Algorithm),
+			Params:    c.Params,
+			Duration:  c.Duration.Microseconds(),
+			Memory:    c.Memory,
+		})
+	}
+
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, "Cannot encode calibration", http.StatusInternalServerError)
+	}
+}
+
+type wrapResponse struct {
+	Running bool `json:"running"`
+	Wrapped int  `json:"wrapped"`
+	Skipped int  `json:"skipped"`
+}
+
+// startWrap starts the background wrap migration, the progress is reported by wrapProgress
+func (h adminHandler) startWrap(w http.ResponseWriter, r *http.Request) {
+	if err := h.svc.StartWrap(); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	h.writeWrapProgress(w, http.StatusAccepted)
+}
+
+func (h adminHandler) wrapProgress(w http.ResponseWriter, r *http.Request) {
+	h.writeWrapProgress(w, http.StatusOK)
+}
+
+func (h adminHandler) writeWrapProgress(w http.ResponseWriter, status int) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+
+	progress := h.svc.WrapProgress()
+	if err := json.NewEncoder(w).Encode(wrapResponse{
+		Running: progress.Running,
+		Wrapped: progress.Wrapped,
+		Skipped: progress.Skipped,
+	}); err != nil {
+		http.Error(w, "Cannot encode wrap progress", http.StatusInternalServerError)
+	}
+}
+
+type selfTestResponse struct {
+	Passed  bool                     `json:"passed"`
+	Results []selfTestResultResponse `json:"results"`
+}
+
+type selfTestResultResponse struct {
+	Algorithm string `json:"algorithm"`
+	Vectors   int    `json:"vectors"`
+	Passed    bool   `json:"passed"`
+	Error     string `json:"error,omitempty"`
+}
+
+// selfTest runs the known-answer tests on demand, a failure marks the server unhealthy until a self-test passes
+func (h adminHandler) selfTest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+
+	report := h.svc.SelfTest()
+	resp := selfTestResponse{
+		Passed:  report.Passed(),
+		Results: []selfTestResultResponse{},
+	}
+	for _, result := range report.Results {
+		rr := selfTestResultResponse{
+			Algorithm: string(result.Algorithm),
+			Vectors:   result.Vectors,
+			Passed:    result.Err == nil,
+		}
+		if result.Err != nil {
+			rr.Error = result.Err.Error()
+		}
+		resp.Results = append(resp.Results, rr)
+	}
+
+	if !resp.Passed {
+		h.healthSvc.Fail(health.ConditionSelfTest)
+		w.WriteHeader(http.StatusInternalServerError)
+	} else {
+		h.healthSvc.Recover(health.ConditionSelfTest)
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		http.Error(w, "Cannot encode self-test report", http.StatusInternalServerError)
+	}
+}
@@ -1,14 +1,62 @@
 package config
 
 import (
+	"encoding/base64"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"regexp"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/plar/hash/domain"
+)
+
+// minPepperSecretLen is the minimum length of a pepper secret in bytes
+const minPepperSecretLen = 16
+
+// minAdminTokenLen is the minimum length of the admin token
+const minAdminTokenLen = 16
+
+var pepperKeyIDRegex = regexp.MustCompile("^[A-Za-z0-9_-]+$")
+
+var pipelineNameRegex = regexp.MustCompile("^[A-Za-z0-9_-]+$")
+
+var policyNameRegex = regexp.MustCompile("^[A-Za-z0-9_-]+$")
+
+var policyParamsRegex = regexp.MustCompile("^[a-z0-9-]+=[a-z0-9-]+(,[a-z0-9-]+=[a-z0-9-]+)*$")
+
+// Reserved policy arm names
+const (
+	// PolicyArmDefault is the arm of the default algorithm or pipeline
+	PolicyArmDefault = "default"
+	// PolicyArmOverride is the arm of explicitly requested algorithms and pipelines
+	PolicyArmOverride = "override"
 )
 
+// PepperKey is a server-side secret mixed into passwords before hashing
+type PepperKey struct {
+	ID     string
+	Secret []byte
+}
+
+// PolicyArm is a named algorithm with optional cost parameters of the rollout policy
+type PolicyArm struct {
+	Name      string
+	Algorithm domain.Algorithm
+	// Params are the algorithm parameters in the PHC string format, empty for the configured ones
+	Params string
+}
+
+// PolicyRollout assigns a percentage of new hashes to a policy arm
+type PolicyRollout struct {
+	Arm     string
+	Percent int
+}
+
 // Config defines Server configuration interface
 type Config interface {
 	Address() string
@@ -18,10 +66,101 @@ type Config interface {
 	WriteTimeout() time.Duration
 	IdleTimeout() time.Duration
 	ShutDownTimeout() time.Duration
+	// AdminToken is the bearer token of the /admin endpoints, empty if the endpoints are disabled
+	AdminToken() string
 
 	TaskDelay() time.Duration
 	TotalWorkers() uint
 	QueueSize() uint
+
+	Algorithm() domain.Algorithm
+	AllowedAlgorithms() []domain.Algorithm
+	Argon2Memory() uint32
+	Argon2Iterations() uint32
+	Argon2Lanes() uint8
+	BcryptCost() int
+	BcryptPreHash() bool
+	ScryptN() int
+	ScryptR() int
+	ScryptP() int
+	PBKDF2Iterations() int
+	PBKDF2SaltLen() int
+	PBKDF2KeyLen() int
+
+	// SCRAMIterations is the number of PBKDF2 iterations of SCRAM verifiers
+	SCRAMIterations() int
+
+	// PepperKeys returns the pepper keys from the oldest to the newest,
+	// the newest key is used for new hashes.
+	PepperKeys() []PepperKey
+
+	// Pipelines returns the hashing pipelines by name
+	Pipelines() map[string]domain.Pipeline
+	// Pipeline is the name of the default pipeline, empty if the default algorithm is used as is
+	Pipeline() string
+
+	// PasswordMinLength is the minimum password length in characters
+	PasswordMinLength() int
+	// PasswordMaxLength is the maximum password length in characters, zero if unlimited
+	PasswordMaxLength() int
+	// PasswordMinClasses is the minimum number of character classes: lower case, upper case, digits and symbols
+	PasswordMinClasses() int
+	// PasswordDenylist returns the denied passwords
+	PasswordDenylist() []string
+	// PasswordMinEntropy is the minimum estimated password entropy in bits
+	PasswordMinEntropy() int
+	// BreachDir is the directory of the HIBP range files, empty if not used
+	BreachDir() string
+	// BreachFilter is the path of the breach filter, empty if not used
+	BreachFilter() string
+
+	// Normalization is the Unicode normalization mode of new hashes, empty if passwords are hashed as is
+	Normalization() domain.Stage
+
+	// PolicyArms returns the arms of the rollout policy
+	PolicyArms() []PolicyArm
+	// PolicyRollout returns the percentages of new hashes assigned to the policy arms,
+	// the remaining hashes use the default arm
+	PolicyRollout() []PolicyRollout
+	// PolicyTenants returns the policy arms by tenant
+	PolicyTenants() map[string]string
+
+	// RehashOnVerify enables upgrading of outdated hashes after a successful verification
+	RehashOnVerify() bool
+
+	// ImportMaxRounds is the maximum number of rounds of imported sha512-crypt hashes
+	ImportMaxRounds() int
+	// ImportMaxBcryptCost is the maximum cost of imported bcrypt hashes
+	ImportMaxBcryptCost() int
+
+	// MemoryBudget is the total memory budget of the hash pool workers in KiB, zero disables the governor
+	MemoryBudget() uint64
+
+	// Calibrate enables the cost parameters calibration at startup
+	Calibrate() bool
+	// CalibrationTarget is the target time per hash
+	CalibrationTarget() time.Duration
+	// CalibrationMaxMemory is the memory ceiling per hash in KiB
+	CalibrationMaxMemory() uint32
+
+	// ProbeInterval is the interval of the synthetic end-to-end probe, zero disables the probe
+	ProbeInterval() time.Duration
+	// ProbeTimeout is the maximum time to wait for the probe hash
+	ProbeTimeout() time.Duration
+	// ProbeFailures is the number of consecutive probe failures which mark the service unhealthy
+	ProbeFailures() int
+
+	// SRPGroup is the size in bits of the default RFC 5054 group of new SRP verifiers
+	SRPGroup() int
+	// SRPHandshakeTTL is the maximum time between the SRP login challenge and the client proof
+	SRPHandshakeTTL() time.Duration
+	// SRPMaxHandshakes is the maximum number of pending SRP login handshakes
+	SRPMaxHandshakes() int
+
+	// KDFEnabled enables the HKDF key derivation endpoint
+	KDFEnabled() bool
+	// KDFMaxLength is the maximum length of a derived key in bytes
+	KDFMaxLength() int
 }
 
 // DefaultConfig defines default server configuration
@@ -57,6 +196,10 @@ func (c *DefaultConfig) ShutDownTimeout() time.Duration {
 	return 30 * time.Second
 }
 
+func (c *DefaultConfig) AdminToken() string {
+	return ""
+}
+
 func (c *DefaultConfig) TaskDelay() time.Duration {
 	return 5 * time.Second
 }
@@ -69,6 +212,186 @@ func (c *DefaultConfig) QueueSize() uint {
 	return 10000
 }
 
+func (c *DefaultConfig) Algorithm() domain.Algorithm {
+	return domain.AlgorithmSHA512
+}
+
+// AllowedAlgorithms are all algorithms except SCRAM,
+// SCRAM verifiers cannot be peppered and are allowed on demand only
+func (c *DefaultConfig) AllowedAlgorithms() []domain.Algorithm {
+	var allowed []domain.Algorithm
+	for _, algorithm := range domain.Algorithms() {
+		if !algorithm.IsSCRAM() {
+			allowed = append(allowed, algorithm)
+		}
+	}
+	return allowed
+}
+
+func (c *DefaultConfig) Argon2Memory() uint32 {
+	return 64 * 1024
+}
+
+func (c *DefaultConfig) Argon2Iterations() uint32 {
+	return 1
+}
+
+func (c *DefaultConfig) Argon2Lanes() uint8 {
+	return 2
+}
+
+func (c *DefaultConfig) BcryptCost() int {
+	return 10
+}
+
+func (c *DefaultConfig) BcryptPreHash() bool {
+	return false
+}
+
+func (c *DefaultConfig) ScryptN() int {
+	return 32768
+}
+
+func (c *DefaultConfig) ScryptR() int {
+	return 8
+}
+
+func (c *DefaultConfig) ScryptP() int {
+	return 1
+}
+
+func (c *DefaultConfig) PBKDF2Iterations() int {
+	return 310000
+}
+
+func (c *DefaultConfig) PBKDF2SaltLen() int {
+	return 16
+}
+
+func (c *DefaultConfig) SCRAMIterations() int {
+	return 4096
+}
+
+func (c *DefaultConfig) PBKDF2KeyLen() int {
+	return 32
+}
+
+func (c *DefaultConfig) PepperKeys() []PepperKey {
+	return nil
+}
+
+func (c *DefaultConfig) Pipelines() map[string]domain.Pipeline {
+	return nil
+}
+
+func (c *DefaultConfig) Pipeline() string {
+	return ""
+}
+
+func (c *DefaultConfig) PasswordMinLength() int {
+	return 1
+}
+
+func (c *DefaultConfig) PasswordMaxLength() int {
+	return 0
+}
+
+func (c *DefaultConfig) PasswordMinClasses() int {
+	return 0
+}
+
+func (c *DefaultConfig) PasswordDenylist() []string {
+	return nil
+}
+
+func (c *DefaultConfig) PasswordMinEntropy() int {
+	return 0
+}
+
+func (c *DefaultConfig) BreachDir() string {
+	return ""
+}
+
+func (c *DefaultConfig) BreachFilter() string {
+	return ""
+}
+
+func (c *DefaultConfig) Normalization() domain.Stage {
+	return ""
+}
+
+func (c *DefaultConfig) PolicyArms() []PolicyArm {
+	return nil
+}
+
+func (c *DefaultConfig) PolicyRollout() []PolicyRollout {
+	return nil
+}
+
+func (c *DefaultConfig) PolicyTenants() map[string]string {
+	return nil
+}
+
+func (c *DefaultConfig) RehashOnVerify() bool {
+	return true
+}
+
+func (c *DefaultConfig) ImportMaxRounds() int {
+	return 500000
+}
+
+func (c *DefaultConfig) ImportMaxBcryptCost() int {
+	return 14
+}
+
+func (c *DefaultConfig) MemoryBudget() uint64 {
+	return 0
+}
+
+func (c *DefaultConfig) Calibrate() bool {
+	return false
+}
+
+func (c *DefaultConfig) CalibrationTarget() time.Duration {
+	return 250 * time.Millisecond
+}
+
+func (c *DefaultConfig) CalibrationMaxMemory() uint32 {
+	return 256 * 1024
+}
+
+func (c *DefaultConfig) ProbeInterval() time.Duration {
+	return 30 * time.Second
+}
+
+func (c *DefaultConfig) ProbeTimeout() time.Duration {
+	return 20 * time.Second
+}
+
+func (c *DefaultConfig) ProbeFailures() int {
+	return 3
+}
+
+func (c *DefaultConfig) SRPGroup() int {
+	return 2048
+}
+
+func (c *DefaultConfig) SRPHandshakeTTL() time.Duration {
+	return 60 * time.Second
+}
+
+func (c *DefaultConfig) SRPMaxHandshakes() int {
+	return 10000
+}
+
+func (c *DefaultConfig) KDFEnabled() bool {
+	return false
+}
+
+func (c *DefaultConfig) KDFMaxLength() int {
+	return 64
+}
+
 type config struct {
 	addr            string
 	port            uint
@@ -76,10 +399,56 @@ type config struct {
 	writeTimeout    time.Duration
 	idleTimeout     time.Duration
 	shutDownTimeout time.Duration
+	adminToken      string
 
 	taskDelay    time.Duration
 	totalWorkers uint
 	queueSize    uint
+
+	algorithm          domain.Algorithm
+	allowedAlgorithms  []domain.Algorithm
+	argon2Memory       uint32
+	argon2Iterations   uint32
+	argon2Lanes        uint8
+	bcryptCost         int
+	bcryptPreHash      bool
+	scryptN            int
+	scryptR            int
+	scryptP            int
+	pbkdf2Iterations   int
+	pbkdf2SaltLen      int
+	pbkdf2KeyLen       int
+	scramIterations    int
+	pepperKeys         []PepperKey
+	pipelines          map[string]domain.Pipeline
+	pipeline           string
+	passwordMinLength  int
+	passwordMaxLength  int
+	passwordMinClasses int
+	passwordDenylist   []string
+	passwordMinEntropy int
+	breachDir          string
+	breachFilter       string
+	normalization      domain.Stage
+	policyArms         []PolicyArm
+	policyRollout      []PolicyRollout
+	policyTenants      map[string]string
+	rehashOnVerify     bool
+	importMaxRounds    int
+	importMaxBcrypt    int
+
+	memoryBudget         uint64
+	calibrate            bool
+	calibrationTarget    time.Duration
+	calibrationMaxMemory uint32
+	probeInterval        time.Duration
+	probeTimeout         time.Duration
+	probeFailures        int
+	srpGroup             int
+	srpHandshakeTTL      time.Duration
+	srpMaxHandshakes     int
+	kdfEnabled           bool
+	kdfMaxLength         int
 }
 
 func parseTimeout(timeout string) (time.Duration, error) {
@@ -102,6 +471,198 @@ func parseEnvTimeout(def time.Duration, envName string) (time.Duration, error) {
 	return def, nil
 }
 
+func parseEnvUint(def uint64, envName string, bitSize int) (uint64, error) {
+	raw, ok := os.LookupEnv(envName)
+	if ok {
+		parsed, err := strconv.ParseUint(raw, 10, bitSize)
+		if err != nil {
+			return 0, fmt.Errorf("Cannot parse %v '%v': %w", envName, raw, err)
+		}
+		if parsed < 1 {
+			return 0, fmt.Errorf("Invalid %v value '%v', should be greater than 0", envName, parsed)
+		}
+		return parsed, nil
+	}
+	return def, nil
+}
+
+// parseEnvUintOrZero parses a non-negative integer, zero usually disables the feature
+func parseEnvUintOrZero(def uint64, envName string, bitSize int) (uint64, error) {
+	raw, ok := os.LookupEnv(envName)
+	if ok {
+		parsed, err := strconv.ParseUint(raw, 10, bitSize)
+		if err != nil {
+			return 0, fmt.Errorf("Cannot parse %v '%v': %w", envName, raw, err)
+		}
+		return parsed, nil
+	}
+	return def, nil
+}
+
+func parseEnvBool(def bool, envName string) (bool, error) {
+	raw, ok := os.LookupEnv(envName)
+	if ok {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return false, fmt.Errorf("Cannot parse %v '%v': %w", envName, raw, err)
+		}
+		return parsed, nil
+	}
+	return def, nil
+}
+
+// parsePepperKeys parses comma separated pepper keys in the <id>:<base64 secret> format
+func parsePepperKeys(raw string) ([]PepperKey, error) {
+	var keys []PepperKey
+	seen := make(map[string]bool)
+	for _, rawKey := range strings.Split(raw, ",") {
+		kv := strings.SplitN(rawKey, ":", 2)
+		if len(kv) != 2 || !pepperKeyIDRegex.MatchString(kv[0]) {
+			return nil, fmt.Errorf("Invalid HASH_PEPPER_KEYS key '%v', expected <id>:<base64 secret>", kv[0])
+		}
+		if seen[kv[0]] {
+			return nil, fmt.Errorf("Invalid HASH_PEPPER_KEYS, duplicate key ID '%v'", kv[0])
+		}
+		secret, err := base64.StdEncoding.DecodeString(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("Cannot parse HASH_PEPPER_KEYS secret of key '%v': %w", kv[0], err)
+		}
+		if len(secret) < minPepperSecretLen {
+			return nil, fmt.Errorf("Invalid HASH_PEPPER_KEYS secret of key '%v', should be at least %v bytes", kv[0], minPepperSecretLen)
+		}
+		seen[kv[0]] = true
+		keys = append(keys, PepperKey{ID: kv[0], Secret: secret})
+	}
+	return keys, nil
+}
+
+// parsePipelines parses comma separated pipelines in the <name>=<stage>+<stage>... format
+func parsePipelines(raw string) (map[string]domain.Pipeline, error) {
+	pipelines := make(map[string]domain.Pipeline)
+	for _, rawPipeline := range strings.Split(raw, ",") {
+		kv := strings.SplitN(rawPipeline, "=", 2)
+		if len(kv) != 2 || !pipelineNameRegex.MatchString(kv[0]) {
+			return nil, fmt.Errorf("Invalid HASH_PIPELINES pipeline '%v', expected <name>=<stage>+<stage>...", kv[0])
+		}
+		if _, ok := pipelines[kv[0]]; ok {
+			return nil, fmt.Errorf("Invalid HASH_PIPELINES, duplicate pipeline '%v'", kv[0])
+		}
+		pipeline, err := domain.ParsePipeline(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("Cannot parse HASH_PIPELINES pipeline '%v': %w", kv[0], err)
+		}
+		pipelines[kv[0]] = pipeline
+	}
+	return pipelines, nil
+}
+
+// readDenylist reads denied passwords from the file, one password per line.
+// Empty lines and lines starting with # are ignored.
+func readDenylist(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read HASH_PASSWORD_DENYLIST '%v': %w", path, err)
+	}
+
+	var denylist []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		denylist = append(denylist, line)
+	}
+	return denylist, nil
+}
+
+// parseBreachPaths parses HASH_BREACH_DIR and HASH_BREACH_FILTER, the corpus is loaded by the caller
+func parseBreachPaths(defDir, defFilter string) (string, string, error) {
+	dir, filter := defDir, defFilter
+	if raw, ok := os.LookupEnv("HASH_BREACH_DIR"); ok {
+		dir = raw
+	}
+	if raw, ok := os.LookupEnv("HASH_BREACH_FILTER"); ok {
+		filter = raw
+	}
+	if dir != "" && filter != "" {
+		return "", "", fmt.Errorf("HASH_BREACH_DIR and HASH_BREACH_FILTER are mutually exclusive")
+	}
+	return dir, filter, nil
+}
+
+// parsePolicyArms parses semicolon separated policy arms in the <name>=<algorithm>[:<params>] format
+func parsePolicyArms(raw string) ([]PolicyArm, error) {
+	var arms []PolicyArm
+	seen := make(map[string]bool)
+	for _, rawArm := range strings.Split(raw, ";") {
+		kv := strings.SplitN(rawArm, "=", 2)
+		if len(kv) != 2 || !policyNameRegex.MatchString(kv[0]) {
+			return nil, fmt.Errorf("Invalid HASH_POLICY_ARMS arm '%v', expected <name>=<algorithm>[:<params>]", kv[0])
+		}
+		if kv[0] == PolicyArmDefault || kv[0] == PolicyArmOverride {
+			return nil, fmt.Errorf("Invalid HASH_POLICY_ARMS, the arm name '%v' is reserved", kv[0])
+		}
+		if seen[kv[0]] {
+			return nil, fmt.Errorf("Invalid HASH_POLICY_ARMS, duplicate arm '%v'", kv[0])
+		}
+
+		arm := PolicyArm{Name: kv[0]}
+		target := strings.SplitN(kv[1], ":", 2)
+		arm.Algorithm = domain.Algorithm(target[0])
+		if !arm.Algorithm.IsValid() {
+			return nil, fmt.Errorf("Invalid HASH_POLICY_ARMS algorithm '%v' of arm '%v'", target[0], kv[0])
+		}
+		if len(target) == 2 {
+			if !policyParamsRegex.MatchString(target[1]) {
+				return nil, fmt.Errorf("Invalid HASH_POLICY_ARMS params '%v' of arm '%v'", target[1], kv[0])
+			}
+			arm.Params = target[1]
+		}
+
+		seen[kv[0]] = true
+		arms = append(arms, arm)
+	}
+	return arms, nil
+}
+
+// parsePolicyRollout parses comma separated percentages in the <arm>:<percent> format
+func parsePolicyRollout(raw string) ([]PolicyRollout, error) {
+	var rollout []PolicyRollout
+	total := 0
+	for _, rawRollout := range strings.Split(raw, ",") {
+		kv := strings.SplitN(rawRollout, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid HASH_POLICY_ROLLOUT value '%v', expected <arm>:<percent>", rawRollout)
+		}
+		percent, err := strconv.ParseUint(kv[1], 10, 8)
+		if err != nil || percent > 100 {
+			return nil, fmt.Errorf("Invalid HASH_POLICY_ROLLOUT percent '%v' of arm '%v'", kv[1], kv[0])
+		}
+		total += int(percent)
+		rollout = append(rollout, PolicyRollout{Arm: kv[0], Percent: int(percent)})
+	}
+	if total > 100 {
+		return nil, fmt.Errorf("Invalid HASH_POLICY_ROLLOUT, the total percent %v is greater than 100", total)
+	}
+	return rollout, nil
+}
+
+// parsePolicyTenants parses comma separated tenants in the <tenant>=<arm> format
+func parsePolicyTenants(raw string) (map[string]string, error) {
+	tenants := make(map[string]string)
+	for _, rawTenant := range strings.Split(raw, ",") {
+		kv := strings.SplitN(rawTenant, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("Invalid HASH_POLICY_TENANTS value '%v', expected <tenant>=<arm>", rawTenant)
+		}
+		if _, ok := tenants[kv[0]]; ok {
+			return nil, fmt.Errorf("Invalid HASH_POLICY_TENANTS, duplicate tenant '%v'", kv[0])
+		}
+		tenants[kv[0]] = kv[1]
+	}
+	return tenants, nil
+}
+
 // New handles Server configuration from env variables
 func New() (Config, error) {
 	c := &config{}
@@ -116,6 +677,45 @@ func New() (Config, error) {
 		return nil, err
 	}
 
+	if !c.isAllowed(c.algorithm) {
+		return nil, fmt.Errorf("The default algorithm '%v' is not in the allowed algorithms %v", c.algorithm, c.allowedAlgorithms)
+	}
+
+	for name, pipeline := range c.pipelines {
+		if !c.isAllowed(pipeline.KDF) {
+			return nil, fmt.Errorf("The KDF '%v' of pipeline '%v' is not in the allowed algorithms %v", pipeline.KDF, name, c.allowedAlgorithms)
+		}
+		if pipeline.HMAC != "" && len(c.pepperKeys) == 0 {
+			return nil, fmt.Errorf("The HMAC stage of pipeline '%v' requires HASH_PEPPER_KEYS", name)
+		}
+	}
+	if _, ok := c.pipelines[c.pipeline]; c.pipeline != "" && !ok {
+		return nil, fmt.Errorf("The default pipeline '%v' is not defined in HASH_PIPELINES", c.pipeline)
+	}
+
+	arms := map[string]bool{PolicyArmDefault: true}
+	for _, arm := range c.policyArms {
+		if !c.isAllowed(arm.Algorithm) {
+			return nil, fmt.Errorf("The algorithm '%v' of policy arm '%v' is not in the allowed algorithms %v", arm.Algorithm, arm.Name, c.allowedAlgorithms)
+		}
+		arms[arm.Name] = true
+	}
+	for _, rollout := range c.policyRollout {
+		if !arms[rollout.Arm] {
+			return nil, fmt.Errorf("The policy arm '%v' of HASH_POLICY_ROLLOUT is not defined in HASH_POLICY_ARMS", rollout.Arm)
+		}
+	}
+	for tenant, arm := range c.policyTenants {
+		if !arms[arm] {
+			return nil, fmt.Errorf("The policy arm '%v' of tenant '%v' is not defined in HASH_POLICY_ARMS", arm, tenant)
+		}
+	}
+
+	// the probe hash is delayed as any other hash
+	if c.probeInterval > 0 && c.probeTimeout <= c.taskDelay {
+		return nil, fmt.Errorf("HASH_PROBE_TIMEOUT %v should be greater than the task delay %v", c.probeTimeout, c.taskDelay)
+	}
+
 	return c, nil
 }
 
@@ -165,6 +765,14 @@ func (c *config) parseEnv() error {
 		return err
 	}
 
+	c.adminToken = def.AdminToken()
+	if adminToken, ok := os.LookupEnv("HASH_ADMIN_TOKEN"); ok {
+		if len(adminToken) < minAdminTokenLen {
+			return fmt.Errorf("Invalid HASH_ADMIN_TOKEN value, should be at least %v characters", minAdminTokenLen)
+		}
+		c.adminToken = adminToken
+	}
+
 	c.taskDelay, err = parseEnvTimeout(def.TaskDelay(), "HASH_TASK_DELAY")
 	if err != nil {
 		return err
@@ -196,6 +804,314 @@ func (c *config) parseEnv() error {
 		c.queueSize = uint(queueSize)
 	}
 
+	c.algorithm = def.Algorithm()
+	rawAlgorithm, ok := os.LookupEnv("HASH_ALGORITHM")
+	if ok {
+		c.algorithm = domain.Algorithm(rawAlgorithm)
+		if !c.algorithm.IsValid() {
+			return fmt.Errorf("Invalid HASH_ALGORITHM value '%v'", rawAlgorithm)
+		}
+	}
+
+	c.allowedAlgorithms = def.AllowedAlgorithms()
+	rawAllowedAlgorithms, ok := os.LookupEnv("HASH_ALLOWED_ALGORITHMS")
+	if ok {
+		c.allowedAlgorithms = nil
+		for _, rawAlgorithm := range strings.Split(rawAllowedAlgorithms, ",") {
+			algorithm := domain.Algorithm(strings.TrimSpace(rawAlgorithm))
+			if !algorithm.IsValid() {
+				return fmt.Errorf("Invalid HASH_ALLOWED_ALGORITHMS value '%v'", rawAlgorithm)
+			}
+			c.allowedAlgorithms = append(c.allowedAlgorithms, algorithm)
+		}
+	}
+
+	argon2Memory, err := parseEnvUint(uint64(def.Argon2Memory()), "HASH_ARGON2_MEMORY", 32)
+	if err != nil {
+		return err
+	}
+	c.argon2Memory = uint32(argon2Memory)
+
+	argon2Iterations, err := parseEnvUint(uint64(def.Argon2Iterations()), "HASH_ARGON2_ITERATIONS", 32)
+	if err != nil {
+		return err
+	}
+	c.argon2Iterations = uint32(argon2Iterations)
+
+	argon2Lanes, err := parseEnvUint(uint64(def.Argon2Lanes()), "HASH_ARGON2_LANES", 8)
+	if err != nil {
+		return err
+	}
+	c.argon2Lanes = uint8(argon2Lanes)
+
+	bcryptCost, err := parseEnvUint(uint64(def.BcryptCost()), "HASH_BCRYPT_COST", 8)
+	if err != nil {
+		return err
+	}
+	if bcryptCost < 4 || bcryptCost > 31 {
+		return fmt.Errorf("Invalid HASH_BCRYPT_COST value '%v', valid range [4..31]", bcryptCost)
+	}
+	c.bcryptCost = int(bcryptCost)
+
+	c.bcryptPreHash, err = parseEnvBool(def.BcryptPreHash(), "HASH_BCRYPT_PREHASH")
+	if err != nil {
+		return err
+	}
+
+	scryptN, err := parseEnvUint(uint64(def.ScryptN()), "HASH_SCRYPT_N", 31)
+	if err != nil {
+		return err
+	}
+	if scryptN < 2 || scryptN&(scryptN-1) != 0 {
+		return fmt.Errorf("Invalid HASH_SCRYPT_N value '%v', should be a power of two greater than 1", scryptN)
+	}
+	c.scryptN = int(scryptN)
+
+	scryptR, err := parseEnvUint(uint64(def.ScryptR()), "HASH_SCRYPT_R", 31)
+	if err != nil {
+		return err
+	}
+	c.scryptR = int(scryptR)
+
+	scryptP, err := parseEnvUint(uint64(def.ScryptP()), "HASH_SCRYPT_P", 31)
+	if err != nil {
+		return err
+	}
+	c.scryptP = int(scryptP)
+
+	pbkdf2Iterations, err := parseEnvUint(uint64(def.PBKDF2Iterations()), "HASH_PBKDF2_ITERATIONS", 31)
+	if err != nil {
+		return err
+	}
+	c.pbkdf2Iterations = int(pbkdf2Iterations)
+
+	pbkdf2SaltLen, err := parseEnvUint(uint64(def.PBKDF2SaltLen()), "HASH_PBKDF2_SALT_LEN", 16)
+	if err != nil {
+		return err
+	}
+	c.pbkdf2SaltLen = int(pbkdf2SaltLen)
+
+	pbkdf2KeyLen, err := parseEnvUint(uint64(def.PBKDF2KeyLen()), "HASH_PBKDF2_KEY_LEN", 16)
+	if err != nil {
+		return err
+	}
+	c.pbkdf2KeyLen = int(pbkdf2KeyLen)
+
+	scramIterations, err := parseEnvUint(uint64(def.SCRAMIterations()), "HASH_SCRAM_ITERATIONS", 31)
+	if err != nil {
+		return err
+	}
+	c.scramIterations = int(scramIterations)
+
+	c.pepperKeys = def.PepperKeys()
+	rawPepperKeys, ok := os.LookupEnv("HASH_PEPPER_KEYS")
+	if ok && rawPepperKeys != "" {
+		c.pepperKeys, err = parsePepperKeys(rawPepperKeys)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.pipelines = def.Pipelines()
+	rawPipelines, ok := os.LookupEnv("HASH_PIPELINES")
+	if ok && rawPipelines != "" {
+		c.pipelines, err = parsePipelines(rawPipelines)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.pipeline = def.Pipeline()
+	rawPipeline, ok := os.LookupEnv("HASH_PIPELINE")
+	if ok {
+		c.pipeline = rawPipeline
+	}
+
+	passwordMinLength, err := parseEnvUint(uint64(def.PasswordMinLength()), "HASH_PASSWORD_MIN_LENGTH", 31)
+	if err != nil {
+		return err
+	}
+	c.passwordMinLength = int(passwordMinLength)
+
+	passwordMaxLength, err := parseEnvUintOrZero(uint64(def.PasswordMaxLength()), "HASH_PASSWORD_MAX_LENGTH", 31)
+	if err != nil {
+		return err
+	}
+	if passwordMaxLength > 0 && int(passwordMaxLength) < c.passwordMinLength {
+		return fmt.Errorf("Invalid HASH_PASSWORD_MAX_LENGTH value '%v', should be greater or equal to HASH_PASSWORD_MIN_LENGTH", passwordMaxLength)
+	}
+	c.passwordMaxLength = int(passwordMaxLength)
+
+	passwordMinClasses, err := parseEnvUintOrZero(uint64(def.PasswordMinClasses()), "HASH_PASSWORD_MIN_CLASSES", 8)
+	if err != nil {
+		return err
+	}
+	if passwordMinClasses > 4 {
+		return fmt.Errorf("Invalid HASH_PASSWORD_MIN_CLASSES value '%v', valid range [0..4]", passwordMinClasses)
+	}
+	c.passwordMinClasses = int(passwordMinClasses)
+
+	c.passwordDenylist = def.PasswordDenylist()
+	rawPasswordDenylist, ok := os.LookupEnv("HASH_PASSWORD_DENYLIST")
+	if ok && rawPasswordDenylist != "" {
+		c.passwordDenylist, err = readDenylist(rawPasswordDenylist)
+		if err != nil {
+			return err
+		}
+	}
+
+	passwordMinEntropy, err := parseEnvUintOrZero(uint64(def.PasswordMinEntropy()), "HASH_PASSWORD_MIN_ENTROPY", 16)
+	if err != nil {
+		return err
+	}
+	c.passwordMinEntropy = int(passwordMinEntropy)
+
+	c.breachDir, c.breachFilter, err = parseBreachPaths(def.BreachDir(), def.BreachFilter())
+	if err != nil {
+		return err
+	}
+
+	c.normalization = def.Normalization()
+	rawNormalization, ok := os.LookupEnv("HASH_NORMALIZATION")
+	if ok && rawNormalization != "" {
+		c.normalization = domain.Stage(rawNormalization)
+		if !c.normalization.IsNormalization() {
+			return fmt.Errorf("Invalid HASH_NORMALIZATION value '%v'", rawNormalization)
+		}
+	}
+
+	c.policyArms = def.PolicyArms()
+	rawPolicyArms, ok := os.LookupEnv("HASH_POLICY_ARMS")
+	if ok && rawPolicyArms != "" {
+		c.policyArms, err = parsePolicyArms(rawPolicyArms)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.policyRollout = def.PolicyRollout()
+	rawPolicyRollout, ok := os.LookupEnv("HASH_POLICY_ROLLOUT")
+	if ok && rawPolicyRollout != "" {
+		c.policyRollout, err = parsePolicyRollout(rawPolicyRollout)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.policyTenants = def.PolicyTenants()
+	rawPolicyTenants, ok := os.LookupEnv("HASH_POLICY_TENANTS")
+	if ok && rawPolicyTenants != "" {
+		c.policyTenants, err = parsePolicyTenants(rawPolicyTenants)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.rehashOnVerify, err = parseEnvBool(def.RehashOnVerify(), "HASH_REHASH_ON_VERIFY")
+	if err != nil {
+		return err
+	}
+
+	// the limits of the sha512-crypt and bcrypt formats
+	importMaxRounds, err := parseEnvUint(uint64(def.ImportMaxRounds()), "HASH_IMPORT_MAX_ROUNDS", 32)
+	if err != nil {
+		return err
+	}
+	if importMaxRounds < 1000 || importMaxRounds > 999999999 {
+		return fmt.Errorf("Invalid HASH_IMPORT_MAX_ROUNDS value '%v', should be 1000 to 999999999", importMaxRounds)
+	}
+	c.importMaxRounds = int(importMaxRounds)
+
+	importMaxBcrypt, err := parseEnvUint(uint64(def.ImportMaxBcryptCost()), "HASH_IMPORT_MAX_BCRYPT_COST", 8)
+	if err != nil {
+		return err
+	}
+	if importMaxBcrypt < 4 || importMaxBcrypt > 31 {
+		return fmt.Errorf("Invalid HASH_IMPORT_MAX_BCRYPT_COST value '%v', should be 4 to 31", importMaxBcrypt)
+	}
+	c.importMaxBcrypt = int(importMaxBcrypt)
+
+	c.memoryBudget = def.MemoryBudget()
+	rawMemoryBudget, ok := os.LookupEnv("HASH_MEMORY_BUDGET")
+	if ok {
+		c.memoryBudget, err = strconv.ParseUint(rawMemoryBudget, 10, 64)
+		if err != nil {
+			return fmt.Errorf("Cannot parse HASH_MEMORY_BUDGET '%v': %w", rawMemoryBudget, err)
+		}
+	}
+
+	c.calibrate, err = parseEnvBool(def.Calibrate(), "HASH_CALIBRATE")
+	if err != nil {
+		return err
+	}
+
+	calibrationTarget, err := parseEnvUint(uint64(def.CalibrationTarget().Milliseconds()), "HASH_CALIBRATION_TARGET", 32)
+	if err != nil {
+		return err
+	}
+	c.calibrationTarget = time.Duration(calibrationTarget) * time.Millisecond
+
+	calibrationMaxMemory, err := parseEnvUint(uint64(def.CalibrationMaxMemory()), "HASH_CALIBRATION_MAX_MEMORY", 32)
+	if err != nil {
+		return err
+	}
+	c.calibrationMaxMemory = uint32(calibrationMaxMemory)
+
+	probeInterval, err := parseEnvUintOrZero(uint64(def.ProbeInterval().Seconds()), "HASH_PROBE_INTERVAL", 32)
+	if err != nil {
+		return err
+	}
+	c.probeInterval = time.Duration(probeInterval) * time.Second
+
+	probeTimeout, err := parseEnvUint(uint64(def.ProbeTimeout().Seconds()), "HASH_PROBE_TIMEOUT", 32)
+	if err != nil {
+		return err
+	}
+	c.probeTimeout = time.Duration(probeTimeout) * time.Second
+
+	probeFailures, err := parseEnvUint(uint64(def.ProbeFailures()), "HASH_PROBE_FAILURES", 16)
+	if err != nil {
+		return err
+	}
+	c.probeFailures = int(probeFailures)
+
+	srpGroup, err := parseEnvUint(uint64(def.SRPGroup()), "HASH_SRP_GROUP", 16)
+	if err != nil {
+		return err
+	}
+	// the RFC 5054 groups supported by the SRP service
+	if srpGroup != 2048 && srpGroup != 3072 && srpGroup != 4096 {
+		return fmt.Errorf("Invalid HASH_SRP_GROUP value '%v', should be 2048, 3072 or 4096", srpGroup)
+	}
+	c.srpGroup = int(srpGroup)
+
+	srpHandshakeTTL, err := parseEnvUint(uint64(def.SRPHandshakeTTL().Seconds()), "HASH_SRP_HANDSHAKE_TTL", 32)
+	if err != nil {
+		return err
+	}
+	c.srpHandshakeTTL = time.Duration(srpHandshakeTTL) * time.Second
+
+	srpMaxHandshakes, err := parseEnvUint(uint64(def.SRPMaxHandshakes()), "HASH_SRP_MAX_HANDSHAKES", 31)
+	if err != nil {
+		return err
+	}
+	c.srpMaxHandshakes = int(srpMaxHandshakes)
+
+	c.kdfEnabled, err = parseEnvBool(def.KDFEnabled(), "HASH_KDF_ENABLED")
+	if err != nil {
+		return err
+	}
+
+	kdfMaxLength, err := parseEnvUint(uint64(def.KDFMaxLength()), "HASH_KDF_MAX_LENGTH", 16)
+	if err != nil {
+		return err
+	}
+	// RFC 5869 limits the output to 255 blocks of the hash, 255*32 bytes for HKDF-SHA256
+	if kdfMaxLength > 8160 {
+		return fmt.Errorf("Invalid HASH_KDF_MAX_LENGTH value '%v', should be 1 to 8160", kdfMaxLength)
+	}
+	c.kdfMaxLength = int(kdfMaxLength)
+
 	return nil
 }
 
@@ -203,10 +1119,14 @@ func (c *config) parseFlags() error {
 	var taskDelay uint
 	var totalWorkers uint
 	var queueSize uint
+	var algorithm string
+	var pbkdf2Iterations uint
 
 	flag.UintVar(&taskDelay, "delay", uint(c.TaskDelay().Seconds()), "number of seconds to delay hash task")
 	flag.UintVar(&totalWorkers, "workers", uint(c.TotalWorkers()), "number of workers in the hash pool")
 	flag.UintVar(&queueSize, "queue-size", uint(c.QueueSize()), "hash pool queue size")
+	flag.StringVar(&algorithm, "algorithm", string(c.Algorithm()), "default password hashing algorithm")
+	flag.UintVar(&pbkdf2Iterations, "pbkdf2-iterations", uint(c.PBKDF2Iterations()), "number of PBKDF2 iterations")
 	flag.Parse()
 
 	c.taskDelay = time.Duration(taskDelay) * time.Second
@@ -223,6 +1143,16 @@ func (c *config) parseFlags() error {
 		c.queueSize = queueSize
 	}
 
+	c.algorithm = domain.Algorithm(algorithm)
+	if !c.algorithm.IsValid() {
+		return fmt.Errorf("Invalid algorithm '%v'", algorithm)
+	}
+
+	if pbkdf2Iterations == 0 {
+		return fmt.Errorf("Number of PBKDF2 iterations should be greater than 0")
+	}
+	c.pbkdf2Iterations = int(pbkdf2Iterations)
+
 	return nil
 }
 
@@ -254,6 +1184,10 @@ func (c *config) ShutDownTimeout() time.Duration {
 	return c.shutDownTimeout
 }
 
+func (c *config) AdminToken() string {
+	return c.adminToken
+}
+
 func (c *config) TaskDelay() time.Duration {
 	return c.taskDelay
 }
@@ -265,3 +1199,184 @@ func (c *config) TotalWorkers() uint {
 func (c *config) QueueSize() uint {
 	return c.queueSize
 }
+
+func (c *config) Algorithm() domain.Algorithm {
+	return c.algorithm
+}
+
+func (c *config) AllowedAlgorithms() []domain.Algorithm {
+	return c.allowedAlgorithms
+}
+
+func (c *config) isAllowed(algorithm domain.Algorithm) bool {
+	for _, allowed := range c.allowedAlgorithms {
+		if allowed == algorithm {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *config) Argon2Memory() uint32 {
+	return c.argon2Memory
+}
+
+func (c *config) Argon2Iterations() uint32 {
+	return c.argon2Iterations
+}
+
+func (c *config) Argon2Lanes() uint8 {
+	return c.argon2Lanes
+}
+
+func (c *config) BcryptCost() int {
+	return c.bcryptCost
+}
+
+func (c *config) BcryptPreHash() bool {
+	return c.bcryptPreHash
+}
+
+func (c *config) ScryptN() int {
+	return c.scryptN
+}
+
+func (c *config) ScryptR() int {
+	return c.scryptR
+}
+
+func (c *config) ScryptP() int {
+	return c.scryptP
+}
+
+func (c *config) PBKDF2Iterations() int {
+	return c.pbkdf2Iterations
+}
+
+func (c *config) PBKDF2SaltLen() int {
+	return c.pbkdf2SaltLen
+}
+
+func (c *config) SCRAMIterations() int {
+	return c.scramIterations
+}
+
+func (c *config) PBKDF2KeyLen() int {
+	return c.pbkdf2KeyLen
+}
+
+func (c *config) PepperKeys() []PepperKey {
+	return c.pepperKeys
+}
+
+func (c *config) Pipelines() map[string]domain.Pipeline {
+	return c.pipelines
+}
+
+func (c *config) Pipeline() string {
+	return c.pipeline
+}
+
+func (c *config) PasswordMinLength() int {
+	return c.passwordMinLength
+}
+
+func (c *config) PasswordMaxLength() int {
+	return c.passwordMaxLength
+}
+
+func (c *config) PasswordMinClasses() int {
+	return c.passwordMinClasses
+}
+
+func (c *config) PasswordDenylist() []string {
+	return c.passwordDenylist
+}
+
+func (c *config) PasswordMinEntropy() int {
+	return c.passwordMinEntropy
+}
+
+func (c *config) BreachDir() string {
+	return c.breachDir
+}
+
+func (c *config) BreachFilter() string {
+	return c.breachFilter
+}
+
+func (c *config) Normalization() domain.Stage {
+	return c.normalization
+}
+
+func (c *config) PolicyArms() []PolicyArm {
+	return c.policyArms
+}
+
+func (c *config) PolicyRollout() []PolicyRollout {
+	return c.policyRollout
+}
+
+func (c *config) PolicyTenants() map[string]string {
+	return c.policyTenants
+}
+
+func (c *config) RehashOnVerify() bool {
+	return c.rehashOnVerify
+}
+
+func (c *config) ImportMaxRounds() int {
+	return c.importMaxRounds
+}
+
+func (c *config) ImportMaxBcryptCost() int {
+	return c.importMaxBcrypt
+}
+
+func (c *config) MemoryBudget() uint64 {
+	return c.memoryBudget
+}
+
+func (c *config) Calibrate() bool {
+	return c.calibrate
+}
+
+func (c *config) CalibrationTarget() time.Duration {
+	return c.calibrationTarget
+}
+
+func (c *config) CalibrationMaxMemory() uint32 {
+	return c.calibrationMaxMemory
+}
+
+func (c *config) ProbeInterval() time.Duration {
+	return c.probeInterval
+}
+
+func (c *config) ProbeTimeout() time.Duration {
+	return c.probeTimeout
+}
+
+func (c *config) ProbeFailures() int {
+	return c.probeFailures
+}
+
+func (c *config) SRPGroup() int {
+	return c.srpGroup
+}
+
+func (c *config) SRPHandshakeTTL() time.Duration {
+	return c.srpHandshakeTTL
+}
+
+func (c *config) SRPMaxHandshakes() int {
+	return c.srpMaxHandshakes
+}
+
+func (c *config) KDFEnabled() bool {
+	return c.kdfEnabled
+}
+
+func (c *config) KDFMaxLength() int {
+	return c.kdfMaxLength
+}
@@ -1,12 +1,17 @@
 package server
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"mime"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/plar/hash/domain"
 	"github.com/plar/hash/domain/repository"
+	"github.com/plar/hash/infra/pool"
 	"github.com/plar/hash/service/hasher"
 )
 
@@ -14,10 +19,30 @@ type hasherHandler struct {
 	svc hasher.Service
 }
 
+// headerTenant is the caller identity header used by the rollout policy
+const headerTenant = "X-Tenant-ID"
+
 func (h hasherHandler) createHash(w http.ResponseWriter, r *http.Request) {
 	password := r.FormValue("password")
-	hashID, err := h.svc.Create(password)
+	hashID, err := h.svc.Create(password, hasher.CreateOptions{
+		Algorithm: domain.Algorithm(r.FormValue("algorithm")),
+		Pipeline:  r.FormValue("pipeline"),
+		Tenant:    r.Header.Get(headerTenant),
+	})
 	if err != nil {
+		var policyErr *hasher.PasswordPolicyError
+		if errors.As(err, &policyErr) {
+			writePolicyError(w, policyErr)
+			return
+		}
+		if errors.Is(err, hasher.ErrBreachCheck) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if errors.Is(err, pool.ErrStopped) {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
@@ -25,7 +50,88 @@ func (h hasherHandler) createHash(w http.ResponseWriter, r *http.Request) {
 	w.Write(hashID.Bytes())
 }
 
+type policyErrorResponse struct {
+	Error      string                   `json:"error"`
+	Violations []hasher.PolicyViolation `json:"violations"`
+}
+
+// writePolicyError responds with the password policy violations
+func writePolicyError(w http.ResponseWriter, err *hasher.PasswordPolicyError) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(policyErrorResponse{
+		Error:      hasher.ErrPasswordPolicy.Error(),
+		Violations: err.Violations,
+	})
+}
+
+func (h hasherHandler) importHash(w http.ResponseWriter, r *http.Request) {
+	hashID, err := h.svc.Import(r.FormValue("hash"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Write(hashID.Bytes())
+}
+
+// Output formats of the getHash handler
+const (
+	formatBase64 = "base64"
+	formatPHC    = "phc"
+	formatSCRAM  = "scram"
+	formatLDAP   = "ldap"
+	formatDjango = "django"
+	formatASPNet = "aspnet"
+)
+
+// mediaTypeFormatPrefix is the prefix of the Accept header media types of the output formats,
+// e.g. application/vnd.hashsvc.django
+const mediaTypeFormatPrefix = "application/vnd.hashsvc."
+
+// hashFormatters are the output formatters of the getHash handler
+var hashFormatters = map[string]func(domain.Hash) (string, error){
+	formatBase64: func(hash domain.Hash) (string, error) {
+		return string(hash.Base64()), nil
+	},
+	formatPHC: domain.Hash.PHC,
+	formatSCRAM: func(hash domain.Hash) (string, error) {
+		if verifier := hash.SCRAM(); verifier != "" {
+			return verifier, nil
+		}
+		return "", fmt.Errorf("%w '%v': not a SCRAM verifier", domain.ErrFormatMismatch, formatSCRAM)
+	},
+	formatLDAP:   domain.Hash.LDAP,
+	formatDjango: domain.Hash.Django,
+	formatASPNet: domain.Hash.ASPNetIdentity,
+}
+
+// requestedFormat returns the output format of the format query parameter or the Accept header,
+// the query parameter takes precedence. The status is the response status of a bad format:
+// 400 Bad Request for the query parameter and 406 Not Acceptable for the Accept header.
+func requestedFormat(r *http.Request) (string, int) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format, http.StatusBadRequest
+	}
+
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(accept)
+		if err == nil && strings.HasPrefix(mediaType, mediaTypeFormatPrefix) {
+			return strings.TrimPrefix(mediaType, mediaTypeFormatPrefix), http.StatusNotAcceptable
+		}
+	}
+	return "", http.StatusBadRequest
+}
+
 func (h hasherHandler) getHash(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Vary", "Accept")
+
+	format, status := requestedFormat(r)
+	if _, ok := hashFormatters[format]; format != "" && !ok {
+		http.Error(w, fmt.Sprintf("Unknown format '%v'", format), status)
+		return
+	}
+
 	id, _ := strconv.ParseInt(requestField(r, 0), 10, 64)
 	hash, err := h.svc.Get(domain.HashID(id))
 	if err != nil {
@@ -34,7 +140,145 @@ func (h hasherHandler) getHash(w http.ResponseWriter, r *http.Request) {
 		} else {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 		}
+		return
+	}
+
+	if format == "" {
+		format = formatBase64
+		if hash.Algorithm.IsSCRAM() {
+			format = formatSCRAM
+		}
+	}
+
+	formatted, err := hashFormatters[format](hash)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.Write([]byte(formatted))
+}
+
+type reliefParamsResponse struct {
+	Algorithm domain.Algorithm `json:"algorithm"`
+	Params    string           `json:"params"`
+	Salt      []byte           `json:"salt"`
+	Token     []byte           `json:"token,omitempty"`
+}
+
+// writeReliefParams responds with the server relief parameters
+func writeReliefParams(w http.ResponseWriter, params hasher.ReliefParams) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(reliefParamsResponse{
+		Algorithm: params.Algorithm,
+		Params:    params.Params,
+		Salt:      params.Salt,
+		Token:     params.Token,
+	}); err != nil {
+		http.Error(w, "Cannot encode response", http.StatusInternalServerError)
+	}
+}
+
+func (h hasherHandler) newReliefParams(w http.ResponseWriter, r *http.Request) {
+	params, err := h.svc.NewReliefParams(domain.Algorithm(r.FormValue("algorithm")))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeReliefParams(w, params)
+}
+
+func (h hasherHandler) reliefParams(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(requestField(r, 0), 10, 64)
+	params, err := h.svc.ReliefParams(domain.HashID(id))
+	if err != nil {
+		if errors.Is(err, hasher.ErrReliefNotSupported) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeReliefParams(w, params)
+}
+
+func (h hasherHandler) createRelief(w http.ResponseWriter, r *http.Request) {
+	salt, err := decodeFormBytes(r, "salt")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	token, err := decodeFormBytes(r, "token")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key, err := decodeFormBytes(r, "key")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	hashID, err := h.svc.CreateRelief(hasher.ReliefParams{
+		Algorithm: domain.Algorithm(r.FormValue("algorithm")),
+		Params:    r.FormValue("params"),
+		Salt:      salt,
+		Token:     token,
+	}, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Write(hashID.Bytes())
+}
+
+func (h hasherHandler) verifyRelief(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(requestField(r, 0), 10, 64)
+	key, err := decodeFormBytes(r, "key")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	valid, err := h.svc.VerifyRelief(domain.HashID(id), key)
+	// respond exactly like a mismatch, do not leak which hashes exist
+	if err != nil && !errors.Is(err, repository.ErrHashNotFound) {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(verifyResponse{Valid: valid}); err != nil {
+		http.Error(w, "Cannot encode response", http.StatusInternalServerError)
+	}
+}
+
+type verifyResponse struct {
+	Valid    bool `json:"valid"`
+	Rehashed bool `json:"rehashed"`
+}
+
+func (h hasherHandler) verifyHash(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(requestField(r, 0), 10, 64)
+	password := r.FormValue("password")
+	result, err := h.svc.Verify(domain.HashID(id), password)
+	if err != nil {
+		switch {
+		case errors.Is(err, repository.ErrHashNotFound):
+			// respond exactly like a mismatch, do not leak which hashes exist
+		case errors.Is(err, hasher.ErrInvalidPassword):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	}
 
-	w.Write(hash.Base64())
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(verifyResponse{Valid: result.Valid, Rehashed: result.Rehashed}); err != nil {
+		http.Error(w, "Cannot encode response", http.StatusInternalServerError)
+	}
 }
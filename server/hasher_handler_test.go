@@ -0,0 +1,114 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/domain/repository"
+	"github.com/plar/hash/infra/persistence/memory"
+	"github.com/plar/hash/server/config"
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+type handlerConfig struct {
+	config.DefaultConfig
+}
+
+func (c *handlerConfig) TaskDelay() time.Duration {
+	return 0
+}
+
+// RehashOnVerify keeps the stored algorithm of the test hashes
+func (c *handlerConfig) RehashOnVerify() bool {
+	return false
+}
+
+// SCRAM is not allowed by default
+func (c *handlerConfig) AllowedAlgorithms() []domain.Algorithm {
+	return domain.Algorithms()
+}
+
+func newHandlerService(t *testing.T) (hasher.Service, repository.HashRepository) {
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, &handlerConfig{}, stats.New())
+	t.Cleanup(svc.Stop)
+	return svc, repo
+}
+
+// createHash creates a hash through the service and waits for the pool to store it
+func createHash(t *testing.T, svc hasher.Service, repo repository.HashRepository, password string, opts hasher.CreateOptions) domain.HashID {
+	id, err := svc.Create(password, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if _, err := repo.Load(id); err == nil {
+			return id
+		}
+	}
+	t.Fatalf("hash %v was not stored", id)
+	return 0
+}
+
+// verify posts the password to the verify endpoint of the hash
+func verify(svc hasher.Service, id domain.HashID, password string) *httptest.ResponseRecorder {
+	h := hasherHandler{svc: svc}
+	rt := newRouter([]route{newRoute(http.MethodPost, "/hash/([0-9]+)/verify", h.verifyHash)})
+
+	form := url.Values{"password": {password}}
+	r := httptest.NewRequest(http.MethodPost, "/hash/"+strconv.FormatInt(int64(id), 10)+"/verify", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	rt.handler(w, r)
+	return w
+}
+
+func TestVerifyPlaintextHidden(t *testing.T) {
+	assert := assert.New(t)
+
+	svc, repo := newHandlerService(t)
+	params, err := svc.NewReliefParams(domain.AlgorithmArgon2id)
+	assert.NoError(err)
+	reliefID, err := svc.CreateRelief(params, make([]byte, 32))
+	assert.NoError(err)
+
+	srpID := repo.NewID()
+	repo.Save(domain.Hash{ID: srpID, Algorithm: domain.AlgorithmSRP6a, Params: "n=2048", Salt: []byte("0123456789abcdef"), Hash: []byte{2}})
+
+	// SRP verifiers and server relief hashes look exactly like a missing hash
+	missing := verify(svc, srpID+1000, "angryMonkey")
+	for _, id := range []domain.HashID{reliefID, srpID} {
+		w := verify(svc, id, "angryMonkey")
+		assert.Equal(missing.Code, w.Code)
+		assert.Equal(missing.Body.String(), w.Body.String())
+	}
+	assert.Equal(http.StatusOK, missing.Code)
+	assert.JSONEq(`{"valid":false,"rehashed":false}`, missing.Body.String())
+}
+
+func TestVerifySCRAMNonASCII(t *testing.T) {
+	assert := assert.New(t)
+
+	svc, repo := newHandlerService(t)
+	id := createHash(t, svc, repo, "pencil", hasher.CreateOptions{Algorithm: domain.AlgorithmSCRAMSHA256})
+
+	w := verify(svc, id, "pencil")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.JSONEq(`{"valid":true,"rehashed":false}`, w.Body.String())
+
+	// a password the SCRAM encryptor rejects looks exactly like a missing hash
+	missing := verify(svc, id+1000, "péncil")
+	w = verify(svc, id, "péncil")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal(missing.Code, w.Code)
+	assert.Equal(missing.Body.String(), w.Body.String())
+	assert.JSONEq(`{"valid":false,"rehashed":false}`, w.Body.String())
+}
@@ -0,0 +1,52 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/plar/hash/service/kdf"
+)
+
+type kdfHandler struct {
+	svc kdf.Service
+}
+
+type deriveResponse struct {
+	Key []byte `json:"key"`
+}
+
+func (h kdfHandler) derive(w http.ResponseWriter, r *http.Request) {
+	var fields [3][]byte
+	for i, name := range []string{"secret", "salt", "info"} {
+		value, err := decodeFormBytes(r, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fields[i] = value
+	}
+	length, err := strconv.Atoi(r.FormValue("length"))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%v: '%v'", kdf.ErrInvalidLength, r.FormValue("length")), http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.svc.Derive(kdf.Hash(r.FormValue("hash")), fields[0], fields[1], fields[2], length)
+	if err != nil {
+		if errors.Is(err, kdf.ErrDisabled) {
+			http.NotFound(w, r)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := json.NewEncoder(w).Encode(deriveResponse{Key: key}); err != nil {
+		http.Error(w, "Cannot encode response", http.StatusInternalServerError)
+	}
+}
@@ -2,6 +2,8 @@ package server
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
 	"net/http"
 	"regexp"
 )
@@ -35,6 +37,15 @@ func requestField(r *http.Request, index int) string {
 	return fields[index]
 }
 
+// decodeFormBytes decodes a base64 encoded form value
+func decodeFormBytes(r *http.Request, name string) ([]byte, error) {
+	value, err := base64.StdEncoding.DecodeString(r.FormValue(name))
+	if err != nil {
+		return nil, fmt.Errorf("Cannot decode '%v': %w", name, err)
+	}
+	return value, nil
+}
+
 func (rt *router) handler(w http.ResponseWriter, r *http.Request) {
 	for _, route := range rt.routes {
 		matches := route.regex.FindStringSubmatch(r.URL.Path)
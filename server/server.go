@@ -9,6 +9,8 @@ import (
 	"github.com/plar/hash/server/config"
 	"github.com/plar/hash/service/hasher"
 	"github.com/plar/hash/service/health"
+	"github.com/plar/hash/service/kdf"
+	"github.com/plar/hash/service/srp"
 	"github.com/plar/hash/service/stats"
 )
 
@@ -25,11 +27,14 @@ type Server struct {
 }
 
 // New creates a new HTTP server with config
-func New(cfg config.Config, hasherSvc hasher.Service, statsSvc stats.Service, healthSvc health.Service) (*Server, chan error) {
+func New(cfg config.Config, hasherSvc hasher.Service, srpSvc srp.Service, kdfSvc kdf.Service, statsSvc stats.Service, healthSvc health.Service) (*Server, chan error) {
 
-	// initialize specific handlers for /hash and /stats endpoints
+	// initialize specific handlers for /hash, /srp, /kdf, /stats and /admin endpoints
 	hasherHandler := &hasherHandler{svc: hasherSvc}
+	srpHandler := &srpHandler{svc: srpSvc}
+	kdfHandler := &kdfHandler{svc: kdfSvc}
 	statsHandler := &statsHandler{svc: statsSvc}
+	adminHandler := &adminHandler{svc: hasherSvc, healthSvc: healthSvc, token: cfg.AdminToken()}
 
 	// initialize server
 	s := &Server{
@@ -42,10 +47,27 @@ func New(cfg config.Config, hasherSvc hasher.Service, statsSvc stats.Service, he
 	// initialize routes
 	s.router = newRouter([]route{
 		newRoute(http.MethodPost, "/hash", hasherHandler.createHash),
+		newRoute(http.MethodPost, "/hash/import", hasherHandler.importHash),
 		newRoute(http.MethodGet, "/hash/([0-9]+)", hasherHandler.getHash),
+		newRoute(http.MethodPost, "/hash/([0-9]+)/verify", hasherHandler.verifyHash),
+		newRoute(http.MethodGet, "/hash/params", hasherHandler.newReliefParams),
+		newRoute(http.MethodGet, "/hash/([0-9]+)/params", hasherHandler.reliefParams),
+		newRoute(http.MethodPost, "/hash/relief", hasherHandler.createRelief),
+		newRoute(http.MethodPost, "/hash/([0-9]+)/relief/verify", hasherHandler.verifyRelief),
+
+		newRoute(http.MethodPost, "/srp", srpHandler.register),
+		newRoute(http.MethodPost, "/srp/([0-9]+)/challenge", srpHandler.challenge),
+		newRoute(http.MethodPost, "/srp/authenticate", srpHandler.authenticate),
+
+		newRoute(http.MethodPost, "/kdf", kdfHandler.derive),
 
 		newRoute(http.MethodGet, "/stats", statsHandler.stats),
 
+		newRoute(http.MethodGet, "/admin/calibration", adminHandler.authorize(adminHandler.calibration)),
+		newRoute(http.MethodPost, "/admin/wrap", adminHandler.authorize(adminHandler.startWrap)),
+		newRoute(http.MethodGet, "/admin/wrap/progress", adminHandler.authorize(adminHandler.wrapProgress)),
+		newRoute(http.MethodPost, "/admin/selftest", adminHandler.authorize(adminHandler.selfTest)),
+
 		newRoute(http.MethodGet, "/shutdown", s.shutdownHandler),
 	})
 
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/service/srp"
+)
+
+type srpHandler struct {
+	svc srp.Service
+}
+
+func (h srpHandler) register(w http.ResponseWriter, r *http.Request) {
+	salt, err := decodeFormBytes(r, "salt")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	verifier, err := decodeFormBytes(r, "verifier")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	group := 0
+	if raw := r.FormValue("group"); raw != "" {
+		if group, err = strconv.Atoi(raw); err != nil {
+			http.Error(w, fmt.Sprintf("%v: '%v'", srp.ErrUnknownGroup, raw), http.StatusBadRequest)
+			return
+		}
+	}
+
+	hashID, err := h.svc.Register(salt, verifier, group)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Write(hashID.Bytes())
+}
+
+type challengeResponse struct {
+	Session   string `json:"session"`
+	Group     int    `json:"group"`
+	Salt      []byte `json:"salt"`
+	PublicKey []byte `json:"public_key"`
+}
+
+func (h srpHandler) challenge(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.ParseInt(requestField(r, 0), 10, 64)
+	publicKey, err := decodeFormBytes(r, "public_key")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	challenge, err := h.svc.Challenge(domain.HashID(id), publicKey)
+	if err != nil {
+		switch {
+		case errors.Is(err, srp.ErrInvalidPublicKey):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(challengeResponse{
+		Session:   challenge.Session,
+		Group:     challenge.Group,
+		Salt:      challenge.Salt,
+		PublicKey: challenge.PublicKey,
+	}); err != nil {
+		http.Error(w, "Cannot encode response", http.StatusInternalServerError)
+	}
+}
+
+type authenticateResponse struct {
+	ID    domain.HashID `json:"id"`
+	Proof []byte        `json:"proof"`
+}
+
+func (h srpHandler) authenticate(w http.ResponseWriter, r *http.Request) {
+	proof, err := decodeFormBytes(r, "proof")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	id, serverProof, err := h.svc.Authenticate(r.FormValue("session"), proof)
+	if err != nil {
+		// do not tell an expired handshake from a wrong password
+		http.Error(w, srp.ErrAuthentication.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	if err := json.NewEncoder(w).Encode(authenticateResponse{ID: id, Proof: serverProof}); err != nil {
+		http.Error(w, "Cannot encode response", http.StatusInternalServerError)
+	}
+}
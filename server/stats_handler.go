@@ -4,6 +4,10 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/kdf"
+	"github.com/plar/hash/service/probe"
+	"github.com/plar/hash/service/srp"
 	"github.com/plar/hash/service/stats"
 )
 
@@ -12,17 +16,84 @@ type statsHandler struct {
 }
 
 type statsResponse struct {
+	Total   int64               `json:"total"`
+	Average int64               `json:"average"`
+	Memory  memoryStatsResponse `json:"memory"`
+	// Policy is the hash execution time per policy arm
+	Policy map[string]armStatsResponse `json:"policy"`
+	Probe  probeStatsResponse          `json:"probe"`
+	SRP    srpStatsResponse            `json:"srp"`
+	KDF    kdfStatsResponse            `json:"kdf"`
+}
+
+type kdfStatsResponse struct {
+	Total    int64 `json:"total"`
+	Average  int64 `json:"average"`
+	Failures int64 `json:"failures"`
+}
+
+type srpStatsResponse struct {
+	Total    int64 `json:"total"`
+	Average  int64 `json:"average"`
+	Failures int64 `json:"failures"`
+	// Pending is the number of pending handshakes
+	Pending int64 `json:"pending"`
+}
+
+type probeStatsResponse struct {
+	Total    int64 `json:"total"`
+	Average  int64 `json:"average"`
+	Failures int64 `json:"failures"`
+	// Consecutive is the number of consecutive failures
+	Consecutive int64 `json:"consecutive"`
+}
+
+type armStatsResponse struct {
 	Total   int64 `json:"total"`
 	Average int64 `json:"average"`
 }
 
+type memoryStatsResponse struct {
+	Reserved int64 `json:"reserved"`
+	Waiters  int64 `json:"waiters"`
+}
+
 func (h statsHandler) stats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 
-	m := h.svc.Metric("Hasher.Create")
+	m := h.svc.Metric(hasher.StatsCreate)
+	probes := h.svc.Metric(hasher.StatsProbe)
+	login := h.svc.Metric(srp.StatsAuthenticate)
+	derive := h.svc.Metric(kdf.StatsDerive)
+	policy := make(map[string]armStatsResponse)
+	for arm, am := range h.svc.Metrics(hasher.StatsPolicyPrefix) {
+		policy[arm] = armStatsResponse{Total: am.Count(), Average: am.Average().Microseconds()}
+	}
 	if err := json.NewEncoder(w).Encode(statsResponse{
 		Total:   m.Count(),
 		Average: m.Average().Microseconds(),
+		Memory: memoryStatsResponse{
+			Reserved: h.svc.Gauge(hasher.StatsMemoryReserved),
+			Waiters:  h.svc.Gauge(hasher.StatsMemoryWaiters),
+		},
+		Policy: policy,
+		Probe: probeStatsResponse{
+			Total:       probes.Count(),
+			Average:     probes.Average().Microseconds(),
+			Failures:    h.svc.Metric(hasher.StatsProbeFailure).Count(),
+			Consecutive: h.svc.Gauge(probe.StatsConsecutiveFailures),
+		},
+		SRP: srpStatsResponse{
+			Total:    login.Count(),
+			Average:  login.Average().Microseconds(),
+			Failures: h.svc.Metric(srp.StatsAuthenticationFailure).Count(),
+			Pending:  h.svc.Gauge(srp.StatsHandshakes),
+		},
+		KDF: kdfStatsResponse{
+			Total:    derive.Count(),
+			Average:  derive.Average().Microseconds(),
+			Failures: h.svc.Metric(kdf.StatsFailure).Count(),
+		},
 	}); err != nil {
 		http.Error(w, "Cannot encode stats", http.StatusInternalServerError)
 	}
@@ -0,0 +1,52 @@
+package hasher
+
+import (
+	"fmt"
+
+	"github.com/plar/hash/domain"
+	"golang.org/x/crypto/argon2"
+)
+
+const argon2KeyLen = 32
+
+// Argon2Params defines Argon2id cost parameters
+type Argon2Params struct {
+	// Memory is the amount of memory in KiB
+	Memory uint32
+	// Iterations is the number of passes over the memory
+	Iterations uint32
+	// Lanes is the degree of parallelism
+	Lanes uint8
+}
+
+type argon2idEncryptor struct {
+	params Argon2Params
+}
+
+// NewArgon2idEncryptor creates a new Argon2id encryptor with the cost parameters
+func NewArgon2idEncryptor(params Argon2Params) Encryptor {
+	return &argon2idEncryptor{
+		params: params,
+	}
+}
+
+func (e *argon2idEncryptor) Algorithm() domain.Algorithm {
+	return domain.AlgorithmArgon2id
+}
+
+func (e *argon2idEncryptor) Params() string {
+	return fmt.Sprintf("m=%d,t=%d,p=%d", e.params.Memory, e.params.Iterations, e.params.Lanes)
+}
+
+func (e *argon2idEncryptor) SaltLen() int {
+	return defSaltLen
+}
+
+func (e *argon2idEncryptor) Memory() uint64 {
+	return uint64(e.params.Memory)
+}
+
+// Hash generates Argon2id key of password
+func (e *argon2idEncryptor) Hash(password, salt []byte) ([]byte, error) {
+	return argon2.IDKey(password, salt, e.params.Iterations, e.params.Memory, e.params.Lanes, argon2KeyLen), nil
+}
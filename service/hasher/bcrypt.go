@@ -0,0 +1,107 @@
+package hasher
+
+import (
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/plar/hash/domain"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// bcryptMaxPasswordLen is the maximum number of password bytes used by bcrypt,
+// the remaining bytes are silently ignored.
+const bcryptMaxPasswordLen = 72
+
+// ErrPasswordTooLong is returned when password exceeds the algorithm input limit
+var ErrPasswordTooLong = errors.New("Password is too long")
+
+// BcryptParams defines bcrypt cost parameters
+type BcryptParams struct {
+	// Cost is the base-2 logarithm of the number of rounds
+	Cost int
+	// PreHash enables SHA512 pre-hashing of passwords to avoid the 72 bytes input limit
+	PreHash bool
+}
+
+type bcryptEncryptor struct {
+	params BcryptParams
+}
+
+// NewBcryptEncryptor creates a new bcrypt encryptor with the cost parameters
+func NewBcryptEncryptor(params BcryptParams) Encryptor {
+	return &bcryptEncryptor{
+		params: params,
+	}
+}
+
+// Validate checks that password fits the bcrypt input limit
+func (e *bcryptEncryptor) Validate(password []byte) error {
+	if !e.params.PreHash && len(password) > bcryptMaxPasswordLen {
+		return ErrPasswordTooLong
+	}
+	return nil
+}
+
+func (e *bcryptEncryptor) Algorithm() domain.Algorithm {
+	return domain.AlgorithmBcrypt
+}
+
+// Params returns the bcrypt cost and the pre-hash flag,
+// the cost is duplicated in the hash itself.
+func (e *bcryptEncryptor) Params() string {
+	if e.params.PreHash {
+		return fmt.Sprintf("c=%d,h=sha512", e.params.Cost)
+	}
+	return fmt.Sprintf("c=%d", e.params.Cost)
+}
+
+// SaltLen returns zero, bcrypt generates a salt and embeds it into the hash.
+func (e *bcryptEncryptor) SaltLen() int {
+	return 0
+}
+
+// Memory returns the size of the blowfish state
+func (e *bcryptEncryptor) Memory() uint64 {
+	return 4
+}
+
+// Verify compares the bcrypt hash with password
+func (e *bcryptEncryptor) Verify(password []byte, hash domain.Hash) (bool, error) {
+	if e.params.PreHash {
+		digest := sha512.Sum512(password)
+		password = digest[:]
+	}
+
+	err := bcrypt.CompareHashAndPassword(hash.Hash, password)
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Hash generates bcrypt hash of password in the modular crypt format,
+// the salt argument is ignored.
+func (e *bcryptEncryptor) Hash(password, salt []byte) ([]byte, error) {
+	if e.params.PreHash {
+		digest := sha512.Sum512(password)
+		password = digest[:]
+	}
+	return bcrypt.GenerateFromPassword(password, e.params.Cost)
+}
+
+// parseBcryptHash validates a bcrypt hash in the modular crypt format ($2a$, $2b$ or $2y$)
+// and returns its cost.
+func parseBcryptHash(encoded string) (int, error) {
+	// $2b$10$ + 22 chars of salt + 31 chars of hash
+	if len(encoded) != 60 || encoded[0] != '$' || encoded[1] != '2' || encoded[3] != '$' || encoded[6] != '$' ||
+		!strings.ContainsRune("aby", rune(encoded[2])) || !isCryptString(encoded[7:]) {
+		return 0, ErrInvalidHash
+	}
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil || cost < bcrypt.MinCost || cost > bcrypt.MaxCost {
+		return 0, ErrInvalidHash
+	}
+	return cost, nil
+}
@@ -0,0 +1,48 @@
+package hasher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/plar/hash/infra/breach"
+)
+
+// ErrBreachCheck is returned when the breach corpus cannot be checked
+var ErrBreachCheck = errors.New("Cannot check the password against the breach corpus")
+
+// breachViolation is the violation of a password found in the breach corpus
+var breachViolation = PolicyViolation{
+	Rule:    RuleBreached,
+	Message: "Password has appeared in a data breach",
+}
+
+// isBreached checks the password as typed and, if it differs, the normalized password,
+// breach corpora contain the digests of the passwords as they were leaked.
+func isBreached(corpus breach.Corpus, password, normalized []byte) (bool, error) {
+	found, err := corpus.Contains(breach.NewDigest(password))
+	if err != nil || found || bytes.Equal(password, normalized) {
+		return found, err
+	}
+	return corpus.Contains(breach.NewDigest(normalized))
+}
+
+// checkPassword checks a new password against the password policy and the breach corpus,
+// returns PasswordPolicyError with all violations.
+func (s *service) checkPassword(password, normalized []byte) error {
+	violations := s.passwordPolicy.Violations(string(normalized))
+	if s.breachCorpus != nil {
+		found, err := isBreached(s.breachCorpus, password, normalized)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrBreachCheck, err)
+		}
+		if found {
+			violations = append(violations, breachViolation)
+		}
+	}
+
+	if len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package hasher_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/infra/breach"
+	"github.com/plar/hash/infra/persistence/memory"
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+type breachConfig struct {
+	testConfig
+}
+
+func (c *breachConfig) PasswordMinLength() int {
+	return 6
+}
+
+func (c *breachConfig) Normalization() domain.Stage {
+	return domain.StageNFKC
+}
+
+type failingCorpus struct{}
+
+func (failingCorpus) Contains(digest breach.Digest) (bool, error) {
+	return false, errors.New("disk failure")
+}
+
+func TestCreateBreached(t *testing.T) {
+	assert := assert.New(t)
+
+	filter, err := breach.NewBloomFilter(10, 0.001)
+	assert.NoError(err)
+	filter.Add(breach.NewDigest([]byte("password")))
+	// the fullwidth "abc" as leaked, NFKC normalizes it to "abc"
+	filter.Add(breach.NewDigest([]byte("\uff41\uff42\uff43")))
+
+	repo := memory.NewHashRepository()
+	svc := hasher.NewWithBreachCorpus(repo, &breachConfig{}, stats.New(), filter)
+	defer svc.Stop()
+
+	var policyErr *hasher.PasswordPolicyError
+	_, err = svc.Create("password", hasher.CreateOptions{})
+	assert.True(errors.As(err, &policyErr))
+	assert.Equal([]hasher.PolicyViolation{{Rule: hasher.RuleBreached, Message: "Password has appeared in a data breach"}}, policyErr.Violations)
+
+	// breached and too short
+	_, err = svc.Create("\uff41\uff42\uff43", hasher.CreateOptions{})
+	assert.True(errors.As(err, &policyErr))
+	assert.Len(policyErr.Violations, 2)
+	assert.Equal(hasher.RuleMinLength, policyErr.Violations[0].Rule)
+	assert.Equal(hasher.RuleBreached, policyErr.Violations[1].Rule)
+
+	hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	assert.EqualValues(1, hashID)
+}
+
+func TestCreateBreachCheckError(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.NewWithBreachCorpus(repo, &breachConfig{}, stats.New(), failingCorpus{})
+	defer svc.Stop()
+
+	_, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.True(errors.Is(err, hasher.ErrBreachCheck))
+}
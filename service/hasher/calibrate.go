@@ -0,0 +1,157 @@
+package hasher
+
+import (
+	"sort"
+	"time"
+
+	"github.com/plar/hash/domain"
+)
+
+const (
+	calibrationMaxIterations = 32
+	calibrationMaxScryptLogN = 30
+	calibrationMaxBcryptCost = 31
+	calibrationMaxPBKDF2Iter = 1 << 30
+	// calibrationSamples is the number of timed runs per cost, the median is used
+	calibrationSamples = 3
+)
+
+// Calibration is the calibrated cost parameters of an algorithm
+type Calibration struct {
+	Algorithm domain.Algorithm
+	Params    string
+	// Duration is the median measured time of a single hash
+	Duration time.Duration
+	// Memory is the estimated memory usage of a single hash in KiB
+	Memory uint64
+}
+
+// costLadder returns encryptors with increasing costs for step 0, 1, 2, ...
+// and nil when the highest cost is reached.
+type costLadder func(step int) Encryptor
+
+// Calibrate benchmarks the registered encryptors and replaces them with the ones
+// with the highest costs that still hit the target time per hash within the memory ceiling in KiB.
+// The registered costs are the floor, they are kept even if they miss the target or the ceiling.
+// Encryptors without tunable costs are left as is.
+func (r *Registry) Calibrate(target time.Duration, maxMemory uint32) []Calibration {
+	var calibration []Calibration
+	for _, algorithm := range r.Algorithms() {
+		ladder := newCostLadder(r.factories[algorithm](), maxMemory)
+		if ladder == nil {
+			continue // nothing to tune
+		}
+
+		bestStep := 0
+		bestDuration := measureHash(ladder(0))
+		for step := 1; ; step++ {
+			e := ladder(step)
+			if e == nil {
+				break
+			}
+			duration := measureHash(e)
+			if duration > target {
+				break
+			}
+			bestStep, bestDuration = step, duration
+		}
+
+		best := ladder(bestStep)
+		r.Register(algorithm, func() Encryptor {
+			return ladder(bestStep)
+		})
+		calibration = append(calibration, Calibration{
+			Algorithm: algorithm,
+			Params:    best.Params(),
+			Duration:  bestDuration,
+			Memory:    best.Memory(),
+		})
+	}
+	return calibration
+}
+
+// measureHash returns the median time of calibrationSamples hash runs,
+// a single sample is easily skewed by the scheduler or the GC
+func measureHash(e Encryptor) time.Duration {
+	salt := make([]byte, e.SaltLen())
+	samples := make([]time.Duration, calibrationSamples)
+	for i := range samples {
+		start := time.Now()
+		e.Hash([]byte("calibration"), salt)
+		samples[i] = time.Since(start)
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	return samples[len(samples)/2]
+}
+
+// newCostLadder creates a cost ladder based on the encryptor parameters.
+// Step 0 is the configured cost, calibration never goes below it.
+func newCostLadder(e Encryptor, maxMemory uint32) costLadder {
+	switch e := e.(type) {
+	case *argon2idEncryptor:
+		return func(step int) Encryptor {
+			// double the memory up to the ceiling first, then add iterations
+			params := e.params
+			for i := 0; i < step; i++ {
+				if params.Memory <= maxMemory/2 {
+					params.Memory *= 2
+				} else {
+					params.Iterations++
+				}
+			}
+			if step > 0 && params.Iterations > calibrationMaxIterations {
+				return nil
+			}
+			return NewArgon2idEncryptor(params)
+		}
+
+	case *scryptEncryptor:
+		return func(step int) Encryptor {
+			params := e.params
+			params.N <<= uint(step)
+			if step > 0 && (params.N > 1<<calibrationMaxScryptLogN || scryptMemory(params) > uint64(maxMemory)) {
+				return nil
+			}
+			return NewScryptEncryptor(params)
+		}
+
+	case *bcryptEncryptor:
+		return func(step int) Encryptor {
+			params := e.params
+			params.Cost += step
+			if step > 0 && params.Cost > calibrationMaxBcryptCost {
+				return nil
+			}
+			return NewBcryptEncryptor(params)
+		}
+
+	case *pbkdf2Encryptor:
+		return func(step int) Encryptor {
+			params := e.params
+			params.Iterations <<= uint(step)
+			if step > 0 && params.Iterations > calibrationMaxPBKDF2Iter {
+				return nil
+			}
+			return &pbkdf2Encryptor{
+				algorithm: e.algorithm,
+				hashFn:    e.hashFn,
+				params:    params,
+			}
+		}
+
+	case *scramEncryptor:
+		return func(step int) Encryptor {
+			params := e.params
+			params.Iterations <<= uint(step)
+			if step > 0 && params.Iterations > calibrationMaxPBKDF2Iter {
+				return nil
+			}
+			return &scramEncryptor{
+				algorithm: e.algorithm,
+				hashFn:    e.hashFn,
+				params:    params,
+			}
+		}
+	}
+	return nil
+}
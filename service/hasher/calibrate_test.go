@@ -0,0 +1,107 @@
+package hasher_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalibrate(t *testing.T) {
+	assert := assert.New(t)
+
+	r := hasher.NewRegistryFromConfig(&calibrateConfig{})
+	calibration := r.Calibrate(2*time.Millisecond, 1024)
+
+	// sha512 has nothing to tune
+	var algorithms []domain.Algorithm
+	for _, c := range calibration {
+		algorithms = append(algorithms, c.Algorithm)
+		assert.NotEmpty(c.Params)
+		assert.LessOrEqual(c.Memory, uint64(1024))
+
+		// the registry uses the calibrated parameters
+		e, err := r.New(c.Algorithm)
+		assert.NoError(err)
+		assert.Equal(c.Params, e.Params())
+	}
+	assert.Equal([]domain.Algorithm{
+		domain.AlgorithmArgon2id,
+		domain.AlgorithmBcrypt,
+		domain.AlgorithmPBKDF2SHA256,
+		domain.AlgorithmPBKDF2SHA512,
+		domain.AlgorithmScrypt,
+	}, algorithms)
+
+	e, err := r.New(domain.AlgorithmArgon2id)
+	assert.NoError(err)
+	assert.Contains(e.Params(), "m=1024,")
+}
+
+func TestCalibrateConfiguredFloor(t *testing.T) {
+	assert := assert.New(t)
+
+	// the configured costs miss both the target and the memory ceiling
+	configured := hasher.NewRegistryFromConfig(Config())
+	r := hasher.NewRegistryFromConfig(Config())
+	calibration := r.Calibrate(time.Nanosecond, 1024)
+	assert.Len(calibration, 5)
+
+	// calibration never weakens the configured costs
+	for _, c := range calibration {
+		want, err := configured.New(c.Algorithm)
+		assert.NoError(err)
+		e, err := r.New(c.Algorithm)
+		assert.NoError(err)
+		assert.Equal(want.Params(), c.Params)
+		assert.Equal(want.Params(), e.Params())
+	}
+}
+
+// calibrateConfig starts the calibration from cheap costs
+type calibrateConfig struct {
+	testConfig
+}
+
+func (c *calibrateConfig) Argon2Memory() uint32 {
+	return 256
+}
+
+func (c *calibrateConfig) BcryptCost() int {
+	return 4
+}
+
+func (c *calibrateConfig) ScryptN() int {
+	return 16
+}
+
+func (c *calibrateConfig) PBKDF2Iterations() int {
+	return 1000
+}
+
+func (c *calibrateConfig) Calibrate() bool {
+	return true
+}
+
+func (c *calibrateConfig) CalibrationTarget() time.Duration {
+	return time.Millisecond
+}
+
+func (c *calibrateConfig) CalibrationMaxMemory() uint32 {
+	return 1024
+}
+
+func TestServiceCalibration(t *testing.T) {
+	assert := assert.New(t)
+
+	svc := hasher.New(nil, Config(), stats.New())
+	assert.Empty(svc.Calibration())
+	svc.Stop()
+
+	svc = hasher.New(nil, &calibrateConfig{}, stats.New())
+	assert.Len(svc.Calibration(), 5)
+	svc.Stop()
+}
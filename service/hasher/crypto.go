@@ -1,16 +1,249 @@
 package hasher
 
 import (
+	"crypto/rand"
 	"crypto/sha512"
+	"crypto/subtle"
 	"errors"
+	"fmt"
 	"hash"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/plar/hash/domain"
 )
 
 // ErrInvalidPassword is retured when password is invalid
 var ErrInvalidPassword = errors.New("Password is empty or nil")
 
+// ErrInvalidParams is returned when stored algorithm parameters cannot be parsed
+var ErrInvalidParams = errors.New("Invalid algorithm parameters")
+
+// ErrUnknownAlgorithm is returned when a stored hash uses an unsupported algorithm
+var ErrUnknownAlgorithm = errors.New("Unknown algorithm")
+
+// ErrInvalidHash is returned when an imported hash cannot be parsed
+var ErrInvalidHash = errors.New("Invalid hash")
+
+// ErrPlaintextVerify is returned when a hash cannot be verified with a plaintext password, e.g. an SRP verifier
+var ErrPlaintextVerify = errors.New("Hash cannot be verified with a plaintext password")
+
+// defSaltLen is the default salt length in bytes
+const defSaltLen = 16
+
+// Encryptor interface declares a password hashing algorithm
 type Encryptor interface {
-	Hash(password []byte) []byte
+	// Algorithm returns the algorithm identifier
+	Algorithm() domain.Algorithm
+
+	// Params returns the algorithm parameters in the PHC string format
+	Params() string
+
+	// SaltLen returns the length of a salt in bytes,
+	// zero if the algorithm generates and embeds salts itself
+	SaltLen() int
+
+	// Memory returns the estimated memory usage of a single hash in KiB
+	Memory() uint64
+
+	// Hash generates a hash of password with salt
+	Hash(password, salt []byte) ([]byte, error)
+}
+
+// passwordValidator is implemented by encryptors with additional input restrictions
+type passwordValidator interface {
+	Validate(password []byte) error
+}
+
+// passwordVerifier is implemented by encryptors which cannot recompute a hash
+// from the stored salt and parameters, e.g. bcrypt embeds everything into the hash.
+type passwordVerifier interface {
+	Verify(password []byte, hash domain.Hash) (bool, error)
+}
+
+// verifyPassword recomputes the password hash with the stored salt and parameters
+// and compares it with the stored hash in constant time.
+func verifyPassword(e Encryptor, password []byte, hash domain.Hash) (bool, error) {
+	if v, ok := e.(passwordVerifier); ok {
+		return v.Verify(password, hash)
+	}
+
+	calculated, err := e.Hash(password, hash.Salt)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(calculated, hash.Hash) == 1, nil
+}
+
+// newEncryptorFor creates an encryptor with the algorithm and parameters of the stored hash
+func newEncryptorFor(hash domain.Hash) (Encryptor, error) {
+	switch hash.Algorithm {
+	case domain.AlgorithmSHA512:
+		return NewSHA512Encryptor(), nil
+
+	case domain.AlgorithmSSHA512:
+		return NewSSHA512Encryptor(), nil
+
+	case domain.AlgorithmArgon2id, domain.AlgorithmArgon2idSHA512:
+		values, err := parseParams(hash.Params, "m", "t", "p")
+		if err != nil {
+			return nil, err
+		}
+		if values[2] > math.MaxUint8 {
+			return nil, fmt.Errorf("%w: '%v'", ErrInvalidParams, hash.Params)
+		}
+		params := Argon2Params{
+			Memory:     uint32(values[0]),
+			Iterations: uint32(values[1]),
+			Lanes:      uint8(values[2]),
+		}
+		if hash.Algorithm == domain.AlgorithmArgon2idSHA512 {
+			return NewArgon2idSHA512Encryptor(params), nil
+		}
+		return NewArgon2idEncryptor(params), nil
+
+	case domain.AlgorithmBcrypt:
+		preHash := strings.HasSuffix(hash.Params, ",h=sha512")
+		values, err := parseParams(strings.TrimSuffix(hash.Params, ",h=sha512"), "c")
+		if err != nil {
+			return nil, err
+		}
+		return NewBcryptEncryptor(BcryptParams{
+			Cost:    values[0],
+			PreHash: preHash,
+		}), nil
+
+	case domain.AlgorithmScrypt:
+		values, err := parseParams(hash.Params, "ln", "r", "p")
+		if err != nil {
+			return nil, err
+		}
+		if values[0] > 30 {
+			return nil, fmt.Errorf("%w: '%v'", ErrInvalidParams, hash.Params)
+		}
+		return NewScryptEncryptor(ScryptParams{
+			N: 1 << uint(values[0]),
+			R: values[1],
+			P: values[2],
+		}), nil
+
+	case domain.AlgorithmSCRAMSHA1, domain.AlgorithmSCRAMSHA256:
+		values, err := parseParams(hash.Params, "i")
+		if err != nil {
+			return nil, err
+		}
+		params := SCRAMParams{Iterations: values[0]}
+		if hash.Algorithm == domain.AlgorithmSCRAMSHA1 {
+			return NewSCRAMSHA1Encryptor(params), nil
+		}
+		return NewSCRAMSHA256Encryptor(params), nil
+
+	case domain.AlgorithmPBKDF2SHA256, domain.AlgorithmPBKDF2SHA512:
+		values, err := parseParams(hash.Params, "i")
+		if err != nil {
+			return nil, err
+		}
+		params := PBKDF2Params{
+			Iterations: values[0],
+			SaltLen:    len(hash.Salt),
+			KeyLen:     len(hash.Hash),
+		}
+		if hash.Algorithm == domain.AlgorithmPBKDF2SHA512 {
+			return NewPBKDF2SHA512Encryptor(params), nil
+		}
+		return NewPBKDF2SHA256Encryptor(params), nil
+
+	case domain.AlgorithmSHA512Crypt:
+		if hash.Params == "" {
+			return NewSHA512CryptEncryptor(SHA512CryptParams{}), nil
+		}
+		values, err := parseParams(hash.Params, "rounds")
+		if err != nil {
+			return nil, err
+		}
+		return &sha512CryptEncryptor{
+			params:     SHA512CryptParams{Rounds: values[0]},
+			roundsText: sha512CryptRoundsText(string(hash.Hash)),
+		}, nil
+
+	case domain.AlgorithmMD5Crypt:
+		return NewMD5CryptEncryptor(), nil
+
+	case domain.AlgorithmAPR1:
+		return NewAPR1Encryptor(), nil
+	}
+
+	return nil, fmt.Errorf("%w: '%v'", ErrUnknownAlgorithm, hash.Algorithm)
+}
+
+// parseParams parses positive integer parameters in the PHC format, e.g. m=65536,t=1,p=2.
+// The parameters must be in the given order.
+func parseParams(params string, names ...string) ([]int, error) {
+	fields := strings.Split(params, ",")
+	if len(fields) != len(names) {
+		return nil, fmt.Errorf("%w: '%v'", ErrInvalidParams, params)
+	}
+
+	values := make([]int, len(names))
+	for i, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 || kv[0] != names[i] {
+			return nil, fmt.Errorf("%w: '%v'", ErrInvalidParams, params)
+		}
+		value, err := strconv.ParseInt(kv[1], 10, 32)
+		if err != nil || value < 1 {
+			return nil, fmt.Errorf("%w: '%v'", ErrInvalidParams, params)
+		}
+		values[i] = int(value)
+	}
+	return values, nil
+}
+
+// newSalt generates a new random salt using the system CSPRNG
+func newSalt(saltLen int) ([]byte, error) {
+	if saltLen == 0 {
+		return nil, nil
+	}
+
+	salt := make([]byte, saltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+// textSaltAlphabet is the alphabet of text salts, the alphabet of Django salts
+const textSaltAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// newTextSalt generates a new random alphanumeric salt using the system CSPRNG,
+// the salt is longer than saltLen to keep the entropy of a binary salt of saltLen bytes
+func newTextSalt(saltLen int) ([]byte, error) {
+	textLen := int(math.Ceil(float64(8*saltLen) / math.Log2(float64(len(textSaltAlphabet)))))
+	salt := make([]byte, 0, textLen)
+	buf := make([]byte, textLen)
+	for len(salt) < textLen {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		for _, b := range buf {
+			// reject the bytes above the largest multiple of the alphabet size to avoid the modulo bias
+			if int(b) < 256-256%len(textSaltAlphabet) && len(salt) < textLen {
+				salt = append(salt, textSaltAlphabet[int(b)%len(textSaltAlphabet)])
+			}
+		}
+	}
+	return salt, nil
+}
+
+// newSaltFor generates a new random salt for the encryptor.
+// PBKDF2-SHA256 salts are alphanumeric, so the hashes can be exported in the Django format which keeps the salt as text,
+// the salts of other algorithms are binary.
+func newSaltFor(e Encryptor) ([]byte, error) {
+	if e.Algorithm() == domain.AlgorithmPBKDF2SHA256 {
+		return newTextSalt(e.SaltLen())
+	}
+	return newSalt(e.SaltLen())
 }
 
 type sha512Encryptor struct {
@@ -23,9 +256,61 @@ func NewSHA512Encryptor() Encryptor {
 	}
 }
 
-// Hash generates SHA512 of password
-func (e *sha512Encryptor) Hash(password []byte) []byte {
+func (e *sha512Encryptor) Algorithm() domain.Algorithm {
+	return domain.AlgorithmSHA512
+}
+
+func (e *sha512Encryptor) Params() string {
+	return ""
+}
+
+func (e *sha512Encryptor) SaltLen() int {
+	return defSaltLen
+}
+
+func (e *sha512Encryptor) Memory() uint64 {
+	return 0
+}
+
+// Hash generates SHA512 of salt and password
+func (e *sha512Encryptor) Hash(password, salt []byte) ([]byte, error) {
+	e.hashFn.Reset()
+	e.hashFn.Write(salt)
+	e.hashFn.Write(password)
+	return e.hashFn.Sum(nil), nil
+}
+
+type ssha512Encryptor struct {
+	hashFn hash.Hash
+}
+
+// NewSSHA512Encryptor creates a new LDAP salted SHA512 encryptor, the hashes can be exported in the {SSHA512} format
+func NewSSHA512Encryptor() Encryptor {
+	return &ssha512Encryptor{
+		hashFn: sha512.New(),
+	}
+}
+
+func (e *ssha512Encryptor) Algorithm() domain.Algorithm {
+	return domain.AlgorithmSSHA512
+}
+
+func (e *ssha512Encryptor) Params() string {
+	return ""
+}
+
+func (e *ssha512Encryptor) SaltLen() int {
+	return defSaltLen
+}
+
+func (e *ssha512Encryptor) Memory() uint64 {
+	return 0
+}
+
+// Hash generates SHA512 of password and salt
+func (e *ssha512Encryptor) Hash(password, salt []byte) ([]byte, error) {
 	e.hashFn.Reset()
 	e.hashFn.Write(password)
-	return e.hashFn.Sum(nil)
+	e.hashFn.Write(salt)
+	return e.hashFn.Sum(nil), nil
 }
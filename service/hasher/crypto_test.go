@@ -0,0 +1,137 @@
+package hasher_test
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/service/hasher"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSHA512Encryptor(t *testing.T) {
+	assert := assert.New(t)
+
+	e := hasher.NewSHA512Encryptor()
+	assert.Equal(domain.AlgorithmSHA512, e.Algorithm())
+	assert.Equal("", e.Params())
+	assert.Equal(16, e.SaltLen())
+
+	hash, err := e.Hash([]byte("angryMonkey"), nil)
+	assert.NoError(err)
+	assert.Equal("ZEHhWB65gUlzdVwtDQArEyx+KVLzp/aTaRaPlBzYRIFj6vjFdqEb0Q5B8zVKCZ0vKbZPZklJz0Fd7su2A+gf7Q==", base64.StdEncoding.EncodeToString(hash))
+
+	hash, err = e.Hash([]byte("Monkey"), []byte("angry"))
+	assert.NoError(err)
+	assert.Equal("ZEHhWB65gUlzdVwtDQArEyx+KVLzp/aTaRaPlBzYRIFj6vjFdqEb0Q5B8zVKCZ0vKbZPZklJz0Fd7su2A+gf7Q==", base64.StdEncoding.EncodeToString(hash))
+}
+
+func TestSSHA512Encryptor(t *testing.T) {
+	assert := assert.New(t)
+
+	e := hasher.NewSSHA512Encryptor()
+	assert.Equal(domain.AlgorithmSSHA512, e.Algorithm())
+	assert.Equal("", e.Params())
+	assert.Equal(16, e.SaltLen())
+
+	hash, err := e.Hash([]byte("angry"), []byte("Monkey"))
+	assert.NoError(err)
+	assert.Equal("ZEHhWB65gUlzdVwtDQArEyx+KVLzp/aTaRaPlBzYRIFj6vjFdqEb0Q5B8zVKCZ0vKbZPZklJz0Fd7su2A+gf7Q==", base64.StdEncoding.EncodeToString(hash))
+}
+
+func TestArgon2idEncryptor(t *testing.T) {
+	assert := assert.New(t)
+
+	e := hasher.NewArgon2idEncryptor(hasher.Argon2Params{Memory: 1024, Iterations: 1, Lanes: 1})
+	assert.Equal(domain.AlgorithmArgon2id, e.Algorithm())
+	assert.Equal("m=1024,t=1,p=1", e.Params())
+	assert.Equal(16, e.SaltLen())
+
+	hash1, err := e.Hash([]byte("angryMonkey"), []byte("saltsaltsaltsalt"))
+	assert.NoError(err)
+	assert.Len(hash1, 32)
+
+	// the same password produces different hashes with different salts
+	hash2, err := e.Hash([]byte("angryMonkey"), []byte("SALTSALTSALTSALT"))
+	assert.NoError(err)
+	assert.NotEqual(hash1, hash2)
+}
+
+func TestBcryptEncryptor(t *testing.T) {
+	assert := assert.New(t)
+
+	e := hasher.NewBcryptEncryptor(hasher.BcryptParams{Cost: 4})
+	assert.Equal(domain.AlgorithmBcrypt, e.Algorithm())
+	assert.Equal("c=4", e.Params())
+	assert.Equal(0, e.SaltLen())
+
+	hash, err := e.Hash([]byte("angryMonkey"), nil)
+	assert.NoError(err)
+	assert.NoError(bcrypt.CompareHashAndPassword(hash, []byte("angryMonkey")))
+	assert.Error(bcrypt.CompareHashAndPassword(hash, []byte("happyMonkey")))
+}
+
+func TestBcryptEncryptorPreHash(t *testing.T) {
+	assert := assert.New(t)
+
+	// passwords with the same first 72 bytes are different after pre-hashing
+	long := strings.Repeat("a", 72)
+	e := hasher.NewBcryptEncryptor(hasher.BcryptParams{Cost: 4, PreHash: true})
+	assert.Equal("c=4,h=sha512", e.Params())
+
+	hash, err := e.Hash([]byte(long+"1"), nil)
+	assert.NoError(err)
+
+	digest := sha512.Sum512([]byte(long + "1"))
+	assert.NoError(bcrypt.CompareHashAndPassword(hash, digest[:]))
+	digest = sha512.Sum512([]byte(long + "2"))
+	assert.Error(bcrypt.CompareHashAndPassword(hash, digest[:]))
+}
+
+func TestScryptEncryptor(t *testing.T) {
+	assert := assert.New(t)
+
+	e := hasher.NewScryptEncryptor(hasher.ScryptParams{N: 16, R: 8, P: 1})
+	assert.Equal(domain.AlgorithmScrypt, e.Algorithm())
+	assert.Equal("ln=4,r=8,p=1", e.Params())
+	assert.Equal(16, e.SaltLen())
+
+	hash1, err := e.Hash([]byte("angryMonkey"), []byte("saltsaltsaltsalt"))
+	assert.NoError(err)
+	assert.Len(hash1, 32)
+
+	hash2, err := e.Hash([]byte("angryMonkey"), []byte("SALTSALTSALTSALT"))
+	assert.NoError(err)
+	assert.NotEqual(hash1, hash2)
+
+	// bad cost parameter
+	e = hasher.NewScryptEncryptor(hasher.ScryptParams{N: 15, R: 8, P: 1})
+	_, err = e.Hash([]byte("angryMonkey"), []byte("saltsaltsaltsalt"))
+	assert.Error(err)
+}
+
+func TestPBKDF2Encryptor(t *testing.T) {
+	assert := assert.New(t)
+
+	params := hasher.PBKDF2Params{Iterations: 1000, SaltLen: 8, KeyLen: 24}
+
+	e := hasher.NewPBKDF2SHA256Encryptor(params)
+	assert.Equal(domain.AlgorithmPBKDF2SHA256, e.Algorithm())
+	assert.Equal("i=1000", e.Params())
+	assert.Equal(8, e.SaltLen())
+
+	hash1, err := e.Hash([]byte("angryMonkey"), []byte("saltsalt"))
+	assert.NoError(err)
+	assert.Len(hash1, 24)
+
+	e = hasher.NewPBKDF2SHA512Encryptor(params)
+	assert.Equal(domain.AlgorithmPBKDF2SHA512, e.Algorithm())
+
+	hash2, err := e.Hash([]byte("angryMonkey"), []byte("saltsalt"))
+	assert.NoError(err)
+	assert.Len(hash2, 24)
+	assert.NotEqual(hash1, hash2)
+}
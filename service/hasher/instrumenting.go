@@ -7,6 +7,9 @@ import (
 	"github.com/plar/hash/service/stats"
 )
 
+// StatsCreate tracks the latency of the hash requests
+const StatsCreate = "Hasher.Create"
+
 type instrumentingService struct {
 	next  Service
 	stats stats.Service
@@ -19,11 +22,46 @@ func NewInstrumentingService(next Service, stats stats.Service) Service {
 	}
 }
 
-func (s *instrumentingService) Create(password string) (domain.HashID, error) {
+func (s *instrumentingService) Create(password string, opts CreateOptions) (domain.HashID, error) {
+	defer func(begin time.Time) {
+		s.stats.TrackMetric(StatsCreate, 1, time.Since(begin))
+	}(time.Now())
+	return s.next.Create(password, opts)
+}
+
+func (s *instrumentingService) Import(encoded string) (domain.HashID, error) {
+	defer func(begin time.Time) {
+		s.stats.TrackMetric("Hasher.Import", 1, time.Since(begin))
+	}(time.Now())
+	return s.next.Import(encoded)
+}
+
+func (s *instrumentingService) NewReliefParams(algorithm domain.Algorithm) (ReliefParams, error) {
+	defer func(begin time.Time) {
+		s.stats.TrackMetric("Hasher.NewReliefParams", 1, time.Since(begin))
+	}(time.Now())
+	return s.next.NewReliefParams(algorithm)
+}
+
+func (s *instrumentingService) ReliefParams(id domain.HashID) (ReliefParams, error) {
+	defer func(begin time.Time) {
+		s.stats.TrackMetric("Hasher.ReliefParams", 1, time.Since(begin))
+	}(time.Now())
+	return s.next.ReliefParams(id)
+}
+
+func (s *instrumentingService) CreateRelief(params ReliefParams, key []byte) (domain.HashID, error) {
+	defer func(begin time.Time) {
+		s.stats.TrackMetric("Hasher.CreateRelief", 1, time.Since(begin))
+	}(time.Now())
+	return s.next.CreateRelief(params, key)
+}
+
+func (s *instrumentingService) VerifyRelief(id domain.HashID, key []byte) (bool, error) {
 	defer func(begin time.Time) {
-		s.stats.TrackMetric("Hasher.Create", 1, time.Since(begin))
+		s.stats.TrackMetric("Hasher.VerifyRelief", 1, time.Since(begin))
 	}(time.Now())
-	return s.next.Create(password)
+	return s.next.VerifyRelief(id, key)
 }
 
 func (s *instrumentingService) Get(id domain.HashID) (domain.Hash, error) {
@@ -33,6 +71,50 @@ func (s *instrumentingService) Get(id domain.HashID) (domain.Hash, error) {
 	return s.next.Get(id)
 }
 
+func (s *instrumentingService) Verify(id domain.HashID, password string) (result VerifyResult, err error) {
+	defer func(begin time.Time) {
+		s.stats.TrackMetric("Hasher.Verify", 1, time.Since(begin))
+		if result.Rehashed {
+			s.stats.TrackMetric("Hasher.Rehash", 1, time.Since(begin))
+		}
+	}(time.Now())
+	return s.next.Verify(id, password)
+}
+
+// Wrap is tracked by the service itself, the migration may run in the background
+func (s *instrumentingService) Wrap() WrapResult {
+	return s.next.Wrap()
+}
+
+func (s *instrumentingService) StartWrap() error {
+	return s.next.StartWrap()
+}
+
+func (s *instrumentingService) WrapProgress() WrapProgress {
+	return s.next.WrapProgress()
+}
+
+func (s *instrumentingService) Calibration() []Calibration {
+	return s.next.Calibration()
+}
+
+func (s *instrumentingService) SelfTest() SelfTestReport {
+	defer func(begin time.Time) {
+		s.stats.TrackMetric("Hasher.SelfTest", 1, time.Since(begin))
+	}(time.Now())
+	return s.next.SelfTest()
+}
+
+func (s *instrumentingService) Probe(timeout time.Duration) (err error) {
+	defer func(begin time.Time) {
+		s.stats.TrackMetric(StatsProbe, 1, time.Since(begin))
+		if err != nil {
+			s.stats.TrackMetric(StatsProbeFailure, 1, time.Since(begin))
+		}
+	}(time.Now())
+	return s.next.Probe(timeout)
+}
+
 func (s *instrumentingService) Stop() {
 	// nothing to collect here
 	s.next.Stop()
@@ -0,0 +1,445 @@
+package hasher
+
+import (
+	"crypto/md5"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/plar/hash/domain"
+)
+
+// ErrImportCost is returned when the cost of an imported hash exceeds the import limits
+var ErrImportCost = errors.New("Imported hash cost exceeds the limit")
+
+// Legacy crypt(3) algorithms are supported for verification of imported hashes only,
+// they are never used for new hashes.
+
+const (
+	cryptAlphabet = "./0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+	md5CryptMagic      = "$1$"
+	apr1CryptMagic     = "$apr1$"
+	md5CryptMaxSalt    = 8
+	md5CryptRounds     = 1000
+	md5CryptHashLen    = 22
+	sha512CryptMagic   = "$6$"
+	sha512CryptMaxSalt = 16
+	sha512CryptHashLen = 86
+
+	ldapSSHA512Prefix = "{SSHA512}"
+
+	sha512CryptDefRounds = 5000
+	sha512CryptMinRounds = 1000
+	sha512CryptMaxRounds = 999999999
+)
+
+// SHA512CryptParams defines sha512-crypt cost parameters
+type SHA512CryptParams struct {
+	// Rounds is the number of rounds, zero means the implicit default of 5000 rounds
+	Rounds int
+}
+
+type sha512CryptEncryptor struct {
+	params SHA512CryptParams
+	// roundsText is the rounds value as written in an imported hash, e.g. 05000 or 10 below the minimum,
+	// empty for the decimal of the clamped rounds
+	roundsText string
+}
+
+// NewSHA512CryptEncryptor creates a new sha512-crypt ($6$) encryptor
+func NewSHA512CryptEncryptor(params SHA512CryptParams) Encryptor {
+	return &sha512CryptEncryptor{
+		params: params,
+	}
+}
+
+func (e *sha512CryptEncryptor) Algorithm() domain.Algorithm {
+	return domain.AlgorithmSHA512Crypt
+}
+
+func (e *sha512CryptEncryptor) Params() string {
+	if e.params.Rounds == 0 {
+		return ""
+	}
+	return fmt.Sprintf("rounds=%d", e.params.Rounds)
+}
+
+// SaltLen returns zero, the salt is a part of the imported hash
+func (e *sha512CryptEncryptor) SaltLen() int {
+	return 0
+}
+
+func (e *sha512CryptEncryptor) Memory() uint64 {
+	return 0
+}
+
+// Hash generates sha512-crypt hash of password in the modular crypt format
+func (e *sha512CryptEncryptor) Hash(password, salt []byte) ([]byte, error) {
+	return sha512Crypt(password, salt, e.params.Rounds, e.roundsText), nil
+}
+
+type md5CryptEncryptor struct {
+	magic string
+}
+
+// NewMD5CryptEncryptor creates a new md5-crypt ($1$) encryptor
+func NewMD5CryptEncryptor() Encryptor {
+	return &md5CryptEncryptor{
+		magic: md5CryptMagic,
+	}
+}
+
+// NewAPR1Encryptor creates a new Apache htpasswd MD5 ($apr1$) encryptor
+func NewAPR1Encryptor() Encryptor {
+	return &md5CryptEncryptor{
+		magic: apr1CryptMagic,
+	}
+}
+
+func (e *md5CryptEncryptor) Algorithm() domain.Algorithm {
+	if e.magic == apr1CryptMagic {
+		return domain.AlgorithmAPR1
+	}
+	return domain.AlgorithmMD5Crypt
+}
+
+func (e *md5CryptEncryptor) Params() string {
+	return ""
+}
+
+// SaltLen returns zero, the salt is a part of the imported hash
+func (e *md5CryptEncryptor) SaltLen() int {
+	return 0
+}
+
+func (e *md5CryptEncryptor) Memory() uint64 {
+	return 0
+}
+
+// Hash generates md5-crypt hash of password in the modular crypt format
+func (e *md5CryptEncryptor) Hash(password, salt []byte) ([]byte, error) {
+	return md5Crypt(password, salt, e.magic), nil
+}
+
+// md5Crypt implements the FreeBSD MD5-based crypt(3) by Poul-Henning Kamp
+func md5Crypt(password, salt []byte, magic string) []byte {
+	if len(salt) > md5CryptMaxSalt {
+		salt = salt[:md5CryptMaxSalt]
+	}
+
+	h := md5.New()
+	h.Write(password)
+	h.Write(salt)
+	h.Write(password)
+	final := h.Sum(nil)
+
+	h.Reset()
+	h.Write(password)
+	h.Write([]byte(magic))
+	h.Write(salt)
+	for pl := len(password); pl > 0; pl -= md5.Size {
+		if pl > md5.Size {
+			h.Write(final)
+		} else {
+			h.Write(final[:pl])
+		}
+	}
+	for i := len(password); i != 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write([]byte{0})
+		} else {
+			h.Write(password[:1])
+		}
+	}
+	final = h.Sum(nil)
+
+	// slow things down
+	for i := 0; i < md5CryptRounds; i++ {
+		h.Reset()
+		if i&1 != 0 {
+			h.Write(password)
+		} else {
+			h.Write(final)
+		}
+		if i%3 != 0 {
+			h.Write(salt)
+		}
+		if i%7 != 0 {
+			h.Write(password)
+		}
+		if i&1 != 0 {
+			h.Write(final)
+		} else {
+			h.Write(password)
+		}
+		final = h.Sum(final[:0])
+	}
+
+	var sb strings.Builder
+	sb.WriteString(magic)
+	sb.Write(salt)
+	sb.WriteString("$")
+	for _, i := range [][3]int{{0, 6, 12}, {1, 7, 13}, {2, 8, 14}, {3, 9, 15}, {4, 10, 5}} {
+		cryptEncode(&sb, final[i[0]], final[i[1]], final[i[2]], 4)
+	}
+	cryptEncode(&sb, 0, 0, final[11], 2)
+	return []byte(sb.String())
+}
+
+// sha512Crypt implements the SHA-512 based crypt(3) by Ulrich Drepper,
+// see https://www.akkadia.org/drepper/SHA-crypt.txt
+// The rounds are written as roundsText if it is not empty, so an imported hash is reproduced as is.
+func sha512Crypt(password, salt []byte, rounds int, roundsText string) []byte {
+	if len(salt) > sha512CryptMaxSalt {
+		salt = salt[:sha512CryptMaxSalt]
+	}
+
+	explicitRounds := rounds != 0
+	if !explicitRounds {
+		rounds = sha512CryptDefRounds
+	} else {
+		rounds = clampSHA512CryptRounds(rounds)
+	}
+	if roundsText == "" {
+		roundsText = strconv.Itoa(rounds)
+	}
+
+	// digest B
+	h := sha512.New()
+	h.Write(password)
+	h.Write(salt)
+	h.Write(password)
+	b := h.Sum(nil)
+
+	// digest A
+	h.Reset()
+	h.Write(password)
+	h.Write(salt)
+	i := len(password)
+	for ; i > sha512.Size; i -= sha512.Size {
+		h.Write(b)
+	}
+	h.Write(b[:i])
+	for i := len(password); i > 0; i >>= 1 {
+		if i&1 != 0 {
+			h.Write(b)
+		} else {
+			h.Write(password)
+		}
+	}
+	a := h.Sum(nil)
+
+	// sequence P
+	h.Reset()
+	for i := 0; i < len(password); i++ {
+		h.Write(password)
+	}
+	p := cryptRepeat(h.Sum(nil), len(password))
+
+	// sequence S
+	h.Reset()
+	for i := 0; i < 16+int(a[0]); i++ {
+		h.Write(salt)
+	}
+	s := cryptRepeat(h.Sum(nil), len(salt))
+
+	c := a
+	for i := 0; i < rounds; i++ {
+		h.Reset()
+		if i&1 != 0 {
+			h.Write(p)
+		} else {
+			h.Write(c)
+		}
+		if i%3 != 0 {
+			h.Write(s)
+		}
+		if i%7 != 0 {
+			h.Write(p)
+		}
+		if i&1 != 0 {
+			h.Write(c)
+		} else {
+			h.Write(p)
+		}
+		c = h.Sum(c[:0])
+	}
+
+	var sb strings.Builder
+	sb.WriteString(sha512CryptMagic)
+	if explicitRounds {
+		fmt.Fprintf(&sb, "rounds=%s$", roundsText)
+	}
+	sb.Write(salt)
+	sb.WriteString("$")
+	for i := 0; i < 21; i++ {
+		// (0, 21, 42), (22, 43, 1), (44, 2, 23), ...
+		cryptEncode(&sb, c[(i*22)%63], c[(i*22+21)%63], c[(i*22+42)%63], 4)
+	}
+	cryptEncode(&sb, 0, 0, c[63], 2)
+	return []byte(sb.String())
+}
+
+// cryptRepeat repeats the digest up to n bytes
+func cryptRepeat(digest []byte, n int) []byte {
+	out := make([]byte, 0, n)
+	for len(out) < n {
+		if n-len(out) >= len(digest) {
+			out = append(out, digest...)
+		} else {
+			out = append(out, digest[:n-len(out)]...)
+		}
+	}
+	return out
+}
+
+// cryptEncode encodes 24 bits with the crypt(3) base64 alphabet, the least significant 6 bits first
+func cryptEncode(sb *strings.Builder, b2, b1, b0 byte, n int) {
+	w := uint(b2)<<16 | uint(b1)<<8 | uint(b0)
+	for ; n > 0; n-- {
+		sb.WriteByte(cryptAlphabet[w&0x3f])
+		w >>= 6
+	}
+}
+
+// clampSHA512CryptRounds clamps the rounds to the range of sha512-crypt like glibc does
+func clampSHA512CryptRounds(rounds int) int {
+	switch {
+	case rounds < sha512CryptMinRounds:
+		return sha512CryptMinRounds
+	case rounds > sha512CryptMaxRounds:
+		return sha512CryptMaxRounds
+	}
+	return rounds
+}
+
+// sha512CryptRoundsText returns the rounds value as written in a sha512-crypt hash, e.g. 05000 of $6$rounds=05000$...,
+// empty for the implicit default rounds
+func sha512CryptRoundsText(encoded string) string {
+	rest := strings.TrimPrefix(encoded, sha512CryptMagic)
+	if !strings.HasPrefix(rest, "rounds=") {
+		return ""
+	}
+	rest = strings.TrimPrefix(rest, "rounds=")
+	if i := strings.IndexByte(rest, '$'); i >= 0 {
+		return rest[:i]
+	}
+	return rest
+}
+
+func isCryptString(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune(cryptAlphabet, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseLegacyHash parses an externally produced hash in the modular crypt format:
+// sha512-crypt ($6$), md5-crypt ($1$), Apache APR1 ($apr1$) or bcrypt ($2a$, $2b$, $2y$),
+// or in the LDAP {SSHA512} format.
+func ParseLegacyHash(encoded string) (domain.Hash, error) {
+	invalid := fmt.Errorf("%w: unsupported or malformed hash", ErrInvalidHash)
+
+	switch {
+	case strings.HasPrefix(encoded, sha512CryptMagic):
+		fields := strings.Split(encoded[len(sha512CryptMagic):], "$")
+		params := ""
+		if len(fields) == 3 && strings.HasPrefix(fields[0], "rounds=") {
+			// glibc clamps the rounds, the parameters are the clamped rounds,
+			// the original text, e.g. leading zeros, is kept in the hash for the comparison
+			text := strings.TrimPrefix(fields[0], "rounds=")
+			rounds, err := strconv.ParseUint(text, 10, 64)
+			if err != nil {
+				return domain.Hash{}, invalid
+			}
+			if rounds > sha512CryptMaxRounds {
+				rounds = sha512CryptMaxRounds
+			}
+			params = fmt.Sprintf("rounds=%d", clampSHA512CryptRounds(int(rounds)))
+			fields = fields[1:]
+		}
+		if len(fields) != 2 || len(fields[0]) > sha512CryptMaxSalt ||
+			len(fields[1]) != sha512CryptHashLen || !isCryptString(fields[1]) {
+			return domain.Hash{}, invalid
+		}
+		return domain.Hash{
+			Hash:      []byte(encoded),
+			Salt:      []byte(fields[0]),
+			Algorithm: domain.AlgorithmSHA512Crypt,
+			Params:    params,
+		}, nil
+
+	case strings.HasPrefix(encoded, md5CryptMagic), strings.HasPrefix(encoded, apr1CryptMagic):
+		algorithm, magic := domain.AlgorithmMD5Crypt, md5CryptMagic
+		if strings.HasPrefix(encoded, apr1CryptMagic) {
+			algorithm, magic = domain.AlgorithmAPR1, apr1CryptMagic
+		}
+		fields := strings.Split(encoded[len(magic):], "$")
+		if len(fields) != 2 || len(fields[0]) > md5CryptMaxSalt ||
+			len(fields[1]) != md5CryptHashLen || !isCryptString(fields[1]) {
+			return domain.Hash{}, invalid
+		}
+		return domain.Hash{
+			Hash:      []byte(encoded),
+			Salt:      []byte(fields[0]),
+			Algorithm: algorithm,
+		}, nil
+
+	case strings.HasPrefix(encoded, ldapSSHA512Prefix):
+		value, err := base64.StdEncoding.DecodeString(encoded[len(ldapSSHA512Prefix):])
+		if err != nil || len(value) <= sha512.Size {
+			return domain.Hash{}, invalid
+		}
+		return domain.Hash{
+			Hash:      value[:sha512.Size],
+			Salt:      value[sha512.Size:],
+			Algorithm: domain.AlgorithmSSHA512,
+		}, nil
+
+	case strings.HasPrefix(encoded, "$2"):
+		cost, err := parseBcryptHash(encoded)
+		if err != nil {
+			return domain.Hash{}, invalid
+		}
+		return domain.Hash{
+			Hash:      []byte(encoded),
+			Algorithm: domain.AlgorithmBcrypt,
+			Params:    fmt.Sprintf("c=%d", cost),
+		}, nil
+	}
+
+	return domain.Hash{}, invalid
+}
+
+// checkImportCost rejects the imported hashes whose cost exceeds the limits,
+// otherwise every verification of such a hash would burn minutes of CPU
+func checkImportCost(hash domain.Hash, maxRounds, maxBcryptCost int) error {
+	switch hash.Algorithm {
+	case domain.AlgorithmSHA512Crypt:
+		rounds := sha512CryptDefRounds
+		if hash.Params != "" {
+			if _, err := fmt.Sscanf(hash.Params, "rounds=%d", &rounds); err != nil {
+				return fmt.Errorf("%w: bad parameters '%v'", ErrInvalidHash, hash.Params)
+			}
+		}
+		if rounds > maxRounds {
+			return fmt.Errorf("%w: %v rounds, at most %v rounds are allowed", ErrImportCost, rounds, maxRounds)
+		}
+
+	case domain.AlgorithmBcrypt:
+		var cost int
+		if _, err := fmt.Sscanf(hash.Params, "c=%d", &cost); err != nil {
+			return fmt.Errorf("%w: bad parameters '%v'", ErrInvalidHash, hash.Params)
+		}
+		if cost > maxBcryptCost {
+			return fmt.Errorf("%w: bcrypt cost %v, at most %v is allowed", ErrImportCost, cost, maxBcryptCost)
+		}
+	}
+	return nil
+}
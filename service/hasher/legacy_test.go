@@ -0,0 +1,279 @@
+package hasher_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/infra/persistence/memory"
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/stats"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestSHA512Crypt(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		password string
+		salt     string
+		rounds   int
+		expected string
+	}{
+		// openssl passwd -6 -salt saltsalt password
+		{"password", "saltsalt", 0, "$6$saltsalt$qFmFH.bQmmtXzyBY0s9v7Oicd2z4XSIecDzlB5KiA2/jctKu9YterLp8wwnSq.qc.eoxqOmSuNp2xS0ktL3nh/"},
+		// https://www.akkadia.org/drepper/SHA-crypt.txt
+		{"Hello world!", "saltstring", 0, "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"},
+		{"Hello world!", "saltstringsaltstring", 10000, "$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v."},
+		{"the minimum number is still observed", "roundstoolow", 10, "$6$rounds=1000$roundstoolow$kUMsbe306n21p9R.FRkW3IGn.S9NPN0x50YhH1xhLsPuWGsUSklZt58jaTfF4ZEQpyUNGc0dqbpBYYBaHHrsX."},
+	}
+
+	for _, test := range tests {
+		e := hasher.NewSHA512CryptEncryptor(hasher.SHA512CryptParams{Rounds: test.rounds})
+		assert.Equal(domain.AlgorithmSHA512Crypt, e.Algorithm())
+
+		hash, err := e.Hash([]byte(test.password), []byte(test.salt))
+		assert.NoError(err)
+		assert.Equal(test.expected, string(hash))
+	}
+}
+
+func TestMD5Crypt(t *testing.T) {
+	assert := assert.New(t)
+
+	// openssl passwd -1 -salt saltsalt password
+	e := hasher.NewMD5CryptEncryptor()
+	assert.Equal(domain.AlgorithmMD5Crypt, e.Algorithm())
+	hash, err := e.Hash([]byte("password"), []byte("saltsalt"))
+	assert.NoError(err)
+	assert.Equal("$1$saltsalt$qjXMvbEw8oaL.CzflDtaK/", string(hash))
+
+	// openssl passwd -apr1 -salt saltsalt password
+	e = hasher.NewAPR1Encryptor()
+	assert.Equal(domain.AlgorithmAPR1, e.Algorithm())
+	hash, err = e.Hash([]byte("password"), []byte("saltsalt"))
+	assert.NoError(err)
+	assert.Equal("$apr1$saltsalt$yAAkm4libquA.ZWLHbSBq/", string(hash))
+}
+
+func TestParseLegacyHash(t *testing.T) {
+	assert := assert.New(t)
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), 4)
+	assert.NoError(err)
+
+	tests := []struct {
+		encoded   string
+		algorithm domain.Algorithm
+		salt      string
+		params    string
+	}{
+		{"$6$saltsalt$qFmFH.bQmmtXzyBY0s9v7Oicd2z4XSIecDzlB5KiA2/jctKu9YterLp8wwnSq.qc.eoxqOmSuNp2xS0ktL3nh/", domain.AlgorithmSHA512Crypt, "saltsalt", ""},
+		{"$6$rounds=1000$roundstoolow$kUMsbe306n21p9R.FRkW3IGn.S9NPN0x50YhH1xhLsPuWGsUSklZt58jaTfF4ZEQpyUNGc0dqbpBYYBaHHrsX.", domain.AlgorithmSHA512Crypt, "roundstoolow", "rounds=1000"},
+		// glibc clamps the rounds, the original text is kept
+		{"$6$rounds=10$roundstoolow$kUMsbe306n21p9R.FRkW3IGn.S9NPN0x50YhH1xhLsPuWGsUSklZt58jaTfF4ZEQpyUNGc0dqbpBYYBaHHrsX.", domain.AlgorithmSHA512Crypt, "roundstoolow", "rounds=1000"},
+		{"$6$rounds=05000$toolongsaltstrin$lQ8jolhgVRVhY4b5pZKaysCLi0QBxGoNeKQzQ3glMhwllF7oGDZxUhx1yxdYcz/e1JSbq3y6JMxxl8audkUEm0", domain.AlgorithmSHA512Crypt, "toolongsaltstrin", "rounds=5000"},
+		{"$1$saltsalt$qjXMvbEw8oaL.CzflDtaK/", domain.AlgorithmMD5Crypt, "saltsalt", ""},
+		{"$apr1$saltsalt$yAAkm4libquA.ZWLHbSBq/", domain.AlgorithmAPR1, "saltsalt", ""},
+		{string(bcryptHash), domain.AlgorithmBcrypt, "", "c=4"},
+		{"$2y$" + string(bcryptHash[4:]), domain.AlgorithmBcrypt, "", "c=4"},
+	}
+
+	for _, test := range tests {
+		hash, err := hasher.ParseLegacyHash(test.encoded)
+		assert.NoError(err, test.encoded)
+		assert.Equal(test.algorithm, hash.Algorithm)
+		assert.Equal(test.encoded, string(hash.Hash))
+		assert.Equal(test.salt, string(hash.Salt))
+		assert.Equal(test.params, hash.Params)
+		phc, err := hash.PHC()
+		assert.NoError(err)
+		assert.Equal(test.encoded, phc)
+	}
+
+	invalid := []string{
+		"",
+		"password",
+		"$5$saltsalt$Ao5bhgdGdIBtUY1ZG5.0/cc.FQqUT7ZK1LX0KkQ5/Q/",
+		"$6$saltsalt$qFmFH.bQmmtXzyBY0s9v7Oicd2z4XSIecDzlB5KiA2/jctKu9YterLp8wwnSq.qc.eoxqOmSuNp2xS0ktL3nh",
+		"$6$saltsalt$qFmFH.bQmmtXzyBY0s9v7Oicd2z4XSIecDzlB5KiA2/jctKu9YterLp8wwnSq.qc.eoxqOmSuNp2xS0ktL3nh!",
+		"$6$rounds=+5000$roundstoolow$kUMsbe306n21p9R.FRkW3IGn.S9NPN0x50YhH1xhLsPuWGsUSklZt58jaTfF4ZEQpyUNGc0dqbpBYYBaHHrsX.",
+		"$6$rounds=$roundstoolow$kUMsbe306n21p9R.FRkW3IGn.S9NPN0x50YhH1xhLsPuWGsUSklZt58jaTfF4ZEQpyUNGc0dqbpBYYBaHHrsX.",
+		"$6$rounds=x$roundstoolow$kUMsbe306n21p9R.FRkW3IGn.S9NPN0x50YhH1xhLsPuWGsUSklZt58jaTfF4ZEQpyUNGc0dqbpBYYBaHHrsX.",
+		"$1$saltsaltsalt$qjXMvbEw8oaL.CzflDtaK/",
+		"$1$saltsalt$qjXMvbEw8oaL.CzflDtaK",
+		"$2x$" + string(bcryptHash[4:]),
+		"$2a$99" + string(bcryptHash[6:]),
+		string(bcryptHash[:59]),
+	}
+	for _, encoded := range invalid {
+		_, err := hasher.ParseLegacyHash(encoded)
+		assert.True(errors.Is(err, hasher.ErrInvalidHash), encoded)
+	}
+}
+
+func TestImport(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, &pepperConfig{}, stats.New())
+	defer svc.Stop()
+
+	_, err := svc.Import("$5$saltsalt$Ao5bhgdGdIBtUY1ZG5.0/cc.FQqUT7ZK1LX0KkQ5/Q/")
+	assert.True(errors.Is(err, hasher.ErrInvalidHash))
+
+	bcryptHash, err := bcrypt.GenerateFromPassword([]byte("password"), 4)
+	assert.NoError(err)
+	roundsHash, err := hasher.NewSHA512CryptEncryptor(hasher.SHA512CryptParams{Rounds: 2000}).Hash([]byte("password"), []byte("saltsalt"))
+	assert.NoError(err)
+
+	for _, encoded := range []string{
+		"$6$saltsalt$qFmFH.bQmmtXzyBY0s9v7Oicd2z4XSIecDzlB5KiA2/jctKu9YterLp8wwnSq.qc.eoxqOmSuNp2xS0ktL3nh/",
+		string(roundsHash),
+		"$1$saltsalt$qjXMvbEw8oaL.CzflDtaK/",
+		"$apr1$saltsalt$yAAkm4libquA.ZWLHbSBq/",
+		string(bcryptHash),
+	} {
+		hashID, err := svc.Import(encoded)
+		assert.NoError(err)
+
+		hash, err := svc.Get(hashID)
+		assert.NoError(err)
+		assert.Equal(encoded, string(hash.Hash))
+		assert.Equal("", hash.KeyID)
+
+		result, err := svc.Verify(hashID, "wrong password")
+		assert.NoError(err)
+		assert.False(result.Valid)
+
+		result, err = svc.Verify(hashID, "password")
+		assert.NoError(err, encoded)
+		assert.True(result.Valid, encoded)
+	}
+}
+
+func TestImportSHA512CryptRounds(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, Config(), stats.New())
+	defer svc.Stop()
+
+	// the Drepper test vectors with rounds below the minimum and the rounds written with a leading zero
+	tests := []struct {
+		encoded  string
+		password string
+	}{
+		{"$6$rounds=10$roundstoolow$kUMsbe306n21p9R.FRkW3IGn.S9NPN0x50YhH1xhLsPuWGsUSklZt58jaTfF4ZEQpyUNGc0dqbpBYYBaHHrsX.", "the minimum number is still observed"},
+		{"$6$rounds=05000$toolongsaltstrin$lQ8jolhgVRVhY4b5pZKaysCLi0QBxGoNeKQzQ3glMhwllF7oGDZxUhx1yxdYcz/e1JSbq3y6JMxxl8audkUEm0", "This is just a test"},
+	}
+	for _, test := range tests {
+		hashID, err := svc.Import(test.encoded)
+		assert.NoError(err, test.encoded)
+
+		result, err := svc.Verify(hashID, test.password+"!")
+		assert.NoError(err)
+		assert.False(result.Valid, test.encoded)
+
+		result, err = svc.Verify(hashID, test.password)
+		assert.NoError(err)
+		assert.True(result.Valid, test.encoded)
+	}
+}
+
+func TestImportSSHA512(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, Config(), stats.New())
+	defer svc.Stop()
+
+	// ssha512 is not selectable for new hashes
+	_, err := svc.Create("password", hasher.CreateOptions{Algorithm: domain.AlgorithmSSHA512})
+	assert.True(errors.Is(err, hasher.ErrAlgorithmNotAllowed))
+
+	for _, encoded := range []string{"{SSHA512}", "{SSHA512}!", "{SSHA512}" + base64.StdEncoding.EncodeToString(make([]byte, 64))} {
+		_, err := svc.Import(encoded)
+		assert.True(errors.Is(err, hasher.ErrInvalidHash), encoded)
+	}
+
+	// sha512("password" || "saltsaltsaltsalt") || "saltsaltsaltsalt"
+	encoded := "{SSHA512}YYvJB6mZFy+oGgGaEbmJNffyjV717Ivr8pgi8lNutfe0KzI8GtBGS+ZeZzlVVsOlmFV4EWpdArxkZsnUyPTmkXNhbHRzYWx0c2FsdHNhbHQ="
+	hashID, err := svc.Import(encoded)
+	assert.NoError(err)
+
+	hash, err := svc.Get(hashID)
+	assert.NoError(err)
+	assert.Equal(domain.AlgorithmSSHA512, hash.Algorithm)
+	assert.Equal("saltsaltsaltsalt", string(hash.Salt))
+	ldap, err := hash.LDAP()
+	assert.NoError(err)
+	assert.Equal(encoded, ldap)
+
+	result, err := svc.Verify(hashID, "wrong password")
+	assert.NoError(err)
+	assert.False(result.Valid)
+
+	result, err = svc.Verify(hashID, "password")
+	assert.NoError(err)
+	assert.True(result.Valid)
+}
+
+type importLimitsConfig struct {
+	testConfig
+}
+
+func (c *importLimitsConfig) ImportMaxRounds() int {
+	return 4000
+}
+
+func (c *importLimitsConfig) ImportMaxBcryptCost() int {
+	return 5
+}
+
+func TestImportCostLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	svc := hasher.New(memory.NewHashRepository(), &importLimitsConfig{}, stats.New())
+	defer svc.Stop()
+
+	hash := strings.Repeat("a", 86)
+	_, err := svc.Import("$6$rounds=4000$saltsalt$" + hash)
+	assert.NoError(err)
+	_, err = svc.Import("$2a$05$" + strings.Repeat("a", 53))
+	assert.NoError(err)
+
+	// the verification of these hashes would take minutes
+	for _, encoded := range []string{
+		"$6$rounds=999999999$saltsalt$" + hash,
+		// the implicit default of 5000 rounds
+		"$6$saltsalt$" + hash,
+		"$2a$31$" + strings.Repeat("a", 53),
+	} {
+		_, err := svc.Import(encoded)
+		assert.True(errors.Is(err, hasher.ErrImportCost), encoded)
+	}
+}
+
+func TestImportRehash(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, &argon2Config{}, stats.New())
+	defer svc.Stop()
+
+	hashID, err := svc.Import("$1$saltsalt$qjXMvbEw8oaL.CzflDtaK/")
+	assert.NoError(err)
+
+	result, err := svc.Verify(hashID, "password")
+	assert.NoError(err)
+	assert.True(result.Valid)
+	assert.True(result.Rehashed)
+
+	hash, err := repo.Load(hashID)
+	assert.NoError(err)
+	assert.Equal(domain.AlgorithmArgon2id, hash.Algorithm)
+}
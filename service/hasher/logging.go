@@ -2,6 +2,7 @@ package hasher
 
 import (
 	"log"
+	"time"
 
 	"github.com/plar/hash/domain"
 )
@@ -17,11 +18,46 @@ func NewLoggingService(next Service) Service {
 	}
 }
 
-func (s *loggingService) Create(password string) (id domain.HashID, err error) {
+func (s *loggingService) Create(password string, opts CreateOptions) (id domain.HashID, err error) {
 	defer func() {
-		log.Printf("the hasher service method=Create => id=%v, err=%v", id, err)
+		log.Printf("the hasher service method=Create algorithm=%v pipeline=%v tenant=%v => id=%v, err=%v", opts.Algorithm, opts.Pipeline, opts.Tenant, id, err)
 	}()
-	return s.next.Create(password)
+	return s.next.Create(password, opts)
+}
+
+func (s *loggingService) Import(encoded string) (id domain.HashID, err error) {
+	defer func() {
+		log.Printf("the hasher service method=Import => id=%v, err=%v", id, err)
+	}()
+	return s.next.Import(encoded)
+}
+
+func (s *loggingService) NewReliefParams(algorithm domain.Algorithm) (params ReliefParams, err error) {
+	defer func() {
+		log.Printf("the hasher service method=NewReliefParams algorithm=%v => algorithm=%v, params=%v, err=%v", algorithm, params.Algorithm, params.Params, err)
+	}()
+	return s.next.NewReliefParams(algorithm)
+}
+
+func (s *loggingService) ReliefParams(id domain.HashID) (params ReliefParams, err error) {
+	defer func() {
+		log.Printf("the hasher service method=ReliefParams id=%v => algorithm=%v, params=%v, err=%v", id, params.Algorithm, params.Params, err)
+	}()
+	return s.next.ReliefParams(id)
+}
+
+func (s *loggingService) CreateRelief(params ReliefParams, key []byte) (id domain.HashID, err error) {
+	defer func() {
+		log.Printf("the hasher service method=CreateRelief algorithm=%v params=%v => id=%v, err=%v", params.Algorithm, params.Params, id, err)
+	}()
+	return s.next.CreateRelief(params, key)
+}
+
+func (s *loggingService) VerifyRelief(id domain.HashID, key []byte) (valid bool, err error) {
+	defer func() {
+		log.Printf("the hasher service method=VerifyRelief id=%v => valid=%v, err=%v", id, valid, err)
+	}()
+	return s.next.VerifyRelief(id, key)
 }
 
 func (s *loggingService) Get(id domain.HashID) (hash domain.Hash, err error) {
@@ -31,6 +67,49 @@ func (s *loggingService) Get(id domain.HashID) (hash domain.Hash, err error) {
 	return s.next.Get(id)
 }
 
+func (s *loggingService) Verify(id domain.HashID, password string) (result VerifyResult, err error) {
+	defer func() {
+		log.Printf("the hasher service method=Verify id=%v => valid=%v, rehashed=%v, err=%v", id, result.Valid, result.Rehashed, err)
+	}()
+	return s.next.Verify(id, password)
+}
+
+func (s *loggingService) Wrap() (result WrapResult) {
+	defer func() {
+		log.Printf("the hasher service method=Wrap => wrapped=%v, skipped=%v", result.Wrapped, result.Skipped)
+	}()
+	return s.next.Wrap()
+}
+
+func (s *loggingService) StartWrap() (err error) {
+	defer func() {
+		log.Printf("the hasher service method=StartWrap => err=%v", err)
+	}()
+	return s.next.StartWrap()
+}
+
+func (s *loggingService) WrapProgress() WrapProgress {
+	return s.next.WrapProgress()
+}
+
+func (s *loggingService) Calibration() []Calibration {
+	return s.next.Calibration()
+}
+
+func (s *loggingService) SelfTest() (report SelfTestReport) {
+	defer func() {
+		log.Printf("the hasher service method=SelfTest => algorithms=%v, err=%v", len(report.Results), report.Err())
+	}()
+	return s.next.SelfTest()
+}
+
+func (s *loggingService) Probe(timeout time.Duration) (err error) {
+	defer func() {
+		log.Printf("the hasher service method=Probe timeout=%v => err=%v", timeout, err)
+	}()
+	return s.next.Probe(timeout)
+}
+
 func (s *loggingService) Stop() {
 	log.Println("the hasher service is stopping")
 	s.next.Stop()
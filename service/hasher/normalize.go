@@ -0,0 +1,65 @@
+package hasher
+
+import (
+	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/plar/hash/domain"
+	"golang.org/x/text/secure/precis"
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrInvalidUnicode is returned when a normalized password is not valid UTF-8 or contains disallowed characters
+var ErrInvalidUnicode = errors.New("Password contains invalid UTF-8 or disallowed characters")
+
+// normalize applies the Unicode normalization mode to password,
+// the password is returned as is for the empty mode.
+func normalize(mode domain.Stage, password []byte) ([]byte, error) {
+	if len(password) == 0 {
+		// nothing to normalize, the empty password is rejected by the password policy
+		return password, nil
+	}
+
+	switch mode {
+	case "":
+		return password, nil
+
+	case domain.StageNFC, domain.StageNFKC:
+		if err := validateUnicode(password); err != nil {
+			return nil, err
+		}
+		if mode == domain.StageNFC {
+			return norm.NFC.Bytes(password), nil
+		}
+		return norm.NFKC.Bytes(password), nil
+
+	case domain.StageOpaqueString:
+		if !utf8.Valid(password) {
+			return nil, fmt.Errorf("%w: invalid UTF-8", ErrInvalidUnicode)
+		}
+		// RFC 8265 section 4.2: width mapping is not applied, non-ASCII spaces are mapped to ASCII spaces,
+		// the string is normalized with NFC, control characters are disallowed
+		normalized, err := precis.OpaqueString.Bytes(password)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidUnicode, err)
+		}
+		return normalized, nil
+	}
+
+	return nil, fmt.Errorf("%w: unknown normalization '%v'", ErrInvalidParams, mode)
+}
+
+// validateUnicode rejects invalid UTF-8 and control characters
+func validateUnicode(password []byte) error {
+	if !utf8.Valid(password) {
+		return fmt.Errorf("%w: invalid UTF-8", ErrInvalidUnicode)
+	}
+	for _, r := range string(password) {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("%w: control character %U", ErrInvalidUnicode, r)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package hasher_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/infra/persistence/memory"
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+type normalizationConfig struct {
+	testConfig
+	mode domain.Stage
+}
+
+func (c *normalizationConfig) Normalization() domain.Stage {
+	return c.mode
+}
+
+func TestNormalization(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		mode     domain.Stage
+		password string
+		equal    []string
+		notEqual []string
+	}{
+		{domain.StageOpaqueString, "\u212Bngstr\u00f6m", []string{"\u00C5ngstr\u00f6m", "A\u030Angstro\u0308m"}, []string{"Angstrom"}},
+		// non-ASCII spaces are mapped to ASCII spaces, width mapping is not applied
+		{domain.StageOpaqueString, "angry\u00A0Monkey", []string{"angry Monkey", "angry\u3000Monkey"}, []string{"angryMonkey"}},
+		{domain.StageOpaqueString, "\uFF21ngryMonkey", nil, []string{"AngryMonkey"}},
+		{domain.StageNFKC, "\uFF21ngryMonkey", []string{"AngryMonkey"}, nil},
+		{domain.StageNFC, "A\u030Angstr\u00f6m", []string{"\u00C5ngstr\u00f6m"}, nil},
+		{"", "A\u030Angstr\u00f6m", nil, []string{"\u00C5ngstr\u00f6m"}},
+	}
+
+	for _, test := range tests {
+		repo := memory.NewHashRepository()
+		svc := hasher.New(repo, &normalizationConfig{mode: test.mode}, stats.New())
+
+		hashID, err := svc.Create(test.password, hasher.CreateOptions{})
+		assert.NoError(err)
+		assert.Equal(test.mode, waitHash(repo, hashID).Normalization)
+
+		for _, password := range append(test.equal, test.password) {
+			result, err := svc.Verify(hashID, password)
+			assert.NoError(err)
+			assert.True(result.Valid, "%v: %q", test.mode, password)
+		}
+		for _, password := range test.notEqual {
+			result, err := svc.Verify(hashID, password)
+			assert.NoError(err)
+			assert.False(result.Valid, "%v: %q", test.mode, password)
+		}
+		svc.Stop()
+	}
+}
+
+func TestNormalizationInvalidUnicode(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, mode := range []domain.Stage{domain.StageOpaqueString, domain.StageNFKC, domain.StageNFC} {
+		repo := memory.NewHashRepository()
+		svc := hasher.New(repo, &normalizationConfig{mode: mode}, stats.New())
+
+		hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+		assert.NoError(err)
+		waitHash(repo, hashID)
+
+		for _, password := range []string{"angry\xffMonkey", "angry\x00Monkey", "angry\tMonkey", "angry\u0085Monkey"} {
+			_, err := svc.Create(password, hasher.CreateOptions{})
+			assert.True(errors.Is(err, hasher.ErrInvalidUnicode), "%v: %q", mode, password)
+
+			// the stored hash is not revealed, the password is a mismatch like for a missing hash
+			result, err := svc.Verify(hashID, password)
+			assert.NoError(err, "%v: %q", mode, password)
+			assert.False(result.Valid, "%v: %q", mode, password)
+		}
+
+		// the empty password is reported by the password policy
+		_, err = svc.Create("", hasher.CreateOptions{})
+		assert.True(errors.Is(err, hasher.ErrPasswordPolicy), mode)
+		svc.Stop()
+	}
+
+	// without normalization passwords are hashed as is
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, Config(), stats.New())
+	defer svc.Stop()
+	_, err := svc.Create("angry\xffMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+}
+
+func TestNormalizationRehash(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, Config(), stats.New())
+	hashID, err := svc.Create("angry\u00A0Monkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	waitHash(repo, hashID)
+	svc.Stop()
+
+	svc = hasher.New(repo, &normalizationConfig{mode: domain.StageOpaqueString}, stats.New())
+	defer svc.Stop()
+
+	// the old hash is verified without normalization
+	result, err := svc.Verify(hashID, "angry Monkey")
+	assert.NoError(err)
+	assert.False(result.Valid)
+
+	result, err = svc.Verify(hashID, "angry\u00A0Monkey")
+	assert.NoError(err)
+	assert.True(result.Valid)
+	assert.True(result.Rehashed)
+
+	hash, err := repo.Load(hashID)
+	assert.NoError(err)
+	assert.Equal(domain.StageOpaqueString, hash.Normalization)
+
+	// both forms are valid now
+	result, err = svc.Verify(hashID, "angry Monkey")
+	assert.NoError(err)
+	assert.True(result.Valid)
+	assert.False(result.Rehashed)
+}
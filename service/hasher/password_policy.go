@@ -0,0 +1,266 @@
+package hasher
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/plar/hash/server/config"
+)
+
+// ErrPasswordPolicy is returned when a new password violates the password policy,
+// the violations are available via PasswordPolicyError.
+var ErrPasswordPolicy = errors.New("Password does not meet the password policy")
+
+// Password policy rule names
+const (
+	RuleMinLength  = "min_length"
+	RuleMaxLength  = "max_length"
+	RuleMinClasses = "min_classes"
+	RuleDenylist   = "denylist"
+	RuleMinEntropy = "min_entropy"
+	RuleBreached   = "breached"
+)
+
+// PolicyViolation is a machine-readable reason of a password policy violation
+type PolicyViolation struct {
+	// Rule is the name of the violated rule
+	Rule string `json:"rule"`
+	// Message is a human-readable description
+	Message string `json:"message"`
+	// Limit is the rule limit, e.g. the minimum length
+	Limit float64 `json:"limit,omitempty"`
+	// Value is the measured value, e.g. the password length
+	Value float64 `json:"value,omitempty"`
+}
+
+// PasswordPolicyError contains all violations of the password policy
+type PasswordPolicyError struct {
+	Violations []PolicyViolation
+}
+
+func (e *PasswordPolicyError) Error() string {
+	rules := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		rules[i] = v.Rule
+	}
+	return fmt.Sprintf("%v: %v", ErrPasswordPolicy, strings.Join(rules, ", "))
+}
+
+func (e *PasswordPolicyError) Unwrap() error {
+	return ErrPasswordPolicy
+}
+
+// PasswordRule is a single rule of the password policy
+type PasswordRule interface {
+	// Check returns the rule violation, nil if the password satisfies the rule
+	Check(password string) *PolicyViolation
+}
+
+// PasswordPolicy checks new passwords against all its rules
+type PasswordPolicy struct {
+	rules []PasswordRule
+}
+
+// NewPasswordPolicy creates a password policy with the rules
+func NewPasswordPolicy(rules ...PasswordRule) *PasswordPolicy {
+	return &PasswordPolicy{
+		rules: rules,
+	}
+}
+
+// NewPasswordPolicyFromConfig creates a password policy with the configured built-in rules
+func NewPasswordPolicyFromConfig(cfg config.Config) *PasswordPolicy {
+	rules := []PasswordRule{
+		MinLengthRule(cfg.PasswordMinLength()),
+	}
+	if cfg.PasswordMaxLength() > 0 {
+		rules = append(rules, MaxLengthRule(cfg.PasswordMaxLength()))
+	}
+	if cfg.PasswordMinClasses() > 0 {
+		rules = append(rules, MinClassesRule(cfg.PasswordMinClasses()))
+	}
+	if len(cfg.PasswordDenylist()) > 0 {
+		rules = append(rules, NewDenylistRule(cfg.PasswordDenylist()))
+	}
+	if cfg.PasswordMinEntropy() > 0 {
+		rules = append(rules, MinEntropyRule(cfg.PasswordMinEntropy()))
+	}
+	return NewPasswordPolicy(rules...)
+}
+
+// Check returns PasswordPolicyError with all violations, nil if the password satisfies the policy
+func (p *PasswordPolicy) Check(password string) error {
+	if violations := p.Violations(password); len(violations) > 0 {
+		return &PasswordPolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// Violations returns all violations of the policy
+func (p *PasswordPolicy) Violations(password string) []PolicyViolation {
+	var violations []PolicyViolation
+	for _, rule := range p.rules {
+		if v := rule.Check(password); v != nil {
+			violations = append(violations, *v)
+		}
+	}
+	return violations
+}
+
+// MinLengthRule is the minimum password length in characters
+type MinLengthRule int
+
+func (r MinLengthRule) Check(password string) *PolicyViolation {
+	if n := utf8.RuneCountInString(password); n < int(r) {
+		return &PolicyViolation{
+			Rule:    RuleMinLength,
+			Message: fmt.Sprintf("Password must be at least %v characters long", int(r)),
+			Limit:   float64(r),
+			Value:   float64(n),
+		}
+	}
+	return nil
+}
+
+// MaxLengthRule is the maximum password length in characters
+type MaxLengthRule int
+
+func (r MaxLengthRule) Check(password string) *PolicyViolation {
+	if n := utf8.RuneCountInString(password); n > int(r) {
+		return &PolicyViolation{
+			Rule:    RuleMaxLength,
+			Message: fmt.Sprintf("Password must be at most %v characters long", int(r)),
+			Limit:   float64(r),
+			Value:   float64(n),
+		}
+	}
+	return nil
+}
+
+// Character classes of passwords
+const (
+	classLower = 1 << iota
+	classUpper
+	classDigit
+	classSymbol
+	classOther
+)
+
+// charClasses returns the bit set of the character classes used by password,
+// non-ASCII characters also set classOther to extend the alphabet of the entropy estimation.
+func charClasses(password string) int {
+	classes := 0
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			classes |= classLower
+		case r >= 'A' && r <= 'Z':
+			classes |= classUpper
+		case r >= '0' && r <= '9':
+			classes |= classDigit
+		case r < utf8.RuneSelf && unicode.IsPrint(r):
+			classes |= classSymbol
+		case unicode.IsLower(r):
+			classes |= classLower | classOther
+		case unicode.IsUpper(r):
+			classes |= classUpper | classOther
+		default:
+			classes |= classSymbol | classOther
+		}
+	}
+	return classes
+}
+
+// MinClassesRule is the minimum number of character classes: lower case, upper case, digits and symbols.
+// Non-ASCII letters count as lower or upper case, other non-ASCII characters count as symbols.
+type MinClassesRule int
+
+func (r MinClassesRule) Check(password string) *PolicyViolation {
+	classes := charClasses(password)
+	n := 0
+	for _, class := range []int{classLower, classUpper, classDigit, classSymbol} {
+		if classes&class != 0 {
+			n++
+		}
+	}
+	if n < int(r) {
+		return &PolicyViolation{
+			Rule:    RuleMinClasses,
+			Message: fmt.Sprintf("Password must contain at least %v of lower case letters, upper case letters, digits and symbols", int(r)),
+			Limit:   float64(r),
+			Value:   float64(n),
+		}
+	}
+	return nil
+}
+
+// DenylistRule rejects commonly used or compromised passwords, the comparison is case-insensitive
+type DenylistRule struct {
+	denylist map[string]bool
+}
+
+// NewDenylistRule creates a denylist rule of the passwords
+func NewDenylistRule(passwords []string) *DenylistRule {
+	r := &DenylistRule{
+		denylist: make(map[string]bool, len(passwords)),
+	}
+	for _, password := range passwords {
+		r.denylist[strings.ToLower(password)] = true
+	}
+	return r
+}
+
+func (r *DenylistRule) Check(password string) *PolicyViolation {
+	if r.denylist[strings.ToLower(password)] {
+		return &PolicyViolation{
+			Rule:    RuleDenylist,
+			Message: "Password is too common",
+		}
+	}
+	return nil
+}
+
+// MinEntropyRule is the minimum estimated password entropy in bits
+type MinEntropyRule int
+
+func (r MinEntropyRule) Check(password string) *PolicyViolation {
+	if bits := PasswordEntropy(password); bits < float64(r) {
+		return &PolicyViolation{
+			Rule:    RuleMinEntropy,
+			Message: fmt.Sprintf("Password is too weak, the estimated strength is %.0f bits of at least %v", math.Floor(bits), int(r)),
+			Limit:   float64(r),
+			Value:   math.Floor(bits),
+		}
+	}
+	return nil
+}
+
+// PasswordEntropy estimates the password entropy in bits as length * log2(alphabet size),
+// where the alphabet is the union of the character classes used by the password.
+// Consecutive repeated characters do not add entropy.
+func PasswordEntropy(password string) float64 {
+	classes := charClasses(password)
+	alphabet := 0
+	for class, size := range map[int]int{classLower: 26, classUpper: 26, classDigit: 10, classSymbol: 33, classOther: 100} {
+		if classes&class != 0 {
+			alphabet += size
+		}
+	}
+	if alphabet == 0 {
+		return 0
+	}
+
+	length := 0
+	var prev rune
+	for i, r := range password {
+		if i == 0 || r != prev {
+			length++
+		}
+		prev = r
+	}
+	return float64(length) * math.Log2(float64(alphabet))
+}
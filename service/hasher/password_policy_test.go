@@ -0,0 +1,115 @@
+package hasher_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/plar/hash/infra/persistence/memory"
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordRules(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		rule     hasher.PasswordRule
+		password string
+		valid    bool
+	}{
+		{hasher.MinLengthRule(8), "angryMon", true},
+		{hasher.MinLengthRule(8), "angryMo", false},
+		// characters, not bytes
+		{hasher.MinLengthRule(8), "ÅÅÅÅ", false},
+		{hasher.MaxLengthRule(8), "ÅÅÅÅÅÅÅÅ", true},
+		{hasher.MaxLengthRule(8), "angryMonk", false},
+		{hasher.MinClassesRule(3), "angryMonkey1", true},
+		{hasher.MinClassesRule(3), "angry-monkey1", true},
+		{hasher.MinClassesRule(3), "angryMonkey", false},
+		{hasher.MinClassesRule(3), "ångström-1", true},
+		{hasher.MinClassesRule(4), "Ångström-1", true},
+		{hasher.NewDenylistRule([]string{"password", "123456"}), "PassWord", false},
+		{hasher.NewDenylistRule([]string{"password", "123456"}), "password1", true},
+		{hasher.MinEntropyRule(40), "angryMonkey", true},
+		{hasher.MinEntropyRule(40), "aaaaaaaaaaaaaaaaaaaaaaaa", false},
+		{hasher.MinEntropyRule(40), "abcdefgh", false},
+	}
+
+	for _, test := range tests {
+		v := test.rule.Check(test.password)
+		assert.Equal(test.valid, v == nil, "%T(%v): %v", test.rule, test.rule, test.password)
+	}
+
+	assert.Equal(float64(0), hasher.PasswordEntropy(""))
+	assert.InDelta(11*5.7, hasher.PasswordEntropy("angryMonkey"), 0.1)
+	assert.Equal(hasher.PasswordEntropy("ab"), hasher.PasswordEntropy("aaabbb"))
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	policy := hasher.NewPasswordPolicy(
+		hasher.MinLengthRule(12),
+		hasher.MinClassesRule(3),
+		hasher.NewDenylistRule([]string{"password"}),
+		hasher.MinEntropyRule(60),
+	)
+	assert.NoError(policy.Check("angryMonkey-42"))
+
+	err := policy.Check("password")
+	assert.True(errors.Is(err, hasher.ErrPasswordPolicy))
+
+	var policyErr *hasher.PasswordPolicyError
+	assert.True(errors.As(err, &policyErr))
+	assert.Equal([]hasher.PolicyViolation{
+		{Rule: hasher.RuleMinLength, Message: "Password must be at least 12 characters long", Limit: 12, Value: 8},
+		{Rule: hasher.RuleMinClasses, Message: "Password must contain at least 3 of lower case letters, upper case letters, digits and symbols", Limit: 3, Value: 1},
+		{Rule: hasher.RuleDenylist, Message: "Password is too common"},
+		{Rule: hasher.RuleMinEntropy, Message: "Password is too weak, the estimated strength is 32 bits of at least 60", Limit: 60, Value: 32},
+	}, policyErr.Violations)
+	assert.Equal("Password does not meet the password policy: min_length, min_classes, denylist, min_entropy", err.Error())
+}
+
+type passwordPolicyConfig struct {
+	testConfig
+}
+
+func (c *passwordPolicyConfig) PasswordMinLength() int {
+	return 8
+}
+
+func (c *passwordPolicyConfig) PasswordMaxLength() int {
+	return 64
+}
+
+func (c *passwordPolicyConfig) PasswordDenylist() []string {
+	return []string{"password1"}
+}
+
+func TestCreatePasswordPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, &passwordPolicyConfig{}, stats.New())
+	defer svc.Stop()
+
+	// the empty password is reported by the minimum length rule
+	var policyErr *hasher.PasswordPolicyError
+	_, err := svc.Create("", hasher.CreateOptions{})
+	assert.True(errors.As(err, &policyErr))
+	assert.Equal([]hasher.PolicyViolation{{Rule: hasher.RuleMinLength, Message: "Password must be at least 8 characters long", Limit: 8}}, policyErr.Violations)
+
+	_, err = svc.Create("angry", hasher.CreateOptions{})
+	assert.True(errors.As(err, &policyErr))
+	assert.Equal(hasher.RuleMinLength, policyErr.Violations[0].Rule)
+
+	_, err = svc.Create("Password1", hasher.CreateOptions{})
+	assert.True(errors.As(err, &policyErr))
+	assert.Equal(hasher.RuleDenylist, policyErr.Violations[0].Rule)
+
+	// rejected passwords do not consume hash IDs
+	hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	assert.EqualValues(1, hashID)
+}
@@ -0,0 +1,101 @@
+package hasher
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"fmt"
+	"hash"
+
+	"github.com/plar/hash/domain"
+)
+
+// PBKDF2Params defines PBKDF2 cost parameters
+type PBKDF2Params struct {
+	// Iterations is the number of HMAC iterations
+	Iterations int
+	// SaltLen is the length of the random salt in bytes
+	SaltLen int
+	// KeyLen is the length of the derived key in bytes
+	KeyLen int
+}
+
+type pbkdf2Encryptor struct {
+	algorithm domain.Algorithm
+	hashFn    func() hash.Hash
+	params    PBKDF2Params
+}
+
+// NewPBKDF2SHA256Encryptor creates a new PBKDF2-HMAC-SHA256 encryptor with the cost parameters
+func NewPBKDF2SHA256Encryptor(params PBKDF2Params) Encryptor {
+	return &pbkdf2Encryptor{
+		algorithm: domain.AlgorithmPBKDF2SHA256,
+		hashFn:    sha256.New,
+		params:    params,
+	}
+}
+
+// NewPBKDF2SHA512Encryptor creates a new PBKDF2-HMAC-SHA512 encryptor with the cost parameters
+func NewPBKDF2SHA512Encryptor(params PBKDF2Params) Encryptor {
+	return &pbkdf2Encryptor{
+		algorithm: domain.AlgorithmPBKDF2SHA512,
+		hashFn:    sha512.New,
+		params:    params,
+	}
+}
+
+func (e *pbkdf2Encryptor) Algorithm() domain.Algorithm {
+	return e.algorithm
+}
+
+func (e *pbkdf2Encryptor) Params() string {
+	return fmt.Sprintf("i=%d", e.params.Iterations)
+}
+
+func (e *pbkdf2Encryptor) SaltLen() int {
+	return e.params.SaltLen
+}
+
+func (e *pbkdf2Encryptor) Memory() uint64 {
+	return 0
+}
+
+// Hash generates PBKDF2 key of password
+func (e *pbkdf2Encryptor) Hash(password, salt []byte) ([]byte, error) {
+	return pbkdf2Key(password, salt, e.params.Iterations, e.params.KeyLen, e.hashFn), nil
+}
+
+// pbkdf2Key derives a key from password as defined in RFC 8018, section 5.2.
+// Only the standard library primitives are used to keep FIPS-constrained deployments happy.
+func pbkdf2Key(password, salt []byte, iter, keyLen int, hashFn func() hash.Hash) []byte {
+	prf := hmac.New(hashFn, password)
+	hashLen := prf.Size()
+	numBlocks := (keyLen + hashLen - 1) / hashLen
+
+	var buf [4]byte
+	dk := make([]byte, 0, numBlocks*hashLen)
+	u := make([]byte, hashLen)
+	for block := 1; block <= numBlocks; block++ {
+		// U_1 = PRF(password, salt || INT(i))
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(buf[:], uint32(block))
+		prf.Write(buf[:4])
+		dk = prf.Sum(dk)
+		t := dk[len(dk)-hashLen:]
+		copy(u, t)
+
+		// T_i = U_1 ^ U_2 ^ ... ^ U_c
+		for n := 2; n <= iter; n++ {
+			prf.Reset()
+			prf.Write(u)
+			u = u[:0]
+			u = prf.Sum(u)
+			for x := range u {
+				t[x] ^= u[x]
+			}
+		}
+	}
+	return dk[:keyLen]
+}
@@ -0,0 +1,38 @@
+package hasher
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPBKDF2Key(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		password string
+		salt     string
+		iter     int
+		keyLen   int
+		sha512   bool
+		expected string
+	}{
+		{"password", "salt", 1, 32, false, "120fb6cffcf8b32c43e7225256c4f837a86548c92ccc35480805987cb70be17b"},
+		{"password", "salt", 2, 32, false, "ae4d0c95af6b46d32d0adff928f06dd02a303f8ef3c251dfd6e2d85a95474c43"},
+		{"password", "salt", 4096, 32, false, "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+		{"passwordPASSWORDpassword", "saltSALTsaltSALTsaltSALTsaltSALTsalt", 4096, 40, false, "348c89dbcbd32b2f32d814b8116e84cf2b17347ebc1800181c4e2a1fb8dd53e1c635518c7dac47e9"},
+		{"password", "salt", 1, 64, true, "867f70cf1ade02cff3752599a3a53dc4af34c7a669815ae5d513554e1c8cf252c02d470a285a0501bad999bfe943c08f050235d7d68b1da55e63f73b60a57fce"},
+	}
+
+	for _, tt := range tests {
+		hashFn := sha256.New
+		if tt.sha512 {
+			hashFn = sha512.New
+		}
+		key := pbkdf2Key([]byte(tt.password), []byte(tt.salt), tt.iter, tt.keyLen, hashFn)
+		assert.Equal(tt.expected, hex.EncodeToString(key))
+	}
+}
@@ -0,0 +1,64 @@
+package hasher
+
+import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+
+	"github.com/plar/hash/server/config"
+)
+
+// ErrUnknownPepperKey is returned when a stored hash refers to a missing pepper key
+var ErrUnknownPepperKey = errors.New("Unknown pepper key")
+
+// pepper mixes a server-side secret into passwords with HMAC-SHA512,
+// so a leaked repository alone is not enough for offline cracking.
+type pepper struct {
+	keys    map[string][]byte
+	current string
+}
+
+// newPepper creates a pepper from the configured keys, the last key is used for new hashes.
+// Without keys the pepper is disabled.
+func newPepper(keys []config.PepperKey) *pepper {
+	p := &pepper{
+		keys: make(map[string][]byte, len(keys)),
+	}
+	for _, key := range keys {
+		p.keys[key.ID] = key.Secret
+		p.current = key.ID
+	}
+	return p
+}
+
+// CurrentKeyID returns the key ID for new hashes, empty if the pepper is disabled
+func (p *pepper) CurrentKeyID() string {
+	return p.current
+}
+
+// Secret returns the secret of the key
+func (p *pepper) Secret(keyID string) ([]byte, error) {
+	secret, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%v'", ErrUnknownPepperKey, keyID)
+	}
+	return secret, nil
+}
+
+// Apply returns HMAC-SHA512 of password with the key,
+// the password is returned as is for the empty key ID.
+func (p *pepper) Apply(keyID string, password []byte) ([]byte, error) {
+	if keyID == "" {
+		return password, nil
+	}
+
+	secret, err := p.Secret(keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha512.New, secret)
+	mac.Write(password)
+	return mac.Sum(nil), nil
+}
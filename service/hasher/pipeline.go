@@ -0,0 +1,132 @@
+package hasher
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"errors"
+	"hash"
+
+	"github.com/plar/hash/domain"
+)
+
+// ErrUnknownPipeline is returned when a requested pipeline is not configured
+var ErrUnknownPipeline = errors.New("Unknown pipeline")
+
+// ErrPipelineAlgorithm is returned when both a pipeline and an algorithm are requested
+var ErrPipelineAlgorithm = errors.New("Pipeline and algorithm are mutually exclusive")
+
+// pipelineEncryptor runs the password through the pipeline stages around the KDF encryptor
+type pipelineEncryptor struct {
+	pipeline domain.Pipeline
+	kdf      Encryptor
+	key      []byte
+}
+
+// newPipelineEncryptor creates an encryptor of the pipeline with the pepper key secret, nil if none.
+// The key is used by the HMAC stage, without the HMAC stage it is mixed into the KDF input
+// like the pepper of plain algorithms.
+func newPipelineEncryptor(pipeline domain.Pipeline, kdf Encryptor, key []byte) Encryptor {
+	return &pipelineEncryptor{
+		pipeline: pipeline,
+		kdf:      kdf,
+		key:      key,
+	}
+}
+
+func (e *pipelineEncryptor) Algorithm() domain.Algorithm {
+	return e.kdf.Algorithm()
+}
+
+func (e *pipelineEncryptor) Params() string {
+	return e.kdf.Params()
+}
+
+func (e *pipelineEncryptor) SaltLen() int {
+	return e.kdf.SaltLen()
+}
+
+func (e *pipelineEncryptor) Memory() uint64 {
+	return e.kdf.Memory()
+}
+
+// Validate checks the KDF input restrictions after the stages before the KDF
+func (e *pipelineEncryptor) Validate(password []byte) error {
+	if v, ok := e.kdf.(passwordValidator); ok {
+		input, err := e.prepare(password)
+		if err != nil {
+			return err
+		}
+		return v.Validate(input)
+	}
+	return nil
+}
+
+// Verify runs the pipeline and compares the result with the stored hash
+func (e *pipelineEncryptor) Verify(password []byte, hash domain.Hash) (bool, error) {
+	if v, ok := e.kdf.(passwordVerifier); ok {
+		// the HMAC stage is not allowed after modular crypt algorithms
+		input, err := e.prepare(password)
+		if err != nil {
+			return false, err
+		}
+		return v.Verify(input, hash)
+	}
+
+	calculated, err := e.Hash(password, hash.Salt)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(calculated, hash.Hash) == 1, nil
+}
+
+// Hash runs password through all pipeline stages
+func (e *pipelineEncryptor) Hash(password, salt []byte) ([]byte, error) {
+	input, err := e.prepare(password)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := e.kdf.Hash(input, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.pipeline.HMAC == "" {
+		return key, nil
+	}
+	mac := hmac.New(stageHashFn(e.pipeline.HMAC), e.key)
+	mac.Write(key)
+	return mac.Sum(nil), nil
+}
+
+// prepare runs the normalization and pre-hash stages and mixes the pepper key into the KDF input
+func (e *pipelineEncryptor) prepare(password []byte) ([]byte, error) {
+	password, err := normalize(e.pipeline.Normalize, password)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.pipeline.PreHash != "" {
+		h := stageHashFn(e.pipeline.PreHash)()
+		h.Write(password)
+		password = h.Sum(nil)
+	}
+
+	if e.pipeline.HMAC == "" && e.key != nil {
+		mac := hmac.New(sha512.New, e.key)
+		mac.Write(password)
+		password = mac.Sum(nil)
+	}
+	return password, nil
+}
+
+func stageHashFn(stage domain.Stage) func() hash.Hash {
+	switch stage {
+	case domain.StageSHA256, domain.StageHMACSHA256:
+		return sha256.New
+	default:
+		return sha512.New
+	}
+}
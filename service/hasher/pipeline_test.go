@@ -0,0 +1,168 @@
+package hasher_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"testing"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/infra/persistence/memory"
+	"github.com/plar/hash/server/config"
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/stats"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/argon2"
+)
+
+type pipelineConfig struct {
+	pepperConfig
+	pipeline string
+}
+
+func (c *pipelineConfig) Argon2Memory() uint32 {
+	return 1024
+}
+
+func (c *pipelineConfig) AllowedAlgorithms() []domain.Algorithm {
+	return domain.Algorithms()
+}
+
+func (c *pipelineConfig) Pipelines() map[string]domain.Pipeline {
+	strict, _ := domain.ParsePipeline("nfkc+sha512+argon2id+hmac-sha256")
+	legacy, _ := domain.ParsePipeline("sha512+bcrypt")
+	normalized, _ := domain.ParsePipeline("nfc+argon2id")
+	return map[string]domain.Pipeline{
+		"strict":     strict,
+		"legacy":     legacy,
+		"normalized": normalized,
+	}
+}
+
+func (c *pipelineConfig) Pipeline() string {
+	return c.pipeline
+}
+
+func (c *pipelineConfig) BcryptCost() int {
+	return 4
+}
+
+var pipelineKey = config.PepperKey{ID: "k1", Secret: []byte("secretsecretsecret")}
+
+func TestCreatePipeline(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	cfg := &pipelineConfig{pepperConfig: pepperConfig{keys: []config.PepperKey{pipelineKey}}}
+	svc := hasher.New(repo, cfg, stats.New())
+	defer svc.Stop()
+
+	// U+212B ANGSTROM SIGN and the decomposed A + U+030A are turned into U+00C5 by NFKC
+	hashID, err := svc.Create("\u212Bngstr\u00f6m", hasher.CreateOptions{Pipeline: "strict"})
+	assert.NoError(err)
+	hash := waitHash(repo, hashID)
+	assert.Equal(domain.AlgorithmArgon2id, hash.Algorithm)
+	assert.Equal("m=1024,t=1,p=2", hash.Params)
+	assert.Equal("k1", hash.KeyID)
+	assert.Equal("nfkc+sha512+argon2id+hmac-sha256", hash.Pipeline)
+
+	digest := sha512.Sum512([]byte("\u00C5ngstr\u00f6m"))
+	mac := hmac.New(sha256.New, pipelineKey.Secret)
+	mac.Write(argon2.IDKey(digest[:], hash.Salt, 1, 1024, 2, 32))
+	assert.Equal(mac.Sum(nil), hash.Hash)
+
+	// all forms are equal after the normalization
+	for _, password := range []string{"\u212Bngstr\u00f6m", "\u00C5ngstr\u00f6m", "A\u030Angstro\u0308m"} {
+		result, err := svc.Verify(hashID, password)
+		assert.NoError(err)
+		assert.True(result.Valid, password)
+	}
+	result, err := svc.Verify(hashID, "Angstrom")
+	assert.NoError(err)
+	assert.False(result.Valid)
+
+	// the pre-hash stage lifts the bcrypt input limit
+	long := string(make([]byte, 100)) + "x"
+	hashID, err = svc.Create(long, hasher.CreateOptions{Pipeline: "legacy"})
+	assert.NoError(err)
+	hash = waitHash(repo, hashID)
+	assert.Equal(domain.AlgorithmBcrypt, hash.Algorithm)
+	assert.Equal("sha512+bcrypt", hash.Pipeline)
+
+	result, err = svc.Verify(hashID, long)
+	assert.NoError(err)
+	assert.True(result.Valid)
+	result, err = svc.Verify(hashID, long[:99])
+	assert.NoError(err)
+	assert.False(result.Valid)
+
+	// the pepper key is mixed into the KDF input without the HMAC stage
+	hashID, err = svc.Create("angryMonkey", hasher.CreateOptions{Pipeline: "normalized"})
+	assert.NoError(err)
+	hash = waitHash(repo, hashID)
+	mac = hmac.New(sha512.New, pipelineKey.Secret)
+	mac.Write([]byte("angryMonkey"))
+	assert.Equal(argon2.IDKey(mac.Sum(nil), hash.Salt, 1, 1024, 2, 32), hash.Hash)
+
+	// plain algorithm
+	hashID, err = svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	hash = waitHash(repo, hashID)
+	assert.Equal(domain.AlgorithmSHA512, hash.Algorithm)
+	assert.Equal("", hash.Pipeline)
+
+	_, err = svc.Create("angryMonkey", hasher.CreateOptions{Pipeline: "missing"})
+	assert.True(errors.Is(err, hasher.ErrUnknownPipeline))
+
+	_, err = svc.Create("angryMonkey", hasher.CreateOptions{Pipeline: "strict", Algorithm: domain.AlgorithmArgon2id})
+	assert.True(errors.Is(err, hasher.ErrPipelineAlgorithm))
+}
+
+type pipelineRehashConfig struct {
+	pipelineConfig
+}
+
+func (c *pipelineRehashConfig) RehashOnVerify() bool {
+	return true
+}
+
+func TestVerifyRehashPipeline(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	cfg := &pipelineRehashConfig{pipelineConfig{pepperConfig: pepperConfig{keys: []config.PepperKey{pipelineKey}}}}
+	svc := hasher.New(repo, cfg, stats.New())
+	hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	waitHash(repo, hashID)
+	svc.Stop()
+
+	// the default pipeline has been configured
+	cfg.pipeline = "strict"
+	svc = hasher.New(repo, cfg, stats.New())
+	defer svc.Stop()
+
+	result, err := svc.Verify(hashID, "angryMonkey")
+	assert.NoError(err)
+	assert.True(result.Valid)
+	assert.True(result.Rehashed)
+
+	hash, err := repo.Load(hashID)
+	assert.NoError(err)
+	assert.Equal("nfkc+sha512+argon2id+hmac-sha256", hash.Pipeline)
+
+	result, err = svc.Verify(hashID, "angryMonkey")
+	assert.NoError(err)
+	assert.True(result.Valid)
+	assert.False(result.Rehashed)
+
+	// new hashes use the default pipeline, an explicit algorithm bypasses it
+	hashID, err = svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	assert.Equal("nfkc+sha512+argon2id+hmac-sha256", waitHash(repo, hashID).Pipeline)
+
+	hashID, err = svc.Create("angryMonkey", hasher.CreateOptions{Algorithm: domain.AlgorithmArgon2id})
+	assert.NoError(err)
+	assert.Equal("", waitHash(repo, hashID).Pipeline)
+}
@@ -0,0 +1,89 @@
+package hasher
+
+import (
+	"hash/fnv"
+	"log"
+	"strconv"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/server/config"
+)
+
+// StatsPolicyPrefix is the metric name prefix of the hash execution time per policy arm
+const StatsPolicyPrefix = "Policy."
+
+// policy selects the arm of a new hash: an explicit override, the arm of the tenant,
+// the arm of the percentage bucket of the hash ID or the default arm.
+type policy struct {
+	arms    map[string]config.PolicyArm
+	rollout []config.PolicyRollout
+	tenants map[string]string
+}
+
+// newPolicy creates a rollout policy, the arms which cannot be created by the registry are replaced by the default arm
+func newPolicy(cfg config.Config, registry *Registry) *policy {
+	p := &policy{
+		arms:    make(map[string]config.PolicyArm),
+		rollout: cfg.PolicyRollout(),
+		tenants: cfg.PolicyTenants(),
+	}
+	for _, arm := range cfg.PolicyArms() {
+		if _, err := registry.NewWithParams(arm.Algorithm, arm.Params); err != nil {
+			log.Printf("the hasher service disabled policy arm=%v: %v", arm.Name, err)
+			continue
+		}
+		p.arms[arm.Name] = arm
+	}
+	return p
+}
+
+// Select returns the arm name of a new hash
+func (p *policy) Select(id domain.HashID, opts CreateOptions) string {
+	if opts.Algorithm != "" || opts.Pipeline != "" {
+		return config.PolicyArmOverride
+	}
+
+	if arm, ok := p.tenants[opts.Tenant]; ok && opts.Tenant != "" {
+		return p.arm(arm)
+	}
+
+	bucket := policyBucket(id)
+	for _, rollout := range p.rollout {
+		if bucket < rollout.Percent {
+			return p.arm(rollout.Arm)
+		}
+		bucket -= rollout.Percent
+	}
+	return config.PolicyArmDefault
+}
+
+// Arm returns the arm by name, false for the default and override arms
+func (p *policy) Arm(name string) (config.PolicyArm, bool) {
+	arm, ok := p.arms[name]
+	return arm, ok
+}
+
+// Arms returns all named arms
+func (p *policy) Arms() []config.PolicyArm {
+	arms := make([]config.PolicyArm, 0, len(p.arms))
+	for _, arm := range p.arms {
+		arms = append(arms, arm)
+	}
+	return arms
+}
+
+// arm returns the arm name if the arm is enabled, otherwise the default arm
+func (p *policy) arm(name string) string {
+	if _, ok := p.arms[name]; ok {
+		return name
+	}
+	return config.PolicyArmDefault
+}
+
+// policyBucket returns the deterministic percentage bucket [0..100) of the hash ID,
+// sequential IDs are spread over the buckets by FNV-1a.
+func policyBucket(id domain.HashID) int {
+	h := fnv.New32a()
+	h.Write([]byte(strconv.FormatInt(int64(id), 10)))
+	return int(h.Sum32() % 100)
+}
@@ -0,0 +1,155 @@
+package hasher_test
+
+import (
+	"testing"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/infra/persistence/memory"
+	"github.com/plar/hash/server/config"
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+type policyConfig struct {
+	testConfig
+	rollout []config.PolicyRollout
+}
+
+func (c *policyConfig) PolicyArms() []config.PolicyArm {
+	return []config.PolicyArm{
+		{Name: "argon2", Algorithm: domain.AlgorithmArgon2id, Params: "m=1024,t=1,p=1"},
+		{Name: "scrypt", Algorithm: domain.AlgorithmScrypt, Params: "ln=4,r=8,p=1"},
+		// invalid params disable the arm
+		{Name: "broken", Algorithm: domain.AlgorithmScrypt, Params: "n=4"},
+	}
+}
+
+func (c *policyConfig) PolicyRollout() []config.PolicyRollout {
+	return c.rollout
+}
+
+func (c *policyConfig) PolicyTenants() map[string]string {
+	return map[string]string{
+		"acme":   "scrypt",
+		"broken": "broken",
+	}
+}
+
+func (c *policyConfig) RehashOnVerify() bool {
+	return true
+}
+
+func createHashes(t *testing.T, cfg config.Config, opts hasher.CreateOptions, n int) ([]domain.Hash, stats.Service) {
+	repo := memory.NewHashRepository()
+	statsSvc := stats.New()
+	svc := hasher.New(repo, cfg, statsSvc)
+	defer svc.Stop()
+
+	var ids []domain.HashID
+	for i := 0; i < n; i++ {
+		hashID, err := svc.Create("angryMonkey", opts)
+		assert.NoError(t, err)
+		ids = append(ids, hashID)
+	}
+
+	var hashes []domain.Hash
+	for _, hashID := range ids {
+		hashes = append(hashes, waitHash(repo, hashID))
+	}
+	return hashes, statsSvc
+}
+
+func TestPolicyRollout(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := &policyConfig{rollout: []config.PolicyRollout{{Arm: "argon2", Percent: 20}, {Arm: "broken", Percent: 10}}}
+	hashes, statsSvc := createHashes(t, cfg, hasher.CreateOptions{}, 500)
+
+	counts := map[domain.Algorithm]int{}
+	for _, hash := range hashes {
+		counts[hash.Algorithm]++
+	}
+	// ~20% of hashes use the argon2 arm, the broken arm falls back to the default one
+	assert.InDelta(100, counts[domain.AlgorithmArgon2id], 30)
+	assert.Equal(500, counts[domain.AlgorithmArgon2id]+counts[domain.AlgorithmSHA512])
+
+	arms := statsSvc.Metrics("Policy.")
+	assert.Len(arms, 2)
+	assert.Equal(int64(counts[domain.AlgorithmArgon2id]), arms["argon2"].Count())
+	assert.Equal(int64(counts[domain.AlgorithmSHA512]), arms["default"].Count())
+
+	// the buckets are deterministic
+	again, _ := createHashes(t, cfg, hasher.CreateOptions{}, 500)
+	for i := range hashes {
+		assert.Equal(hashes[i].Algorithm, again[i].Algorithm)
+	}
+
+	// the whole rollout
+	cfg = &policyConfig{rollout: []config.PolicyRollout{{Arm: "argon2", Percent: 100}}}
+	hashes, _ = createHashes(t, cfg, hasher.CreateOptions{}, 10)
+	for _, hash := range hashes {
+		assert.Equal(domain.AlgorithmArgon2id, hash.Algorithm)
+		assert.Equal("m=1024,t=1,p=1", hash.Params)
+	}
+}
+
+func TestPolicyTenantAndOverride(t *testing.T) {
+	assert := assert.New(t)
+
+	cfg := &policyConfig{rollout: []config.PolicyRollout{{Arm: "argon2", Percent: 100}}}
+
+	hashes, statsSvc := createHashes(t, cfg, hasher.CreateOptions{Tenant: "acme"}, 5)
+	for _, hash := range hashes {
+		assert.Equal(domain.AlgorithmScrypt, hash.Algorithm)
+		assert.Equal("ln=4,r=8,p=1", hash.Params)
+	}
+	assert.Equal(int64(5), statsSvc.Metric("Policy.scrypt").Count())
+
+	// unknown tenants follow the rollout, disabled arms fall back to the default one
+	hashes, _ = createHashes(t, cfg, hasher.CreateOptions{Tenant: "unknown"}, 1)
+	assert.Equal(domain.AlgorithmArgon2id, hashes[0].Algorithm)
+	hashes, _ = createHashes(t, cfg, hasher.CreateOptions{Tenant: "broken"}, 1)
+	assert.Equal(domain.AlgorithmSHA512, hashes[0].Algorithm)
+
+	// an explicit algorithm overrides the policy
+	hashes, statsSvc = createHashes(t, cfg, hasher.CreateOptions{Tenant: "acme", Algorithm: domain.AlgorithmSHA512}, 3)
+	for _, hash := range hashes {
+		assert.Equal(domain.AlgorithmSHA512, hash.Algorithm)
+	}
+	assert.Equal(int64(3), statsSvc.Metric("Policy.override").Count())
+}
+
+func TestPolicyRehash(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	cfg := &policyConfig{}
+	svc := hasher.New(repo, cfg, stats.New())
+	tenantID, err := svc.Create("angryMonkey", hasher.CreateOptions{Tenant: "acme"})
+	assert.NoError(err)
+	defaultID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	overrideID, err := svc.Create("angryMonkey", hasher.CreateOptions{Algorithm: domain.AlgorithmBcrypt})
+	assert.NoError(err)
+	waitHash(repo, tenantID)
+	waitHash(repo, defaultID)
+	waitHash(repo, overrideID)
+
+	// hashes of any arm are up to date
+	for _, hashID := range []domain.HashID{tenantID, defaultID} {
+		result, err := svc.Verify(hashID, "angryMonkey")
+		assert.NoError(err)
+		assert.True(result.Valid)
+		assert.False(result.Rehashed)
+	}
+
+	// other hashes are upgraded to the arm of their bucket
+	result, err := svc.Verify(overrideID, "angryMonkey")
+	assert.NoError(err)
+	assert.True(result.Rehashed)
+	hash, err := repo.Load(overrideID)
+	assert.NoError(err)
+	assert.Equal(domain.AlgorithmSHA512, hash.Algorithm)
+	svc.Stop()
+}
@@ -0,0 +1,110 @@
+package hasher
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/domain/repository"
+)
+
+// ErrProbe is returned when the end-to-end probe fails
+var ErrProbe = errors.New("Probe failed")
+
+// probePassword is the known password of the probe, it is printable ASCII so every algorithm accepts it, e.g. SCRAM
+const probePassword = "probe-Angstrom-42"
+
+// Metrics of the probe
+const (
+	// StatsProbe tracks the probe latency
+	StatsProbe = "Hasher.Probe"
+	// StatsProbeFailure counts the failed probes
+	StatsProbeFailure = "Hasher.ProbeFailure"
+)
+
+// probePollInterval is the interval of polling the repository for the probe hash
+const probePollInterval = 10 * time.Millisecond
+
+func (s *service) Probe(timeout time.Duration) error {
+	hashID, err := s.create(probePassword, CreateOptions{}, true)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrProbe, err)
+	}
+
+	hash, err := s.waitProbe(hashID, timeout)
+	if err != nil {
+		s.abandonProbe(hashID)
+		return err
+	}
+	defer s.hashRepo.Delete(hashID)
+
+	valid, err := s.verify(hash, probePassword)
+	if err != nil {
+		return fmt.Errorf("%w: hash id=%v: %v", ErrProbe, hashID, err)
+	}
+	if !valid {
+		return fmt.Errorf("%w: hash id=%v does not match the probe password", ErrProbe, hashID)
+	}
+
+	valid, err = s.verify(hash, probePassword+"!")
+	if err != nil {
+		return fmt.Errorf("%w: hash id=%v: %v", ErrProbe, hashID, err)
+	}
+	if valid {
+		return fmt.Errorf("%w: hash id=%v matches a wrong password", ErrProbe, hashID)
+	}
+	return nil
+}
+
+// waitProbe polls the repository until the probe hash is saved by a pool worker
+func (s *service) waitProbe(id domain.HashID, timeout time.Duration) (domain.Hash, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		hash, err := s.hashRepo.Load(id)
+		if err == nil {
+			return hash, nil
+		}
+		if !errors.Is(err, repository.ErrHashNotFound) {
+			return domain.Hash{}, fmt.Errorf("%w: %v", ErrProbe, err)
+		}
+		if time.Now().After(deadline) {
+			return domain.Hash{}, fmt.Errorf("%w: hash id=%v is not ready after %v", ErrProbe, id, timeout)
+		}
+		time.Sleep(probePollInterval)
+	}
+}
+
+// abandonedProbes are the probe records which timed out before a pool worker saved them,
+// the worker deletes such a record right after saving it
+type abandonedProbes struct {
+	lock sync.Mutex
+	ids  map[domain.HashID]bool
+}
+
+// abandonProbe deletes the probe record, or marks it for deletion by the worker if it is not saved yet
+func (s *service) abandonProbe(id domain.HashID) {
+	s.abandoned.lock.Lock()
+	defer s.abandoned.lock.Unlock()
+
+	if err := s.hashRepo.Delete(id); err == nil {
+		return
+	}
+	if s.abandoned.ids == nil {
+		s.abandoned.ids = make(map[domain.HashID]bool)
+	}
+	s.abandoned.ids[id] = true
+}
+
+// probeSaved is called by the worker after the probe record is saved or failed,
+// the record of an abandoned probe is deleted
+func (s *service) probeSaved(id domain.HashID) {
+	s.abandoned.lock.Lock()
+	defer s.abandoned.lock.Unlock()
+
+	if s.abandoned.ids[id] {
+		delete(s.abandoned.ids, id)
+		s.hashRepo.Delete(id)
+	}
+}
@@ -0,0 +1,116 @@
+package hasher_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/domain/repository"
+	"github.com/plar/hash/infra/persistence/memory"
+	"github.com/plar/hash/server/config"
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+type probeConfig struct {
+	pepperConfig
+}
+
+func (c *probeConfig) Normalization() domain.Stage {
+	return domain.StageOpaqueString
+}
+
+// a strict policy does not apply to the probe
+func (c *probeConfig) PasswordMinLength() int {
+	return 64
+}
+
+type slowProbeConfig struct {
+	testConfig
+}
+
+func (c *slowProbeConfig) TaskDelay() time.Duration {
+	return 300 * time.Millisecond
+}
+
+func TestProbe(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	statsSvc := stats.New()
+	svc := hasher.NewInstrumentingService(hasher.New(repo, &probeConfig{pepperConfig{keys: []config.PepperKey{{ID: "k1", Secret: []byte("0123456789abcdef")}}}}, statsSvc), statsSvc)
+	defer svc.Stop()
+
+	assert.NoError(svc.Probe(time.Second))
+	assert.NoError(svc.Probe(time.Second))
+
+	assert.Equal(int64(2), statsSvc.Metric(hasher.StatsProbe).Count())
+	assert.Equal(int64(0), statsSvc.Metric(hasher.StatsProbeFailure).Count())
+	assert.Equal(int64(0), statsSvc.Metric("Hasher.Create").Count())
+	assert.Empty(statsSvc.Metrics("Policy."))
+
+	// the probe records are deleted
+	for id := domain.HashID(1); id <= 2; id++ {
+		_, err := repo.Load(id)
+		assert.True(errors.Is(err, repository.ErrHashNotFound))
+	}
+}
+
+type scramProbeConfig struct {
+	scramConfig
+}
+
+func (c *scramProbeConfig) Algorithm() domain.Algorithm {
+	return domain.AlgorithmSCRAMSHA256
+}
+
+func (c *scramProbeConfig) Normalization() domain.Stage {
+	return domain.StageOpaqueString
+}
+
+func TestProbeSCRAM(t *testing.T) {
+	assert := assert.New(t)
+
+	// SCRAM accepts printable ASCII passwords only
+	svc := hasher.New(memory.NewHashRepository(), &scramProbeConfig{}, stats.New())
+	defer svc.Stop()
+
+	assert.NoError(svc.Probe(time.Second))
+}
+
+func TestProbeHidden(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, Config(), stats.New())
+	defer svc.Stop()
+
+	// a probe record in flight
+	id := repo.NewID()
+	repo.Save(domain.Hash{ID: id, Hash: make([]byte, 64), Salt: []byte("saltsalt"), Algorithm: domain.AlgorithmSHA512, Probe: true})
+
+	// the probe record is not visible to clients
+	_, err := svc.Get(id)
+	assert.True(errors.Is(err, repository.ErrHashNotFound))
+	_, err = svc.Verify(id, "password")
+	assert.True(errors.Is(err, repository.ErrHashNotFound))
+	assert.Equal(hasher.WrapResult{}, svc.Wrap())
+}
+
+func TestProbeAbandoned(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, &slowProbeConfig{}, stats.New())
+
+	// the probe times out before the worker saves the record
+	err := svc.Probe(100 * time.Millisecond)
+	assert.True(errors.Is(err, hasher.ErrProbe))
+
+	// the worker deletes the record of the abandoned probe
+	svc.Stop()
+	_, err = repo.Load(1)
+	assert.True(errors.Is(err, repository.ErrHashNotFound))
+}
@@ -0,0 +1,141 @@
+package hasher
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/server/config"
+)
+
+// ErrAlgorithmNotAllowed is returned when a requested algorithm is not in the allowlist
+var ErrAlgorithmNotAllowed = errors.New("Algorithm is not allowed")
+
+// EncryptorFactory creates a new encryptor.
+// Encryptors are not safe for concurrent use, so every task creates its own.
+type EncryptorFactory func() Encryptor
+
+// Registry is a named registry of encryptor factories with the default algorithm
+type Registry struct {
+	factories map[domain.Algorithm]EncryptorFactory
+	def       domain.Algorithm
+}
+
+// NewRegistry creates an empty registry with the default algorithm
+func NewRegistry(def domain.Algorithm) *Registry {
+	return &Registry{
+		factories: make(map[domain.Algorithm]EncryptorFactory),
+		def:       def,
+	}
+}
+
+// Register adds an encryptor factory under the algorithm name
+func (r *Registry) Register(algorithm domain.Algorithm, factory EncryptorFactory) {
+	r.factories[algorithm] = factory
+}
+
+// Default returns the default algorithm
+func (r *Registry) Default() domain.Algorithm {
+	return r.def
+}
+
+// Algorithms returns the sorted names of the registered algorithms
+func (r *Registry) Algorithms() []domain.Algorithm {
+	algorithms := make([]domain.Algorithm, 0, len(r.factories))
+	for algorithm := range r.factories {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Slice(algorithms, func(i, j int) bool { return algorithms[i] < algorithms[j] })
+	return algorithms
+}
+
+// New creates a new encryptor of the algorithm, the default algorithm is used for the empty name
+func (r *Registry) New(algorithm domain.Algorithm) (Encryptor, error) {
+	if algorithm == "" {
+		algorithm = r.def
+	}
+
+	factory, ok := r.factories[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%v'", ErrAlgorithmNotAllowed, algorithm)
+	}
+	return factory(), nil
+}
+
+// NewWithParams creates a new encryptor of the algorithm with the cost parameters in the PHC format,
+// the registered cost parameters are used for the empty params.
+func (r *Registry) NewWithParams(algorithm domain.Algorithm, params string) (Encryptor, error) {
+	encryptor, err := r.New(algorithm)
+	if err != nil || params == "" {
+		return encryptor, err
+	}
+
+	// the salt and key lengths of PBKDF2 are not a part of the parameters
+	stored := domain.Hash{Algorithm: encryptor.Algorithm(), Params: params}
+	if e, ok := encryptor.(*pbkdf2Encryptor); ok {
+		stored.Salt = make([]byte, e.params.SaltLen)
+		stored.Hash = make([]byte, e.params.KeyLen)
+	}
+	return newEncryptorFor(stored)
+}
+
+// NewRegistryFromConfig creates a registry of the allowed built-in algorithms with the configured cost parameters
+func NewRegistryFromConfig(cfg config.Config) *Registry {
+	r := NewRegistry(cfg.Algorithm())
+
+	argon2Params := Argon2Params{
+		Memory:     cfg.Argon2Memory(),
+		Iterations: cfg.Argon2Iterations(),
+		Lanes:      cfg.Argon2Lanes(),
+	}
+	bcryptParams := BcryptParams{
+		Cost:    cfg.BcryptCost(),
+		PreHash: cfg.BcryptPreHash(),
+	}
+	scryptParams := ScryptParams{
+		N: cfg.ScryptN(),
+		R: cfg.ScryptR(),
+		P: cfg.ScryptP(),
+	}
+	scramParams := SCRAMParams{
+		Iterations: cfg.SCRAMIterations(),
+	}
+	pbkdf2Params := PBKDF2Params{
+		Iterations: cfg.PBKDF2Iterations(),
+		SaltLen:    cfg.PBKDF2SaltLen(),
+		KeyLen:     cfg.PBKDF2KeyLen(),
+	}
+
+	builtin := map[domain.Algorithm]EncryptorFactory{
+		domain.AlgorithmSHA512: NewSHA512Encryptor,
+		domain.AlgorithmArgon2id: func() Encryptor {
+			return NewArgon2idEncryptor(argon2Params)
+		},
+		domain.AlgorithmBcrypt: func() Encryptor {
+			return NewBcryptEncryptor(bcryptParams)
+		},
+		domain.AlgorithmScrypt: func() Encryptor {
+			return NewScryptEncryptor(scryptParams)
+		},
+		domain.AlgorithmPBKDF2SHA256: func() Encryptor {
+			return NewPBKDF2SHA256Encryptor(pbkdf2Params)
+		},
+		domain.AlgorithmPBKDF2SHA512: func() Encryptor {
+			return NewPBKDF2SHA512Encryptor(pbkdf2Params)
+		},
+		domain.AlgorithmSCRAMSHA1: func() Encryptor {
+			return NewSCRAMSHA1Encryptor(scramParams)
+		},
+		domain.AlgorithmSCRAMSHA256: func() Encryptor {
+			return NewSCRAMSHA256Encryptor(scramParams)
+		},
+	}
+
+	for _, algorithm := range cfg.AllowedAlgorithms() {
+		if factory, ok := builtin[algorithm]; ok {
+			r.Register(algorithm, factory)
+		}
+	}
+	return r
+}
@@ -0,0 +1,59 @@
+package hasher_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/service/hasher"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	r := hasher.NewRegistry(domain.AlgorithmSHA512)
+	assert.Equal(domain.AlgorithmSHA512, r.Default())
+	assert.Empty(r.Algorithms())
+
+	_, err := r.New("")
+	assert.True(errors.Is(err, hasher.ErrAlgorithmNotAllowed))
+
+	r.Register(domain.AlgorithmSHA512, hasher.NewSHA512Encryptor)
+	r.Register(domain.AlgorithmArgon2id, func() hasher.Encryptor {
+		return hasher.NewArgon2idEncryptor(hasher.Argon2Params{Memory: 1024, Iterations: 1, Lanes: 1})
+	})
+	assert.Equal([]domain.Algorithm{domain.AlgorithmArgon2id, domain.AlgorithmSHA512}, r.Algorithms())
+
+	e, err := r.New("")
+	assert.NoError(err)
+	assert.Equal(domain.AlgorithmSHA512, e.Algorithm())
+
+	e, err = r.New(domain.AlgorithmArgon2id)
+	assert.NoError(err)
+	assert.Equal(domain.AlgorithmArgon2id, e.Algorithm())
+
+	_, err = r.New(domain.AlgorithmBcrypt)
+	assert.True(errors.Is(err, hasher.ErrAlgorithmNotAllowed))
+}
+
+type allowlistConfig struct {
+	testConfig
+}
+
+func (c *allowlistConfig) AllowedAlgorithms() []domain.Algorithm {
+	return []domain.Algorithm{domain.AlgorithmSHA512, domain.AlgorithmScrypt}
+}
+
+func TestNewRegistryFromConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	r := hasher.NewRegistryFromConfig(Config())
+	assert.Equal(domain.AlgorithmSHA512, r.Default())
+	assert.NotContains(r.Algorithms(), domain.AlgorithmSCRAMSHA1)
+	assert.NotContains(r.Algorithms(), domain.AlgorithmSCRAMSHA256)
+	assert.ElementsMatch((&scramConfig{}).AllowedAlgorithms(), hasher.NewRegistryFromConfig(&scramConfig{}).Algorithms())
+
+	r = hasher.NewRegistryFromConfig(&allowlistConfig{})
+	assert.Equal([]domain.Algorithm{domain.AlgorithmScrypt, domain.AlgorithmSHA512}, r.Algorithms())
+}
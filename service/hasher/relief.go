@@ -0,0 +1,201 @@
+package hasher
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/domain/repository"
+)
+
+// ErrReliefNotSupported is returned when the algorithm cannot be computed by clients
+var ErrReliefNotSupported = errors.New("Algorithm does not support server relief")
+
+// ErrInvalidReliefKey is returned when the client key or its parameters do not match the algorithm
+var ErrInvalidReliefKey = errors.New("Invalid server relief key")
+
+// ReliefParams are the parameters of the client side KDF of a server relief hash
+type ReliefParams struct {
+	Algorithm domain.Algorithm
+	// Params are the cost parameters in the PHC string format
+	Params string
+	Salt   []byte
+	// Token binds the salt of new parameters to the server, it is empty for stored hashes
+	Token []byte
+}
+
+// reliefKeyLen returns the length of the client key of the encryptor,
+// false if the encryptor cannot be computed by clients
+func reliefKeyLen(e Encryptor) (int, bool) {
+	switch e := e.(type) {
+	case *argon2idEncryptor:
+		return argon2KeyLen, true
+	case *scryptEncryptor:
+		return scryptKeyLen, true
+	case *pbkdf2Encryptor:
+		return e.params.KeyLen, true
+	}
+	return 0, false
+}
+
+// reliefHash is the final stage of a server relief hash: HMAC-SHA512 of the client key with the pepper key,
+// or SHA512 of the client key if the pepper is disabled
+func (s *service) reliefHash(keyID string, key []byte) ([]byte, error) {
+	if keyID != "" {
+		return s.pepper.Apply(keyID, key)
+	}
+	digest := sha512.Sum512(key)
+	return digest[:], nil
+}
+
+// newReliefEncryptor creates the registered encryptor of the algorithm and checks it can be computed by clients
+func (s *service) newReliefEncryptor(algorithm domain.Algorithm) (Encryptor, int, error) {
+	encryptor, err := s.registry.New(algorithm)
+	if err != nil {
+		return nil, 0, err
+	}
+	keyLen, ok := reliefKeyLen(encryptor)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: '%v'", ErrReliefNotSupported, encryptor.Algorithm())
+	}
+	return encryptor, keyLen, nil
+}
+
+func (s *service) NewReliefParams(algorithm domain.Algorithm) (ReliefParams, error) {
+	encryptor, _, err := s.newReliefEncryptor(algorithm)
+	if err != nil {
+		return ReliefParams{}, err
+	}
+
+	salt, err := newSalt(encryptor.SaltLen())
+	if err != nil {
+		return ReliefParams{}, err
+	}
+	return ReliefParams{
+		Algorithm: encryptor.Algorithm(),
+		Params:    encryptor.Params(),
+		Salt:      salt,
+		Token:     s.reliefToken(encryptor.Algorithm(), encryptor.Params(), salt),
+	}, nil
+}
+
+func (s *service) ReliefParams(id domain.HashID) (ReliefParams, error) {
+	hash, err := s.load(id)
+	if errors.Is(err, repository.ErrHashNotFound) || (err == nil && !hash.Relief) {
+		return s.fakeReliefParams(id)
+	}
+	if err != nil {
+		return ReliefParams{}, err
+	}
+	return ReliefParams{
+		Algorithm: hash.Algorithm,
+		Params:    hash.Params,
+		Salt:      hash.Salt,
+	}, nil
+}
+
+func (s *service) CreateRelief(params ReliefParams, key []byte) (domain.HashID, error) {
+	encryptor, keyLen, err := s.newReliefEncryptor(params.Algorithm)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case params.Params != encryptor.Params():
+		// the client must not downgrade the cost parameters
+		return 0, fmt.Errorf("%w: the parameters '%v' are not the current parameters '%v'", ErrInvalidReliefKey, params.Params, encryptor.Params())
+	case !hmac.Equal(params.Token, s.reliefToken(encryptor.Algorithm(), params.Params, params.Salt)):
+		// the client must not choose the salt
+		return 0, fmt.Errorf("%w: the salt was not issued by the server", ErrInvalidReliefKey)
+	case len(key) != keyLen:
+		return 0, fmt.Errorf("%w: the key must be %v bytes", ErrInvalidReliefKey, keyLen)
+	}
+
+	keyID := s.pepper.CurrentKeyID()
+	final, err := s.reliefHash(keyID, key)
+	if err != nil {
+		return 0, err
+	}
+
+	hash := domain.Hash{
+		ID:        s.hashRepo.NewID(),
+		Algorithm: encryptor.Algorithm(),
+		Params:    encryptor.Params(),
+		Salt:      append([]byte(nil), params.Salt...),
+		Hash:      final,
+		KeyID:     keyID,
+		Relief:    true,
+	}
+	s.hashRepo.Save(hash)
+	return hash.ID, nil
+}
+
+func (s *service) VerifyRelief(id domain.HashID, key []byte) (bool, error) {
+	hash, err := s.load(id)
+	if err != nil {
+		return false, err
+	}
+	if !hash.Relief {
+		// respond exactly like a mismatch, do not leak the type of the hash
+		return false, nil
+	}
+
+	final, err := s.reliefHash(hash.KeyID, key)
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(final, hash.Hash) == 1, nil
+}
+
+// fakeReliefEncryptor returns the encryptor of the fake parameters: the default algorithm,
+// or the first registered algorithm which can be computed by clients
+func (s *service) fakeReliefEncryptor() (Encryptor, error) {
+	encryptor, _, err := s.newReliefEncryptor("")
+	if err == nil {
+		return encryptor, nil
+	}
+	for _, algorithm := range s.registry.Algorithms() {
+		if encryptor, _, err := s.newReliefEncryptor(algorithm); err == nil {
+			return encryptor, nil
+		}
+	}
+	return nil, err
+}
+
+// fakeReliefParams returns the parameters of a missing server relief hash with a salt derived from the secret,
+// so repeated requests for the same id return the same parameters
+func (s *service) fakeReliefParams(id domain.HashID) (ReliefParams, error) {
+	encryptor, err := s.fakeReliefEncryptor()
+	if err != nil {
+		return ReliefParams{}, err
+	}
+
+	salt := make([]byte, 0, encryptor.SaltLen())
+	for i := byte(0); len(salt) < encryptor.SaltLen(); i++ {
+		salt = append(salt, s.derive("relief-salt", id.Bytes(), []byte{i})...)
+	}
+	return ReliefParams{
+		Algorithm: encryptor.Algorithm(),
+		Params:    encryptor.Params(),
+		Salt:      salt[:encryptor.SaltLen()],
+	}, nil
+}
+
+// reliefToken returns the token of the salt issued with the algorithm and the parameters
+func (s *service) reliefToken(algorithm domain.Algorithm, params string, salt []byte) []byte {
+	return s.derive("relief-token", []byte(algorithm), []byte{0}, []byte(params), []byte{0}, salt)
+}
+
+// derive returns HMAC-SHA256 of the label and the data keyed with the secret
+func (s *service) derive(label string, data ...[]byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(label))
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
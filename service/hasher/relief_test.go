@@ -0,0 +1,175 @@
+package hasher_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/domain/repository"
+	"github.com/plar/hash/infra/persistence/memory"
+	"github.com/plar/hash/server/config"
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/stats"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/argon2"
+)
+
+type reliefConfig struct {
+	pepperConfig
+}
+
+func (c *reliefConfig) Argon2Memory() uint32 {
+	return 256
+}
+
+func TestRelief(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	cfg := &reliefConfig{pepperConfig{keys: []config.PepperKey{{ID: "k1", Secret: []byte("0123456789abcdef")}}}}
+	svc := hasher.New(repo, cfg, stats.New())
+	defer svc.Stop()
+
+	params, err := svc.NewReliefParams(domain.AlgorithmArgon2id)
+	assert.NoError(err)
+	assert.Equal(domain.AlgorithmArgon2id, params.Algorithm)
+	assert.Equal("m=256,t=1,p=2", params.Params)
+	assert.Len(params.Salt, 16)
+	assert.NotEmpty(params.Token)
+
+	// the client computes the KDF
+	key := argon2.IDKey([]byte("angryMonkey"), params.Salt, 1, 256, 2, 32)
+	hashID, err := svc.CreateRelief(params, key)
+	assert.NoError(err)
+
+	hash, err := repo.Load(hashID)
+	assert.NoError(err)
+	assert.True(hash.Relief)
+	assert.Equal("k1", hash.KeyID)
+	assert.NotEqual(key, hash.Hash)
+
+	stored, err := svc.ReliefParams(hashID)
+	assert.NoError(err)
+	assert.Equal(params.Algorithm, stored.Algorithm)
+	assert.Equal(params.Params, stored.Params)
+	assert.Equal(params.Salt, stored.Salt)
+	assert.Empty(stored.Token)
+
+	valid, err := svc.VerifyRelief(hashID, key)
+	assert.NoError(err)
+	assert.True(valid)
+
+	valid, err = svc.VerifyRelief(hashID, argon2.IDKey([]byte("angryMonkey!"), params.Salt, 1, 256, 2, 32))
+	assert.NoError(err)
+	assert.False(valid)
+
+	// the stored hash is not the client key, a leaked repository cannot be replayed
+	valid, err = svc.VerifyRelief(hashID, hash.Hash)
+	assert.NoError(err)
+	assert.False(valid)
+
+	// server relief hashes cannot be verified through the plaintext path, it reports a mismatch
+	result, err := svc.Verify(hashID, "angryMonkey")
+	assert.NoError(err)
+	assert.False(result.Valid)
+}
+
+func TestReliefErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, &reliefConfig{}, stats.New())
+	defer svc.Stop()
+
+	// the default algorithm is SHA512
+	_, err := svc.NewReliefParams("")
+	assert.True(errors.Is(err, hasher.ErrReliefNotSupported))
+	_, err = svc.NewReliefParams(domain.AlgorithmBcrypt)
+	assert.True(errors.Is(err, hasher.ErrReliefNotSupported))
+	_, err = svc.NewReliefParams("md5")
+	assert.True(errors.Is(err, hasher.ErrAlgorithmNotAllowed))
+
+	params, err := svc.NewReliefParams(domain.AlgorithmArgon2id)
+	assert.NoError(err)
+	key := make([]byte, 32)
+
+	// the client cannot downgrade the parameters
+	downgraded := params
+	downgraded.Params = "m=64,t=1,p=1"
+	_, err = svc.CreateRelief(downgraded, key)
+	assert.True(errors.Is(err, hasher.ErrInvalidReliefKey))
+
+	// the client cannot choose the salt
+	chosen := params
+	chosen.Salt = make([]byte, 16)
+	_, err = svc.CreateRelief(chosen, key)
+	assert.True(errors.Is(err, hasher.ErrInvalidReliefKey))
+
+	chosen.Token = nil
+	_, err = svc.CreateRelief(chosen, key)
+	assert.True(errors.Is(err, hasher.ErrInvalidReliefKey))
+
+	short := params
+	short.Salt = short.Salt[:8]
+	_, err = svc.CreateRelief(short, key)
+	assert.True(errors.Is(err, hasher.ErrInvalidReliefKey))
+
+	// tokens are bound to the service which issued them
+	other := hasher.New(memory.NewHashRepository(), &reliefConfig{}, stats.New())
+	defer other.Stop()
+	_, err = other.CreateRelief(params, key)
+	assert.True(errors.Is(err, hasher.ErrInvalidReliefKey))
+
+	_, err = svc.CreateRelief(params, key[:16])
+	assert.True(errors.Is(err, hasher.ErrInvalidReliefKey))
+
+	// plaintext hashes are not server relief hashes
+	hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	waitHash(repo, hashID)
+
+	valid, err := svc.VerifyRelief(hashID, key)
+	assert.NoError(err)
+	assert.False(valid)
+
+	_, err = svc.VerifyRelief(hashID+1000, key)
+	assert.True(errors.Is(err, repository.ErrHashNotFound))
+}
+
+func TestReliefParamsHidden(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, &reliefConfig{}, stats.New())
+	defer svc.Stop()
+
+	params, err := svc.NewReliefParams(domain.AlgorithmArgon2id)
+	assert.NoError(err)
+	reliefID, err := svc.CreateRelief(params, make([]byte, 32))
+	assert.NoError(err)
+
+	plainID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	waitHash(repo, plainID)
+
+	// missing and plaintext hashes look like server relief hashes of the first supported algorithm
+	for _, id := range []domain.HashID{plainID, reliefID + 1000} {
+		fake, err := svc.ReliefParams(id)
+		assert.NoError(err)
+		assert.Equal(params.Algorithm, fake.Algorithm)
+		assert.Equal(params.Params, fake.Params)
+		assert.Len(fake.Salt, len(params.Salt))
+
+		again, err := svc.ReliefParams(id)
+		assert.NoError(err)
+		assert.Equal(fake, again)
+	}
+
+	plain, _ := svc.ReliefParams(plainID)
+	missing, _ := svc.ReliefParams(reliefID + 1000)
+	assert.NotEqual(plain.Salt, missing.Salt)
+
+	stored, err := svc.ReliefParams(reliefID)
+	assert.NoError(err)
+	assert.Equal(params.Salt, stored.Salt)
+}
@@ -0,0 +1,100 @@
+package hasher
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+
+	"github.com/plar/hash/domain"
+)
+
+// ErrSCRAMPassword is returned when a SCRAM password is not printable ASCII
+var ErrSCRAMPassword = errors.New("SCRAM password must be printable ASCII")
+
+// scramSaltLen is the salt length of SCRAM verifiers, the same as PostgreSQL uses
+const scramSaltLen = 16
+
+// SCRAMParams defines SCRAM cost parameters
+type SCRAMParams struct {
+	// Iterations is the number of PBKDF2 iterations of SaltedPassword
+	Iterations int
+}
+
+type scramEncryptor struct {
+	algorithm domain.Algorithm
+	hashFn    func() hash.Hash
+	params    SCRAMParams
+}
+
+// NewSCRAMSHA1Encryptor creates a new SCRAM-SHA-1 (RFC 5802) verifier encryptor with the cost parameters
+func NewSCRAMSHA1Encryptor(params SCRAMParams) Encryptor {
+	return &scramEncryptor{
+		algorithm: domain.AlgorithmSCRAMSHA1,
+		hashFn:    sha1.New,
+		params:    params,
+	}
+}
+
+// NewSCRAMSHA256Encryptor creates a new SCRAM-SHA-256 (RFC 7677) verifier encryptor with the cost parameters
+func NewSCRAMSHA256Encryptor(params SCRAMParams) Encryptor {
+	return &scramEncryptor{
+		algorithm: domain.AlgorithmSCRAMSHA256,
+		hashFn:    sha256.New,
+		params:    params,
+	}
+}
+
+// Validate checks that password is printable ASCII. SASLprep (RFC 4013) does not change such passwords,
+// so the verifier matches the one of an external SCRAM server without a SASLprep implementation here.
+func (e *scramEncryptor) Validate(password []byte) error {
+	for _, c := range password {
+		if c < 0x20 || c > 0x7e {
+			return ErrSCRAMPassword
+		}
+	}
+	return nil
+}
+
+func (e *scramEncryptor) Algorithm() domain.Algorithm {
+	return e.algorithm
+}
+
+func (e *scramEncryptor) Params() string {
+	return fmt.Sprintf("i=%d", e.params.Iterations)
+}
+
+func (e *scramEncryptor) SaltLen() int {
+	return scramSaltLen
+}
+
+func (e *scramEncryptor) Memory() uint64 {
+	return 0
+}
+
+// Hash generates StoredKey || ServerKey of password:
+//
+//	SaltedPassword = Hi(password, salt, i)
+//	StoredKey      = H(HMAC(SaltedPassword, "Client Key"))
+//	ServerKey      = HMAC(SaltedPassword, "Server Key")
+func (e *scramEncryptor) Hash(password, salt []byte) ([]byte, error) {
+	if err := e.Validate(password); err != nil {
+		return nil, err
+	}
+
+	saltedPassword := pbkdf2Key(password, salt, e.params.Iterations, e.hashFn().Size(), e.hashFn)
+
+	mac := hmac.New(e.hashFn, saltedPassword)
+	mac.Write([]byte("Client Key"))
+	clientKey := mac.Sum(nil)
+
+	h := e.hashFn()
+	h.Write(clientKey)
+	storedKey := h.Sum(nil)
+
+	mac = hmac.New(e.hashFn, saltedPassword)
+	mac.Write([]byte("Server Key"))
+	return mac.Sum(storedKey), nil
+}
@@ -0,0 +1,112 @@
+package hasher_test
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/infra/persistence/memory"
+	"github.com/plar/hash/server/config"
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSCRAMEncryptor(t *testing.T) {
+	assert := assert.New(t)
+
+	params := hasher.SCRAMParams{Iterations: 4096}
+
+	// RFC 7677 test vector
+	e := hasher.NewSCRAMSHA256Encryptor(params)
+	assert.Equal(domain.AlgorithmSCRAMSHA256, e.Algorithm())
+	assert.Equal("i=4096", e.Params())
+	assert.Equal(16, e.SaltLen())
+
+	salt, _ := base64.StdEncoding.DecodeString("W22ZaJ0SNY7soEsUEjb6gQ==")
+	hash, err := e.Hash([]byte("pencil"), salt)
+	assert.NoError(err)
+	assert.Equal("WG5d8oPm3OtcPnkdi4Uo7BkeZkBFzpcXkuLmtbsT4qY=", base64.StdEncoding.EncodeToString(hash[:32]))
+	assert.Equal("wfPLwcE6nTWhTAmQ7tl2KeoiWGPlZqQxSrmfPwDl2dU=", base64.StdEncoding.EncodeToString(hash[32:]))
+
+	e = hasher.NewSCRAMSHA1Encryptor(params)
+	assert.Equal(domain.AlgorithmSCRAMSHA1, e.Algorithm())
+
+	hash, err = e.Hash([]byte("pencil"), salt)
+	assert.NoError(err)
+	assert.Len(hash, 40)
+}
+
+type scramConfig struct {
+	pepperConfig
+}
+
+// SCRAM is not allowed by default
+func (c *scramConfig) AllowedAlgorithms() []domain.Algorithm {
+	return domain.Algorithms()
+}
+
+func TestSCRAMNonASCII(t *testing.T) {
+	assert := assert.New(t)
+
+	// SASLprep keeps printable ASCII as is, the RFC 5802 client and the PostgreSQL server agree on these bytes
+	e := hasher.NewSCRAMSHA256Encryptor(hasher.SCRAMParams{Iterations: 4096})
+	salt, _ := base64.StdEncoding.DecodeString("W22ZaJ0SNY7soEsUEjb6gQ==")
+	_, err := e.Hash([]byte("pencil with spaces ~!"), salt)
+	assert.NoError(err)
+
+	// SASLprep maps and normalizes non-ASCII passwords, such verifiers would not match an external server
+	for _, password := range []string{"p\u00e9ncil", "pencil\u00a0", "\u2168", "pencil\x00", "pencil\t"} {
+		_, err := e.Hash([]byte(password), salt)
+		assert.Equal(hasher.ErrSCRAMPassword, err, "%q", password)
+	}
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, &scramConfig{}, stats.New())
+	defer svc.Stop()
+	_, err = svc.Create("p\u00e9ncil", hasher.CreateOptions{Algorithm: domain.AlgorithmSCRAMSHA1})
+	assert.Equal(hasher.ErrSCRAMPassword, err)
+}
+
+func TestCreateSCRAM(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, Config(), stats.New())
+	_, err := svc.Create("pencil", hasher.CreateOptions{Algorithm: domain.AlgorithmSCRAMSHA256})
+	assert.True(errors.Is(err, hasher.ErrAlgorithmNotAllowed))
+	svc.Stop()
+
+	cfg := &scramConfig{pepperConfig{keys: []config.PepperKey{{ID: "k1", Secret: []byte("0123456789abcdef")}}}}
+	svc = hasher.New(repo, cfg, stats.New())
+	defer svc.Stop()
+
+	hashID, err := svc.Create("pencil", hasher.CreateOptions{Algorithm: domain.AlgorithmSCRAMSHA256})
+	assert.NoError(err)
+
+	// SCRAM verifiers are not peppered, an external server must be able to use them
+	hash := waitHash(repo, hashID)
+	assert.Equal(domain.AlgorithmSCRAMSHA256, hash.Algorithm)
+	assert.Equal("", hash.KeyID)
+	assert.Len(hash.Salt, 16)
+
+	e := hasher.NewSCRAMSHA256Encryptor(hasher.SCRAMParams{Iterations: 4096})
+	expected, err := e.Hash([]byte("pencil"), hash.Salt)
+	assert.NoError(err)
+	assert.Equal(expected, hash.Hash)
+	assert.Equal("SCRAM-SHA-256$4096:"+base64.StdEncoding.EncodeToString(hash.Salt)+"$"+
+		base64.StdEncoding.EncodeToString(expected[:32])+":"+base64.StdEncoding.EncodeToString(expected[32:]), hash.SCRAM())
+
+	result, err := svc.Verify(hashID, "pencil")
+	assert.NoError(err)
+	assert.True(result.Valid)
+	assert.False(result.Rehashed)
+
+	result, err = svc.Verify(hashID, "pencil!")
+	assert.NoError(err)
+	assert.False(result.Valid)
+
+	_, err = svc.Create("pencil", hasher.CreateOptions{Pipeline: "nfkc+scram-sha-256"})
+	assert.Error(err)
+}
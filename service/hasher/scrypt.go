@@ -0,0 +1,58 @@
+package hasher
+
+import (
+	"fmt"
+	"math/bits"
+
+	"github.com/plar/hash/domain"
+	"golang.org/x/crypto/scrypt"
+)
+
+const scryptKeyLen = 32
+
+// ScryptParams defines scrypt cost parameters
+type ScryptParams struct {
+	// N is the CPU/memory cost, must be a power of two greater than 1
+	N int
+	// R is the block size
+	R int
+	// P is the degree of parallelism
+	P int
+}
+
+type scryptEncryptor struct {
+	params ScryptParams
+}
+
+// NewScryptEncryptor creates a new scrypt encryptor with the cost parameters
+func NewScryptEncryptor(params ScryptParams) Encryptor {
+	return &scryptEncryptor{
+		params: params,
+	}
+}
+
+func (e *scryptEncryptor) Algorithm() domain.Algorithm {
+	return domain.AlgorithmScrypt
+}
+
+func (e *scryptEncryptor) Params() string {
+	return fmt.Sprintf("ln=%d,r=%d,p=%d", bits.TrailingZeros(uint(e.params.N)), e.params.R, e.params.P)
+}
+
+func (e *scryptEncryptor) SaltLen() int {
+	return defSaltLen
+}
+
+func (e *scryptEncryptor) Memory() uint64 {
+	return scryptMemory(e.params)
+}
+
+// scryptMemory estimates the scrypt memory usage in KiB
+func scryptMemory(params ScryptParams) uint64 {
+	return 128 * uint64(params.N) * uint64(params.R) / 1024
+}
+
+// Hash generates scrypt key of password
+func (e *scryptEncryptor) Hash(password, salt []byte) ([]byte, error) {
+	return scrypt.Key(password, salt, e.params.N, e.params.R, e.params.P, scryptKeyLen)
+}
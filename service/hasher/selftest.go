@@ -0,0 +1,188 @@
+package hasher
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/plar/hash/domain"
+)
+
+// ErrSelfTest is returned when an encryptor does not reproduce its known answers
+var ErrSelfTest = errors.New("Self-test failed")
+
+// knownAnswer is a known-answer test vector of an algorithm
+type knownAnswer struct {
+	algorithm domain.Algorithm
+	params    string
+	password  string
+	salt      string
+	// hash is the expected hex encoded hash, or the encoded hash of modular crypt algorithms
+	hash string
+}
+
+// stored returns the expected hash as it is stored in the repository
+func (k knownAnswer) stored() (domain.Hash, error) {
+	if k.algorithm.IsModularCrypt() {
+		return ParseLegacyHash(k.hash)
+	}
+	hash, err := hex.DecodeString(k.hash)
+	if err != nil {
+		return domain.Hash{}, err
+	}
+	return domain.Hash{
+		Algorithm: k.algorithm,
+		Params:    k.params,
+		Salt:      []byte(k.salt),
+		Hash:      hash,
+	}, nil
+}
+
+// knownAnswers are published test vectors with cheap cost parameters
+var knownAnswers = []knownAnswer{
+	// sha512(salt || password)
+	{domain.AlgorithmSHA512, "", "password", "saltsalt", "4ae03f18adeee392bb76c1f4e7f05cd580ef615e934a1b52825755be2209bca5b3dc829f0d4ed2f28b03ba7cc3297d33742389a75838d83f9fd6328037082a39"},
+	// sha512(password || salt)
+	{domain.AlgorithmSSHA512, "", "password", "saltsalt", "f59c47563e18a26c2aa8589829c22313130bc7665b9587d744673828ca9b82f1eadbe1ce830893222f9e3bf51a9bff03d1b7830241a9bf1d78f941657c1b242e"},
+	// https://github.com/P-H-C/phc-winner-argon2/blob/master/src/test.c
+	{domain.AlgorithmArgon2id, "m=256,t=2,p=1", "password", "somesalt", "9dfeb910e80bad0311fee20f9c0e2b12c17987b4cac90c2ef54d5b3021c68bfe"},
+	{domain.AlgorithmArgon2id, "m=256,t=2,p=2", "password", "somesalt", "6d093c501fd5999645e0ea3bf620d7b8be7fd2db59c20d9fff9539da2bf57037"},
+	// argon2id(sha512(salt || password), salt)
+	{domain.AlgorithmArgon2idSHA512, "m=256,t=2,p=1", "password", "somesalt", "b4901ca1e9dfe7d6facc6c5d43f72b44dd29ac53d760a3b4cd42554e279d4880"},
+	// OpenBSD bcrypt test vectors
+	{domain.AlgorithmBcrypt, "", "U*U", "", "$2a$05$CCCCCCCCCCCCCCCCCCCCC.E5YPO9kmyuRGyh0XouQYb4YMJKvyOeW"},
+	// RFC 7914, section 12
+	{domain.AlgorithmScrypt, "ln=10,r=8,p=16", "password", "NaCl", "fdbabe1c9d3472007856e7190d01e9fe7c6ad7cbc8237830e77376634b373162"},
+	// RFC 7914, section 11
+	{domain.AlgorithmPBKDF2SHA256, "i=1", "passwd", "salt", "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"},
+	{domain.AlgorithmPBKDF2SHA256, "i=4096", "password", "salt", "c5e478d59288c841aa530db6845c4c8d962893a001ce4e11a4963873aa98134a"},
+	{domain.AlgorithmPBKDF2SHA512, "i=1", "password", "salt", "867f70cf1ade02cff3752599a3a53dc4af34c7a669815ae5d513554e1c8cf252c02d470a285a0501bad999bfe943c08f050235d7d68b1da55e63f73b60a57fce"},
+	// RFC 5802, section 5, the salt is QSXCR+Q6sek8bf92; the hash is StoredKey || ServerKey
+	{domain.AlgorithmSCRAMSHA1, "i=4096", "pencil", "\x41\x25\xc2\x47\xe4\x3a\xb1\xe9\x3c\x6d\xff\x76", "e9d94660c39d65c38fbad91c358f14da0eef2bd60fe09258b3ac852ba502cc62ba903eaacdbf7d31"},
+	// RFC 7677, section 3, the salt is W22ZaJ0SNY7soEsUEjb6gQ==
+	{domain.AlgorithmSCRAMSHA256, "i=4096", "pencil", "\x5b\x6d\x99\x68\x9d\x12\x35\x8e\xec\xa0\x4b\x14\x12\x36\xfa\x81", "586e5df283e6dceb5c3e791d8b8528ec191e664045ce971792e2e6b5bb13e2a6c1f3cbc1c13a9d35a14c0990eed97629ea225863e566a4314ab99f3f00e5d9d5"},
+	// https://www.akkadia.org/drepper/SHA-crypt.txt
+	{domain.AlgorithmSHA512Crypt, "", "Hello world!", "", "$6$saltstring$svn8UoSVapNtMuq1ukKS4tPQd8iKwSMHWjl/O817G3uBnIFNjnQJuesI68u4OTLiBFdcbYEdFCoEOfaS35inz1"},
+	{domain.AlgorithmSHA512Crypt, "", "Hello world!", "", "$6$rounds=10000$saltstringsaltst$OW1/O6BYHV6BcXZu8QVeXbDWra3Oeqh0sbHbbMCVNSnCM/UrjmM0Dp8vOuZeHBy/YTBmSK6H9qs/y3RnOaw5v."},
+	// openssl passwd -1 -salt saltsalt password
+	{domain.AlgorithmMD5Crypt, "", "password", "", "$1$saltsalt$qjXMvbEw8oaL.CzflDtaK/"},
+	// openssl passwd -apr1 -salt saltsalt password
+	{domain.AlgorithmAPR1, "", "password", "", "$apr1$saltsalt$yAAkm4libquA.ZWLHbSBq/"},
+}
+
+// verifyOnlyAlgorithms are not registered for new hashes, but stored hashes of them are verified
+var verifyOnlyAlgorithms = []domain.Algorithm{
+	domain.AlgorithmSSHA512,
+	domain.AlgorithmArgon2idSHA512,
+	domain.AlgorithmSHA512Crypt,
+	domain.AlgorithmMD5Crypt,
+	domain.AlgorithmAPR1,
+}
+
+// SelfTestResult is the known-answer test result of an algorithm
+type SelfTestResult struct {
+	Algorithm domain.Algorithm
+	// Vectors is the number of checked test vectors
+	Vectors int
+	// Err is the first failure, nil if the algorithm passed
+	Err error
+}
+
+// SelfTestReport contains the results of all algorithms
+type SelfTestReport struct {
+	Results []SelfTestResult
+}
+
+// Passed reports whether all algorithms passed
+func (r SelfTestReport) Passed() bool {
+	return r.Err() == nil
+}
+
+// Err returns ErrSelfTest with the failed algorithms, nil if all algorithms passed
+func (r SelfTestReport) Err() error {
+	var failed []string
+	for _, result := range r.Results {
+		if result.Err != nil {
+			failed = append(failed, fmt.Sprintf("%v", result.Err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %v", ErrSelfTest, strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// SelfTest runs the known-answer tests of the registered algorithms and the algorithms of stored hashes only.
+// The registered encryptor is tested if its parameters match the test vector, every algorithm needs at least one vector.
+func (r *Registry) SelfTest() SelfTestReport {
+	var report SelfTestReport
+	for _, algorithm := range append(r.Algorithms(), verifyOnlyAlgorithms...) {
+		result := SelfTestResult{Algorithm: algorithm}
+		for _, k := range knownAnswers {
+			if k.algorithm != algorithm {
+				continue
+			}
+			result.Vectors++
+			if err := r.checkKnownAnswer(k); err != nil {
+				result.Err = fmt.Errorf("%v: %w", algorithm, err)
+				break
+			}
+		}
+		if result.Vectors == 0 {
+			result.Err = fmt.Errorf("%v: no known-answer test vectors", algorithm)
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report
+}
+
+// withParams builds the registered implementation with the cost parameters of the vector,
+// an implementation which cannot be built with other parameters is not tested with the built-in one
+func withParams(registered Encryptor, stored domain.Hash) (Encryptor, error) {
+	encryptor, err := newEncryptorFor(stored)
+	if err != nil {
+		return nil, err
+	}
+	if reflect.TypeOf(encryptor) != reflect.TypeOf(registered) {
+		return nil, fmt.Errorf("the registered %T with params '%v' cannot be tested with params '%v'", registered, registered.Params(), stored.Params)
+	}
+	return encryptor, nil
+}
+
+// checkKnownAnswer verifies the password of the vector and rejects a wrong password
+func (r *Registry) checkKnownAnswer(k knownAnswer) error {
+	stored, err := k.stored()
+	if err != nil {
+		return err
+	}
+
+	encryptor, err := r.New(k.algorithm)
+	if err != nil {
+		// the algorithms of stored hashes only are not registered
+		encryptor, err = newEncryptorFor(stored)
+	} else if encryptor.Params() != stored.Params {
+		encryptor, err = withParams(encryptor, stored)
+	}
+	if err != nil {
+		return err
+	}
+
+	valid, err := verifyPassword(encryptor, []byte(k.password), stored)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("the hash of '%v' does not match the known answer", k.password)
+	}
+
+	valid, err = verifyPassword(encryptor, []byte(k.password+"!"), stored)
+	if err != nil {
+		return err
+	}
+	if valid {
+		return fmt.Errorf("a wrong password matches the known answer of '%v'", k.password)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package hasher_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/infra/persistence/memory"
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+// brokenEncryptor is SHA512 with a flipped bit
+type brokenEncryptor struct {
+	hasher.Encryptor
+}
+
+func (e brokenEncryptor) Hash(password, salt []byte) ([]byte, error) {
+	hash, err := e.Encryptor.Hash(password, salt)
+	hash[0] ^= 1
+	return hash, err
+}
+
+func TestSelfTest(t *testing.T) {
+	assert := assert.New(t)
+
+	// all algorithms are allowed
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, &scramConfig{}, stats.New())
+	defer svc.Stop()
+
+	report := svc.SelfTest()
+	assert.True(report.Passed())
+	assert.NoError(report.Err())
+
+	algorithms := map[domain.Algorithm]int{}
+	for _, result := range report.Results {
+		assert.NoError(result.Err)
+		algorithms[result.Algorithm] = result.Vectors
+	}
+	// all registered algorithms and the algorithms of imported and wrapped hashes
+	for _, algorithm := range append(domain.Algorithms(), domain.AlgorithmSSHA512, domain.AlgorithmArgon2idSHA512, domain.AlgorithmSHA512Crypt, domain.AlgorithmMD5Crypt, domain.AlgorithmAPR1) {
+		assert.Greater(algorithms[algorithm], 0, algorithm)
+	}
+}
+
+func TestSelfTestFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	r := hasher.NewRegistry(domain.AlgorithmSHA512)
+	r.Register(domain.AlgorithmSHA512, func() hasher.Encryptor {
+		return brokenEncryptor{hasher.NewSHA512Encryptor()}
+	})
+	r.Register(domain.AlgorithmBcrypt, func() hasher.Encryptor {
+		return hasher.NewBcryptEncryptor(hasher.BcryptParams{Cost: 4})
+	})
+
+	report := r.SelfTest()
+	assert.False(report.Passed())
+	assert.True(errors.Is(report.Err(), hasher.ErrSelfTest))
+
+	failed := map[domain.Algorithm]bool{}
+	for _, result := range report.Results {
+		failed[result.Algorithm] = result.Err != nil
+	}
+	assert.Equal(map[domain.Algorithm]bool{
+		domain.AlgorithmSHA512:         true,
+		domain.AlgorithmBcrypt:         false,
+		domain.AlgorithmSSHA512:        false,
+		domain.AlgorithmArgon2idSHA512: false,
+		domain.AlgorithmSHA512Crypt:    false,
+		domain.AlgorithmMD5Crypt:       false,
+		domain.AlgorithmAPR1:           false,
+	}, failed)
+
+	// the registered implementation is tested, not the built-in one with the vector params
+	r = hasher.NewRegistry(domain.AlgorithmArgon2id)
+	r.Register(domain.AlgorithmArgon2id, func() hasher.Encryptor {
+		return brokenEncryptor{hasher.NewArgon2idEncryptor(hasher.Argon2Params{Memory: 256, Iterations: 2, Lanes: 1})}
+	})
+	r.Register(domain.AlgorithmScrypt, func() hasher.Encryptor {
+		return brokenEncryptor{hasher.NewScryptEncryptor(hasher.ScryptParams{N: 1 << 12, R: 8, P: 1})}
+	})
+	r.Register(domain.AlgorithmPBKDF2SHA512, func() hasher.Encryptor {
+		return hasher.NewPBKDF2SHA512Encryptor(hasher.PBKDF2Params{Iterations: 1000, SaltLen: 16, KeyLen: 64})
+	})
+
+	report = r.SelfTest()
+	failed = map[domain.Algorithm]bool{}
+	for _, result := range report.Results {
+		failed[result.Algorithm] = result.Err != nil
+	}
+	assert.True(failed[domain.AlgorithmArgon2id])
+	assert.True(failed[domain.AlgorithmScrypt])
+	assert.False(failed[domain.AlgorithmPBKDF2SHA512])
+
+	// an algorithm without test vectors cannot pass
+	r = hasher.NewRegistry("custom")
+	r.Register("custom", hasher.NewSHA512Encryptor)
+	report = r.SelfTest()
+	assert.False(report.Passed())
+	assert.Equal(domain.Algorithm("custom"), report.Results[0].Algorithm)
+	assert.Equal(0, report.Results[0].Vectors)
+	assert.Error(report.Results[0].Err)
+}
@@ -1,87 +1,351 @@
 package hasher
 
 import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/plar/hash/domain"
 	"github.com/plar/hash/domain/repository"
+	"github.com/plar/hash/infra/breach"
 	"github.com/plar/hash/infra/pool"
 	"github.com/plar/hash/server/config"
+	"github.com/plar/hash/service/stats"
 )
 
 // Service interface declares the Hasher service methods
 type Service interface {
 	// Create creates a new hash request
-	Create(password string) (domain.HashID, error)
+	Create(password string, opts CreateOptions) (domain.HashID, error)
+
+	// Import stores an externally produced password hash in the modular crypt format,
+	// the imported hash can be verified and is upgraded on the first successful verification
+	Import(encoded string) (domain.HashID, error)
 
 	// Get retrieves a password hash by id
 	// returns an error if the password hash was not found
 	Get(id domain.HashID) (domain.Hash, error)
 
+	// Verify checks a password against the stored password hash,
+	// an outdated password hash is transparently replaced after a successful verification
+	// returns an error if the password hash was not found
+	Verify(id domain.HashID, password string) (VerifyResult, error)
+
+	// NewReliefParams returns the algorithm, current cost parameters and a new salt of a server relief hash,
+	// the default algorithm is used for the empty name
+	NewReliefParams(algorithm domain.Algorithm) (ReliefParams, error)
+
+	// ReliefParams returns the algorithm, cost parameters and salt of a stored server relief hash.
+	// A missing hash or a hash which is not a server relief hash is replaced with fake parameters derived from a secret,
+	// the response looks the same but no key can ever match.
+	ReliefParams(id domain.HashID) (ReliefParams, error)
+
+	// CreateRelief stores a server relief hash of the client key computed with the parameters,
+	// the parameters must be the current ones. The key is hashed once more with the pepper.
+	CreateRelief(params ReliefParams, key []byte) (domain.HashID, error)
+
+	// VerifyRelief checks a client key against the stored server relief hash, other hashes never match
+	// returns an error if the password hash was not found
+	VerifyRelief(id domain.HashID, key []byte) (bool, error)
+
+	// Wrap upgrades stored SHA512 hashes without plaintext passwords,
+	// every SHA512 hash is wrapped with Argon2id and the record is marked with the wrapped algorithm
+	Wrap() WrapResult
+
+	// StartWrap runs the wrap migration in the background,
+	// returns ErrWrapRunning if the background migration is already running
+	StartWrap() error
+
+	// WrapProgress returns the progress of the background wrap migration
+	WrapProgress() WrapProgress
+
+	// Calibration returns the calibrated cost parameters, empty if the calibration is disabled
+	Calibration() []Calibration
+
+	// SelfTest runs the known-answer tests of all algorithms
+	SelfTest() SelfTestReport
+
+	// Probe submits the probe password through the hash pool and the repository,
+	// waits for the hash up to timeout and verifies it. The probe record is deleted afterwards.
+	Probe(timeout time.Duration) error
+
 	// Stop the server
 	Stop()
 }
 
+// CreateOptions defines optional parameters of a new hash request
+type CreateOptions struct {
+	// Algorithm overrides the default algorithm, must be in the allowlist
+	Algorithm domain.Algorithm
+	// Pipeline overrides the default pipeline, must be one of the configured pipelines
+	Pipeline string
+	// Tenant is the caller identity used by the rollout policy, empty if unknown
+	Tenant string
+}
+
+// VerifyResult is the result of a password verification
+type VerifyResult struct {
+	// Valid is true if the password matches the stored hash
+	Valid bool
+	// Rehashed is true if the stored hash has been upgraded to the current algorithm and parameters
+	Rehashed bool
+}
+
+// WrapResult is the result of the wrap migration
+type WrapResult struct {
+	// Wrapped is the number of wrapped hashes
+	Wrapped int
+	// Skipped is the number of hashes which could not be wrapped, e.g. modified concurrently
+	Skipped int
+}
+
+// WrapProgress is the progress of the background wrap migration
+type WrapProgress struct {
+	// Running is true while the migration is running
+	Running bool
+	WrapResult
+}
+
 var _ Service = &service{}
 
 type service struct {
 	taskQueue  chan pool.Task
 	dispatcher *pool.Dispatcher
+	// governor reserves the memory of the hashes calculated outside the pool, nil if disabled
+	governor *pool.Governor
 
 	hashRepo repository.HashRepository
 	delay    time.Duration
+	registry *Registry
+	pepper   *pepper
+	rehash   bool
+
+	// importMaxRounds and importMaxBcryptCost limit the cost of imported hashes
+	importMaxRounds     int
+	importMaxBcryptCost int
+
+	pipelines map[string]domain.Pipeline
+	pipeline  string
+	policy    *policy
+	// passwordPolicy checks new passwords
+	passwordPolicy *PasswordPolicy
+	// breachCorpus rejects breached passwords, nil if disabled
+	breachCorpus breach.Corpus
+	// normalization is the Unicode normalization mode of new hashes
+	normalization domain.Stage
+	stats         stats.Service
+
+	// wrapParams are Argon2id parameters of the wrap migration
+	wrapParams Argon2Params
+	// wrapJob is the background wrap migration
+	wrapJob wrapJob
+	// abandoned are the probe records which timed out before they were saved
+	abandoned abandonedProbes
+
+	calibration []Calibration
+
+	// secret derives the fake parameters of the unknown server relief hashes,
+	// so callers cannot probe which relief hashes exist
+	secret []byte
+
+	// dummy is the fake hash of the default target verified instead of the missing hashes
+	dummy domain.Hash
+}
+
+// Gauges of the memory governor
+const (
+	// StatsMemoryReserved is the reserved memory in KiB
+	StatsMemoryReserved = "Pool.MemoryReserved"
+	// StatsMemoryWaiters is the number of jobs waiting for memory
+	StatsMemoryWaiters = "Pool.MemoryWaiters"
+)
+
+// statsWrap is the metric of the wrapped hashes
+const statsWrap = "Hasher.Wrap"
+
+// New creates a new hasher service without the breach check
+func New(hashRepo repository.HashRepository, cfg config.Config, statsSvc stats.Service) Service {
+	return NewWithBreachCorpus(hashRepo, cfg, statsSvc, nil)
 }
 
-// New creates a new hasher service
-func New(hashRepo repository.HashRepository, cfg config.Config) Service {
+// NewWithBreachCorpus creates a new hasher service which rejects new passwords found in the breach corpus,
+// the breach check is disabled if the corpus is nil
+func NewWithBreachCorpus(hashRepo repository.HashRepository, cfg config.Config, statsSvc stats.Service, breachCorpus breach.Corpus) Service {
 
 	// Create the task queue
 	taskQueue := make(chan pool.Task, cfg.QueueSize())
 
-	// Start the dispatcher with 8 workers
-	dispatcher := pool.NewDispatcher(taskQueue, cfg.TotalWorkers())
+	// Memory-hard algorithms reserve their memory cost in the governor before execution
+	var governor *pool.Governor
+	if cfg.MemoryBudget() > 0 {
+		governor = pool.NewGovernor(cfg.MemoryBudget(), func(reserved uint64, waiters int) {
+			statsSvc.SetGauge(StatsMemoryReserved, int64(reserved))
+			statsSvc.SetGauge(StatsMemoryWaiters, int64(waiters))
+		})
+	}
+
+	// Start the dispatcher with cfg.TotalWorkers() workers
+	dispatcher := pool.NewDispatcherWithGovernor(taskQueue, cfg.TotalWorkers(), governor)
 	dispatcher.Run()
 
-	return &service{
-		taskQueue:  taskQueue,
-		dispatcher: dispatcher,
-		hashRepo:   hashRepo,
-		delay:      cfg.TaskDelay(),
+	registry := NewRegistryFromConfig(cfg)
+	var calibration []Calibration
+	if cfg.Calibrate() {
+		calibration = registry.Calibrate(cfg.CalibrationTarget(), cfg.CalibrationMaxMemory())
+		for _, c := range calibration {
+			log.Printf("the hasher service calibrated algorithm=%v params=%v duration=%v memory=%vKiB", c.Algorithm, c.Params, c.Duration, c.Memory)
+		}
+	}
+
+	secret, err := newSalt(sha256.Size)
+	if err != nil {
+		panic(fmt.Sprintf("hasher: cannot generate the secret: %v", err))
+	}
+
+	s := &service{
+		taskQueue:     taskQueue,
+		dispatcher:    dispatcher,
+		governor:      governor,
+		hashRepo:      hashRepo,
+		delay:         cfg.TaskDelay(),
+		registry:      registry,
+		pepper:        newPepper(cfg.PepperKeys()),
+		rehash:        cfg.RehashOnVerify(),
+		pipelines:     cfg.Pipelines(),
+		pipeline:      cfg.Pipeline(),
+		policy:        newPolicy(cfg, registry),
+		normalization: cfg.Normalization(),
+
+		importMaxRounds:     cfg.ImportMaxRounds(),
+		importMaxBcryptCost: cfg.ImportMaxBcryptCost(),
+
+		passwordPolicy: NewPasswordPolicyFromConfig(cfg),
+		breachCorpus:   breachCorpus,
+		stats:          statsSvc,
+		wrapParams: Argon2Params{
+			Memory:     cfg.Argon2Memory(),
+			Iterations: cfg.Argon2Iterations(),
+			Lanes:      cfg.Argon2Lanes(),
+		},
+
+		calibration: calibration,
+		secret:      secret,
 	}
+	s.dummy = s.newDummy()
+	return s
 }
 
-func (s *service) Create(password string) (domain.HashID, error) {
-	// pre-validate input args
-	if len(password) == 0 {
-		return 0, ErrInvalidPassword
+func (s *service) Create(password string, opts CreateOptions) (domain.HashID, error) {
+	return s.create(password, opts, false)
+}
+
+// create creates a new hash request, probe requests create probe records
+func (s *service) create(password string, opts CreateOptions, probe bool) (domain.HashID, error) {
+	normalized, err := normalize(s.normalization, []byte(password))
+	if err != nil {
+		return 0, err
+	}
+
+	// the probe password is known to be valid, the policy may change at any time
+	if !probe {
+		if err := s.checkPassword([]byte(password), normalized); err != nil {
+			return 0, err
+		}
 	}
 
 	hashID := s.hashRepo.NewID()
+	arm := s.policy.Select(hashID, opts)
+	kdf, pipeline, err := s.armTarget(arm, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	keyID := pepperKeyID(kdf.Algorithm(), s.pepper.CurrentKeyID())
+	encryptor, input, err := s.newEncryptor(kdf, pipeline, keyID, normalized)
+	if err != nil {
+		return 0, err
+	}
+
+	if v, ok := encryptor.(passwordValidator); ok {
+		if err := v.Validate(input); err != nil {
+			return 0, err
+		}
+	}
+
+	salt, err := newSaltFor(encryptor)
+	if err != nil {
+		return 0, err
+	}
 
 	// Execute calculation of the hash code asynchronously.
 	// We can get stuck here if we have more then 1000 tasks in the taskQueue.
 	// A goroutine can be used before `go s.dispatcher.Dispatch(...)`
 	// but in that case we can run out of memory.
-	s.dispatcher.Dispatch(pool.Task{
-		ID: int64(hashID),
+	err = s.dispatcher.Dispatch(pool.Task{
+		ID:     int64(hashID),
+		Memory: encryptor.Memory(),
+		// sim long-running process...
+		Delay: s.delay,
 		Handler: func() {
-			// sim long-running process...
-			time.Sleep(s.delay)
-
 			// calc hash
-			encryptor := NewSHA512Encryptor()
-			hash := encryptor.Hash([]byte(password))
+			begin := time.Now()
+			hash, err := encryptor.Hash(input, salt)
+			if err != nil {
+				log.Printf("the hasher service cannot calculate hash id=%v: %v", hashID, err)
+				if probe {
+					s.probeSaved(hashID)
+				}
+				return
+			}
+			if !probe {
+				s.stats.TrackMetric(StatsPolicyPrefix+arm, 1, time.Since(begin))
+			}
 
 			// update storage
-			s.hashRepo.Save(hashID, hash)
+			s.hashRepo.Save(domain.Hash{
+				ID:        hashID,
+				Hash:      hash,
+				Salt:      salt,
+				Algorithm: encryptor.Algorithm(),
+				Params:    encryptor.Params(),
+				KeyID:     keyID,
+				Pipeline:  pipeline.ID(),
+
+				Normalization: s.normalization,
+				Probe:         probe,
+			})
+			if probe {
+				s.probeSaved(hashID)
+			}
 		},
 	})
+	if err != nil {
+		return 0, err
+	}
 
 	return hashID, nil
 }
 
+func (s *service) Import(encoded string) (domain.HashID, error) {
+	hash, err := ParseLegacyHash(encoded)
+	if err != nil {
+		return 0, err
+	}
+	if err := checkImportCost(hash, s.importMaxRounds, s.importMaxBcryptCost); err != nil {
+		return 0, err
+	}
+
+	hash.ID = s.hashRepo.NewID()
+	s.hashRepo.Save(hash)
+	return hash.ID, nil
+}
+
 func (s *service) Get(id domain.HashID) (domain.Hash, error) {
-	hash, err := s.hashRepo.Load(id)
+	hash, err := s.load(id)
 	if err != nil {
 		return domain.Hash{}, err
 	}
@@ -89,6 +353,341 @@ func (s *service) Get(id domain.HashID) (domain.Hash, error) {
 	return hash, nil
 }
 
+// load loads a password hash record, probe records are not visible to clients
+func (s *service) load(id domain.HashID) (domain.Hash, error) {
+	hash, err := s.hashRepo.Load(id)
+	if err == nil && hash.Probe {
+		return domain.Hash{}, fmt.Errorf("HashID '%v': %w", id, repository.ErrHashNotFound)
+	}
+	return hash, err
+}
+
+func (s *service) Verify(id domain.HashID, password string) (VerifyResult, error) {
+	if len(password) == 0 {
+		return VerifyResult{}, ErrInvalidPassword
+	}
+
+	hash, err := s.load(id)
+	if err != nil {
+		// burn the same amount of time as a real verification,
+		// so callers cannot probe which hashes exist
+		s.verifyDummy(password)
+		return VerifyResult{}, err
+	}
+
+	valid, err := s.verify(hash, password)
+	if errors.Is(err, ErrInvalidUnicode) || errors.Is(err, ErrSCRAMPassword) || errors.Is(err, ErrPlaintextVerify) {
+		// a password rejected by the normalization or the SCRAM encryptor and a hash which cannot be verified
+		// with a plaintext password never match, they are reported as a mismatch at the cost of a real verification,
+		// so the response does not reveal that the hash exists or its type
+		s.verifyDummy(password)
+		return VerifyResult{}, nil
+	}
+	if err != nil || !valid {
+		return VerifyResult{}, err
+	}
+
+	result := VerifyResult{Valid: true}
+	if s.rehash {
+		result.Rehashed = s.rehashOutdated(hash, password)
+	}
+	return result, nil
+}
+
+// verify checks a password against the stored password hash with its pipeline, pepper key and normalization
+func (s *service) verify(hash domain.Hash, password string) (bool, error) {
+	if hash.Algorithm.IsSRP() {
+		return false, fmt.Errorf("%w: '%v'", ErrPlaintextVerify, hash.Algorithm)
+	}
+	if hash.Relief {
+		return false, fmt.Errorf("%w: HashID '%v' is a server relief hash", ErrPlaintextVerify, hash.ID)
+	}
+
+	pipeline, err := domain.ParsePipeline(hash.Pipeline)
+	if err != nil {
+		return false, err
+	}
+
+	kdf, err := newEncryptorFor(hash)
+	if err != nil {
+		return false, err
+	}
+
+	normalized, err := normalize(hash.Normalization, []byte(password))
+	if err != nil {
+		return false, err
+	}
+
+	encryptor, input, err := s.newEncryptor(kdf, pipeline, hash.KeyID, normalized)
+	if err != nil {
+		return false, err
+	}
+
+	release := s.reserve(encryptor.Memory())
+	defer release()
+	return verifyPassword(encryptor, input, hash)
+}
+
+// reserve reserves the memory of a hash calculated outside the pool in the governor,
+// the returned function releases it
+func (s *service) reserve(memory uint64) func() {
+	if s.governor == nil {
+		return func() {}
+	}
+	s.governor.Acquire(memory)
+	return func() {
+		s.governor.Release(memory)
+	}
+}
+
+// rehashOutdated recomputes the password hash with the default algorithm, parameters and pepper key
+// if the stored hash is outdated and replaces the stored record.
+// The plaintext password is only available during a successful verification, so it is the only chance to do it.
+func (s *service) rehashOutdated(old domain.Hash, password string) bool {
+	keyID := s.pepper.CurrentKeyID()
+	if s.isUpToDate(old, keyID) {
+		return false
+	}
+
+	// the tenant is unknown here, so the hash is upgraded to the arm of its bucket
+	kdf, pipeline, err := s.armTarget(s.policy.Select(old.ID, CreateOptions{}), CreateOptions{})
+	if err != nil {
+		return false
+	}
+
+	hash, err := s.calcHash(kdf, pipeline, pepperKeyID(kdf.Algorithm(), keyID), old.ID, password)
+	if err != nil {
+		log.Printf("the hasher service cannot rehash id=%v: %v", old.ID, err)
+		return false
+	}
+
+	// the hash might have been replaced by a concurrent verification, it's fine
+	if err := s.hashRepo.Replace(old, hash); err != nil {
+		log.Printf("the hasher service cannot replace hash id=%v: %v", old.ID, err)
+		return false
+	}
+	return true
+}
+
+// calcHash calculates a new password hash record with a fresh salt
+func (s *service) calcHash(kdf Encryptor, pipeline domain.Pipeline, keyID string, id domain.HashID, password string) (domain.Hash, error) {
+	normalized, err := normalize(s.normalization, []byte(password))
+	if err != nil {
+		return domain.Hash{}, err
+	}
+
+	encryptor, input, err := s.newEncryptor(kdf, pipeline, keyID, normalized)
+	if err != nil {
+		return domain.Hash{}, err
+	}
+
+	if v, ok := encryptor.(passwordValidator); ok {
+		if err := v.Validate(input); err != nil {
+			return domain.Hash{}, err
+		}
+	}
+
+	salt, err := newSaltFor(encryptor)
+	if err != nil {
+		return domain.Hash{}, err
+	}
+
+	release := s.reserve(encryptor.Memory())
+	hash, err := encryptor.Hash(input, salt)
+	release()
+	if err != nil {
+		return domain.Hash{}, err
+	}
+
+	return domain.Hash{
+		ID:        id,
+		Hash:      hash,
+		Salt:      salt,
+		Algorithm: encryptor.Algorithm(),
+		Params:    encryptor.Params(),
+		KeyID:     keyID,
+		Pipeline:  pipeline.ID(),
+
+		Normalization: s.normalization,
+	}, nil
+}
+
+// target returns the KDF encryptor and the pipeline for new hashes.
+// An explicitly requested algorithm is used as is, otherwise the requested or the default pipeline is used.
+func (s *service) target(opts CreateOptions) (Encryptor, domain.Pipeline, error) {
+	if opts.Algorithm != "" && opts.Pipeline != "" {
+		return nil, domain.Pipeline{}, ErrPipelineAlgorithm
+	}
+
+	name := opts.Pipeline
+	if name == "" && opts.Algorithm == "" {
+		name = s.pipeline
+	}
+	if name == "" {
+		kdf, err := s.registry.New(opts.Algorithm)
+		return kdf, domain.Pipeline{}, err
+	}
+
+	pipeline, ok := s.pipelines[name]
+	if !ok {
+		return nil, domain.Pipeline{}, fmt.Errorf("%w: '%v'", ErrUnknownPipeline, name)
+	}
+	kdf, err := s.registry.New(pipeline.KDF)
+	return kdf, pipeline, err
+}
+
+// armTarget returns the KDF encryptor and the pipeline of the policy arm,
+// the default and override arms are resolved by target.
+func (s *service) armTarget(arm string, opts CreateOptions) (Encryptor, domain.Pipeline, error) {
+	if a, ok := s.policy.Arm(arm); ok {
+		kdf, err := s.registry.NewWithParams(a.Algorithm, a.Params)
+		return kdf, domain.Pipeline{}, err
+	}
+	return s.target(opts)
+}
+
+// isUpToDate reports whether the hash uses the current pepper key, normalization mode and
+// the algorithm, parameters and pipeline of the default arm or any other policy arm,
+// so hashes do not flip between arms during a rollout.
+func (s *service) isUpToDate(hash domain.Hash, keyID string) bool {
+	if hash.KeyID != pepperKeyID(hash.Algorithm, keyID) || hash.Normalization != s.normalization {
+		return false
+	}
+
+	matches := func(kdf Encryptor, pipeline domain.Pipeline) bool {
+		return hash.Algorithm == kdf.Algorithm() && hash.Params == kdf.Params() && hash.Pipeline == pipeline.ID()
+	}
+
+	if kdf, pipeline, err := s.target(CreateOptions{}); err == nil && matches(kdf, pipeline) {
+		return true
+	}
+	for _, arm := range s.policy.Arms() {
+		if kdf, err := s.registry.NewWithParams(arm.Algorithm, arm.Params); err == nil && matches(kdf, domain.Pipeline{}) {
+			return true
+		}
+	}
+	return false
+}
+
+// newEncryptor wraps the KDF encryptor with the pipeline and returns the encryptor input.
+// Plain algorithms get the peppered password, pipelines mix the pepper key in themselves.
+func (s *service) newEncryptor(kdf Encryptor, pipeline domain.Pipeline, keyID string, password []byte) (Encryptor, []byte, error) {
+	if pipeline.IsZero() {
+		peppered, err := s.pepper.Apply(keyID, password)
+		return kdf, peppered, err
+	}
+
+	var key []byte
+	if keyID != "" || pipeline.HMAC != "" {
+		var err error
+		if key, err = s.pepper.Secret(keyID); err != nil {
+			return nil, nil, err
+		}
+	}
+	return newPipelineEncryptor(pipeline, kdf, key), password, nil
+}
+
+// pepperKeyID returns the pepper key of new hashes of the algorithm,
+// SCRAM verifiers are consumed by external servers which know nothing about the pepper.
+func pepperKeyID(algorithm domain.Algorithm, keyID string) string {
+	if algorithm.IsSCRAM() {
+		return ""
+	}
+	return keyID
+}
+
+// newDummy calculates the fake hash of verifyDummy with the default target, empty if the target is invalid
+func (s *service) newDummy() domain.Hash {
+	kdf, pipeline, err := s.target(CreateOptions{})
+	if err != nil {
+		return domain.Hash{}
+	}
+	hash, err := s.calcHash(kdf, pipeline, pepperKeyID(kdf.Algorithm(), s.pepper.CurrentKeyID()), 0, "dummy password")
+	if err != nil {
+		log.Printf("the hasher service cannot calculate the dummy hash: %v", err)
+		return domain.Hash{}
+	}
+	return hash
+}
+
+// verifyDummy verifies password against the fake hash of the default target,
+// it costs exactly one hash calculation like a real verification.
+// The password is not normalized, so the calculation is not skipped for invalid Unicode.
+func (s *service) verifyDummy(password string) {
+	if s.dummy.Algorithm != "" {
+		dummy := s.dummy
+		dummy.Normalization = ""
+		s.verify(dummy, password)
+	}
+}
+
+func (s *service) Wrap() WrapResult {
+	var job wrapJob
+	s.wrap(&job)
+	return job.result()
+}
+
+// wrap runs the wrap migration and counts the wrapped and skipped hashes of the job
+func (s *service) wrap(job *wrapJob) {
+	begin := time.Now()
+	encryptor := NewArgon2idSHA512Encryptor(s.wrapParams).(*argon2idSHA512Encryptor)
+
+	var wg sync.WaitGroup
+	s.hashRepo.Range(func(old domain.Hash) bool {
+		if old.Algorithm != domain.AlgorithmSHA512 || old.Pipeline != "" {
+			return true
+		}
+
+		wg.Add(1)
+		err := s.dispatcher.Dispatch(pool.Task{
+			ID:     int64(old.ID),
+			Memory: encryptor.Memory(),
+			Handler: func() {
+				defer wg.Done()
+
+				hash, err := encryptor.Wrap(old.Hash, old.Salt)
+				if err == nil {
+					// the pepper key stays the same, it is applied before SHA512
+					err = s.hashRepo.Replace(old, domain.Hash{
+						ID:        old.ID,
+						Hash:      hash,
+						Salt:      old.Salt,
+						Algorithm: encryptor.Algorithm(),
+						Params:    encryptor.Params(),
+						KeyID:     old.KeyID,
+
+						Normalization: old.Normalization,
+					})
+				}
+				if err != nil {
+					log.Printf("the hasher service cannot wrap hash id=%v: %v", old.ID, err)
+					atomic.AddInt64(&job.skipped, 1)
+					return
+				}
+				atomic.AddInt64(&job.wrapped, 1)
+			},
+		})
+		if err != nil {
+			// the pool is stopped, the rejected task never runs
+			wg.Done()
+			log.Printf("the hasher service stops the wrap migration: %v", err)
+			return false
+		}
+		return true
+	})
+	wg.Wait()
+
+	s.stats.TrackMetric(statsWrap, atomic.LoadInt64(&job.wrapped), time.Since(begin))
+}
+
+func (s *service) SelfTest() SelfTestReport {
+	return s.registry.SelfTest()
+}
+
+func (s *service) Calibration() []Calibration {
+	return s.calibration
+}
+
 func (s *service) Stop() {
 	s.dispatcher.Stop()
 }
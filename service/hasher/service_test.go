@@ -1,16 +1,24 @@
 package hasher_test
 
 import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
 	"errors"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/plar/hash/domain"
+	"github.com/plar/hash/domain/repository"
 	"github.com/plar/hash/infra/persistence/memory"
 	"github.com/plar/hash/server/config"
 	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/stats"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
 )
 
 type testConfig struct {
@@ -25,12 +33,17 @@ func Config() config.Config {
 	return &testConfig{}
 }
 
+func saltedSHA512(salt []byte, password string) []byte {
+	digest := sha512.Sum512(append(append([]byte{}, salt...), password...))
+	return digest[:]
+}
+
 func TestNew(t *testing.T) {
 	assert := assert.New(t)
 
 	repo := memory.NewHashRepository()
 
-	svc := hasher.New(repo, Config())
+	svc := hasher.New(repo, Config(), stats.New())
 	assert.NotNil(svc)
 	svc.Stop()
 }
@@ -41,15 +54,15 @@ func TestCreate(t *testing.T) {
 
 	repo := memory.NewHashRepository()
 
-	svc := hasher.New(repo, Config())
+	svc := hasher.New(repo, Config(), stats.New())
 
 	// bad password
-	_, err = svc.Create("")
+	_, err = svc.Create("", hasher.CreateOptions{})
 	assert.Error(err)
-	assert.True(errors.Is(err, hasher.ErrInvalidPassword))
+	assert.True(errors.Is(err, hasher.ErrPasswordPolicy))
 
 	// good password
-	hashID, err := svc.Create("angryMonkey")
+	hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
 	assert.NoError(err)
 	assert.Equal(domain.HashID(1), hashID)
 
@@ -68,10 +81,22 @@ func TestCreate(t *testing.T) {
 	}()
 	wg.Wait()
 
-	assert.Equal("hash{ID: 1, Hash: ZEHhWB65gUlzdVwtDQArEyx+KVLzp/aTaRaPlBzYRIFj6vjFdqEb0Q5B8zVKCZ0vKbZPZklJz0Fd7su2A+gf7Q==}", hash.String())
 	assert.NoError(err)
+	assert.Equal(domain.HashID(1), hash.ID)
+	assert.Equal(domain.AlgorithmSHA512, hash.Algorithm)
+	assert.Len(hash.Salt, 16)
+	assert.Equal(saltedSHA512(hash.Salt, "angryMonkey"), hash.Hash)
 
+	// the same password gets a different salt
+	hashID, err = svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
 	svc.Stop()
+
+	other, err := repo.Load(hashID)
+	assert.NoError(err)
+	assert.NotEqual(hash.Salt, other.Salt)
+	assert.NotEqual(hash.Hash, other.Hash)
+	assert.Equal(saltedSHA512(other.Salt, "angryMonkey"), other.Hash)
 }
 
 func TestGet(t *testing.T) {
@@ -80,15 +105,15 @@ func TestGet(t *testing.T) {
 
 	repo := memory.NewHashRepository()
 
-	svc := hasher.New(repo, Config())
+	svc := hasher.New(repo, Config(), stats.New())
 
 	// bad password
-	_, err = svc.Create("")
+	_, err = svc.Create("", hasher.CreateOptions{})
 	assert.Error(err)
-	assert.True(errors.Is(err, hasher.ErrInvalidPassword))
+	assert.True(errors.Is(err, hasher.ErrPasswordPolicy))
 
 	// good password
-	hashID, err := svc.Create("password")
+	hashID, err := svc.Create("password", hasher.CreateOptions{})
 	assert.NoError(err)
 	assert.Equal(domain.HashID(1), hashID)
 
@@ -107,8 +132,9 @@ func TestGet(t *testing.T) {
 	}()
 	wg.Wait()
 
-	assert.Equal("hash{ID: 1, Hash: sQnzu7wkTrgkQZF+0G1hi5AI3Qmzvv0bXgc5THBqi7mAsdd4Xll27ASbRt9fEyavWi6m0QP9B8lThf+rDKy8hg==}", hash.String())
 	assert.NoError(err)
+	assert.Equal(domain.HashID(1), hash.ID)
+	assert.Equal(saltedSHA512(hash.Salt, "password"), hash.Hash)
 
 	// bad id
 	hash, err = svc.Get(domain.HashID(2))
@@ -117,3 +143,414 @@ func TestGet(t *testing.T) {
 
 	svc.Stop()
 }
+
+type argon2Config struct {
+	testConfig
+}
+
+func (c *argon2Config) Algorithm() domain.Algorithm {
+	return domain.AlgorithmArgon2id
+}
+
+func (c *argon2Config) Argon2Memory() uint32 {
+	return 1024
+}
+
+func TestCreateArgon2id(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+
+	svc := hasher.New(repo, &argon2Config{}, stats.New())
+
+	hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+
+	// wait for all pending tasks
+	svc.Stop()
+
+	hash, err := repo.Load(hashID)
+	assert.NoError(err)
+	assert.Equal(domain.AlgorithmArgon2id, hash.Algorithm)
+	assert.Equal("m=1024,t=1,p=2", hash.Params)
+	assert.Len(hash.Salt, 16)
+	assert.Equal(argon2.IDKey([]byte("angryMonkey"), hash.Salt, 1, 1024, 2, 32), hash.Hash)
+}
+
+type bcryptConfig struct {
+	testConfig
+	preHash bool
+}
+
+func (c *bcryptConfig) Algorithm() domain.Algorithm {
+	return domain.AlgorithmBcrypt
+}
+
+func (c *bcryptConfig) BcryptCost() int {
+	return 4
+}
+
+func (c *bcryptConfig) BcryptPreHash() bool {
+	return c.preHash
+}
+
+func TestCreateBcryptPasswordTooLong(t *testing.T) {
+	assert := assert.New(t)
+
+	password := strings.Repeat("a", 73)
+
+	svc := hasher.New(memory.NewHashRepository(), &bcryptConfig{}, stats.New())
+	_, err := svc.Create(password, hasher.CreateOptions{})
+	assert.True(errors.Is(err, hasher.ErrPasswordTooLong))
+	svc.Stop()
+
+	svc = hasher.New(memory.NewHashRepository(), &bcryptConfig{preHash: true}, stats.New())
+	_, err = svc.Create(password, hasher.CreateOptions{})
+	assert.NoError(err)
+	svc.Stop()
+}
+
+func TestVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	configs := []config.Config{
+		Config(),
+		&argon2Config{},
+		&bcryptConfig{},
+		&bcryptConfig{preHash: true},
+		&scryptConfig{},
+		&pbkdf2Config{},
+	}
+
+	for _, cfg := range configs {
+		repo := memory.NewHashRepository()
+		svc := hasher.New(repo, cfg, stats.New())
+
+		hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+		assert.NoError(err)
+		svc.Stop()
+
+		result, err := svc.Verify(hashID, "angryMonkey")
+		assert.NoError(err, cfg.Algorithm())
+		assert.True(result.Valid, cfg.Algorithm())
+		assert.False(result.Rehashed, cfg.Algorithm())
+
+		result, err = svc.Verify(hashID, "happyMonkey")
+		assert.NoError(err, cfg.Algorithm())
+		assert.False(result.Valid, cfg.Algorithm())
+
+		// bad password
+		result, err = svc.Verify(hashID, "")
+		assert.True(errors.Is(err, hasher.ErrInvalidPassword))
+		assert.False(result.Valid)
+
+		// bad id
+		result, err = svc.Verify(hashID+1, "angryMonkey")
+		assert.True(errors.Is(err, repository.ErrHashNotFound))
+		assert.False(result.Valid)
+	}
+}
+
+func TestVerifyInvalidParams(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, Config(), stats.New())
+	defer svc.Stop()
+
+	repo.Save(domain.Hash{ID: 1, Hash: []byte{1}, Algorithm: domain.AlgorithmArgon2id, Params: "m=1,t=1"})
+	_, err := svc.Verify(1, "angryMonkey")
+	assert.True(errors.Is(err, hasher.ErrInvalidParams))
+
+	repo.Save(domain.Hash{ID: 2, Hash: []byte{1}, Algorithm: "md5"})
+	_, err = svc.Verify(2, "angryMonkey")
+	assert.True(errors.Is(err, hasher.ErrUnknownAlgorithm))
+}
+
+type scryptConfig struct {
+	testConfig
+}
+
+func (c *scryptConfig) Algorithm() domain.Algorithm {
+	return domain.AlgorithmScrypt
+}
+
+func (c *scryptConfig) ScryptN() int {
+	return 16
+}
+
+type pbkdf2Config struct {
+	testConfig
+}
+
+func (c *pbkdf2Config) Algorithm() domain.Algorithm {
+	return domain.AlgorithmPBKDF2SHA512
+}
+
+func (c *pbkdf2Config) PBKDF2Iterations() int {
+	return 1000
+}
+
+type pepperConfig struct {
+	testConfig
+	keys []config.PepperKey
+}
+
+func (c *pepperConfig) PepperKeys() []config.PepperKey {
+	return c.keys
+}
+
+func (c *pepperConfig) RehashOnVerify() bool {
+	return false
+}
+
+func TestPepperRotation(t *testing.T) {
+	assert := assert.New(t)
+
+	key1 := config.PepperKey{ID: "k1", Secret: []byte("0123456789abcdef")}
+	key2 := config.PepperKey{ID: "k2", Secret: []byte("fedcba9876543210")}
+
+	repo := memory.NewHashRepository()
+
+	svc := hasher.New(repo, &pepperConfig{keys: []config.PepperKey{key1}}, stats.New())
+	oldID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	svc.Stop()
+
+	old, err := repo.Load(oldID)
+	assert.NoError(err)
+	assert.Equal("k1", old.KeyID)
+	assert.NotEqual(saltedSHA512(old.Salt, "angryMonkey"), old.Hash)
+
+	// rotate keys, new hashes use the newest key
+	svc = hasher.New(repo, &pepperConfig{keys: []config.PepperKey{key1, key2}}, stats.New())
+	newID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	svc.Stop()
+
+	hash, err := repo.Load(newID)
+	assert.NoError(err)
+	assert.Equal("k2", hash.KeyID)
+
+	// old hashes still verify
+	for _, id := range []domain.HashID{oldID, newID} {
+		result, err := svc.Verify(id, "angryMonkey")
+		assert.NoError(err)
+		assert.True(result.Valid)
+
+		result, err = svc.Verify(id, "happyMonkey")
+		assert.NoError(err)
+		assert.False(result.Valid)
+	}
+
+	// the retired key is not available anymore
+	svc = hasher.New(repo, &pepperConfig{keys: []config.PepperKey{key2}}, stats.New())
+	_, err = svc.Verify(oldID, "angryMonkey")
+	assert.True(errors.Is(err, hasher.ErrUnknownPepperKey))
+	svc.Stop()
+}
+
+func TestCreateWithAlgorithm(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, &allowlistConfig{}, stats.New())
+
+	hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{Algorithm: domain.AlgorithmScrypt})
+	assert.NoError(err)
+
+	_, err = svc.Create("angryMonkey", hasher.CreateOptions{Algorithm: domain.AlgorithmArgon2id})
+	assert.True(errors.Is(err, hasher.ErrAlgorithmNotAllowed))
+
+	_, err = svc.Create("angryMonkey", hasher.CreateOptions{Algorithm: "md5"})
+	assert.True(errors.Is(err, hasher.ErrAlgorithmNotAllowed))
+	svc.Stop()
+
+	hash, err := repo.Load(hashID)
+	assert.NoError(err)
+	assert.Equal(domain.AlgorithmScrypt, hash.Algorithm)
+
+	result, err := svc.Verify(hashID, "angryMonkey")
+	assert.NoError(err)
+	assert.True(result.Valid)
+}
+
+func TestCreatePBKDF2DjangoSalt(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, Config(), stats.New())
+	defer svc.Stop()
+
+	hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{Algorithm: domain.AlgorithmPBKDF2SHA256})
+	assert.NoError(err)
+
+	// Django keeps the salt as text, 22 alphanumeric characters carry the entropy of 16 random bytes
+	hash := waitHash(repo, hashID)
+	assert.Regexp("^[A-Za-z0-9]{22}$", string(hash.Salt))
+
+	django, err := hash.Django()
+	assert.NoError(err)
+	fields := strings.Split(django, "$")
+	assert.Len(fields, 4)
+	assert.Equal("pbkdf2_sha256", fields[0])
+	assert.Equal("310000", fields[1])
+
+	// Django recomputes the key from the text salt
+	key := pbkdf2.Key([]byte("angryMonkey"), []byte(fields[2]), 310000, 32, sha256.New)
+	assert.Equal(base64.StdEncoding.EncodeToString(key), fields[3])
+
+	result, err := svc.Verify(hashID, "angryMonkey")
+	assert.NoError(err)
+	assert.True(result.Valid)
+}
+
+func TestVerifySRP(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, Config(), stats.New())
+	defer svc.Stop()
+
+	// SRP verifiers are checked with the login handshake only, the plaintext path reports a mismatch
+	repo.Save(domain.Hash{ID: 1, Algorithm: domain.AlgorithmSRP6a, Params: "n=2048", Salt: []byte("0123456789abcdef"), Hash: []byte{2}})
+	result, err := svc.Verify(1, "pencil")
+	assert.NoError(err)
+	assert.False(result.Valid)
+}
+
+func TestVerifyRehash(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+
+	// old weak hashes
+	svc := hasher.New(repo, Config(), stats.New())
+	hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	svc.Stop()
+
+	// the default algorithm has been changed
+	svc = hasher.New(repo, &argon2Config{}, stats.New())
+	defer svc.Stop()
+
+	// wrong password never rehashes
+	result, err := svc.Verify(hashID, "happyMonkey")
+	assert.NoError(err)
+	assert.False(result.Valid)
+	assert.False(result.Rehashed)
+
+	hash, err := repo.Load(hashID)
+	assert.NoError(err)
+	assert.Equal(domain.AlgorithmSHA512, hash.Algorithm)
+
+	result, err = svc.Verify(hashID, "angryMonkey")
+	assert.NoError(err)
+	assert.True(result.Valid)
+	assert.True(result.Rehashed)
+
+	hash, err = repo.Load(hashID)
+	assert.NoError(err)
+	assert.Equal(domain.AlgorithmArgon2id, hash.Algorithm)
+	assert.Equal("m=1024,t=1,p=2", hash.Params)
+
+	// up to date hash
+	result, err = svc.Verify(hashID, "angryMonkey")
+	assert.NoError(err)
+	assert.True(result.Valid)
+	assert.False(result.Rehashed)
+}
+
+func TestVerifyRehashStats(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	statsSvc := stats.New()
+
+	svc := hasher.New(repo, Config(), stats.New())
+	hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	svc.Stop()
+
+	svc = hasher.NewInstrumentingService(hasher.New(repo, &argon2Config{}, statsSvc), statsSvc)
+	defer svc.Stop()
+
+	_, err = svc.Verify(hashID, "angryMonkey")
+	assert.NoError(err)
+	_, err = svc.Verify(hashID, "angryMonkey")
+	assert.NoError(err)
+
+	assert.Equal(int64(2), statsSvc.Metric("Hasher.Verify").Count())
+	assert.Equal(int64(1), statsSvc.Metric("Hasher.Rehash").Count())
+}
+
+type memoryBudgetConfig struct {
+	argon2Config
+}
+
+func (c *memoryBudgetConfig) MemoryBudget() uint64 {
+	return 1024
+}
+
+func TestCreateMemoryBudget(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	statsSvc := stats.New()
+	svc := hasher.New(repo, &memoryBudgetConfig{}, statsSvc)
+
+	var ids []domain.HashID
+	for i := 0; i < 10; i++ {
+		hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+		assert.NoError(err)
+		ids = append(ids, hashID)
+	}
+	svc.Stop()
+
+	for _, id := range ids {
+		_, err := repo.Load(id)
+		assert.NoError(err)
+	}
+
+	// all reservations have been released
+	assert.Equal(int64(0), statsSvc.Gauge("Pool.MemoryReserved"))
+	assert.Equal(int64(0), statsSvc.Gauge("Pool.MemoryWaiters"))
+}
+
+// gaugeSpy records the peak values of the gauges
+type gaugeSpy struct {
+	stats.Service
+	lock sync.Mutex
+	peak map[string]int64
+}
+
+func (s *gaugeSpy) SetGauge(name string, value int64) {
+	s.lock.Lock()
+	if value > s.peak[name] {
+		s.peak[name] = value
+	}
+	s.lock.Unlock()
+	s.Service.SetGauge(name, value)
+}
+
+func TestVerifyMemoryBudget(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, &memoryBudgetConfig{}, stats.New())
+	hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	svc.Stop()
+
+	// the verification reserves the Argon2id memory outside the pool
+	statsSvc := &gaugeSpy{Service: stats.New(), peak: make(map[string]int64)}
+	svc = hasher.New(repo, &memoryBudgetConfig{}, statsSvc)
+	defer svc.Stop()
+	statsSvc.peak = make(map[string]int64)
+
+	result, err := svc.Verify(hashID, "angryMonkey")
+	assert.NoError(err)
+	assert.True(result.Valid)
+	assert.Equal(int64(1024), statsSvc.peak["Pool.MemoryReserved"])
+	assert.Equal(int64(0), statsSvc.Gauge("Pool.MemoryReserved"))
+}
@@ -0,0 +1,107 @@
+package hasher
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+
+	"github.com/plar/hash/domain"
+)
+
+// ErrWrapRunning is returned when the background wrap migration is already running
+var ErrWrapRunning = errors.New("Wrap migration is already running")
+
+// wrapJob is the state of a wrap migration
+type wrapJob struct {
+	// wrapped and skipped are updated atomically by the pool workers
+	wrapped int64
+	skipped int64
+
+	lock    sync.Mutex
+	running bool
+}
+
+func (j *wrapJob) result() WrapResult {
+	return WrapResult{
+		Wrapped: int(atomic.LoadInt64(&j.wrapped)),
+		Skipped: int(atomic.LoadInt64(&j.skipped)),
+	}
+}
+
+func (s *service) StartWrap() error {
+	job := &s.wrapJob
+	job.lock.Lock()
+	defer job.lock.Unlock()
+
+	if job.running {
+		return ErrWrapRunning
+	}
+	job.running = true
+	atomic.StoreInt64(&job.wrapped, 0)
+	atomic.StoreInt64(&job.skipped, 0)
+
+	go func() {
+		s.wrap(job)
+		result := job.result()
+		log.Printf("the hasher service finished the wrap migration wrapped=%v skipped=%v", result.Wrapped, result.Skipped)
+
+		job.lock.Lock()
+		job.running = false
+		job.lock.Unlock()
+	}()
+	return nil
+}
+
+func (s *service) WrapProgress() WrapProgress {
+	job := &s.wrapJob
+	job.lock.Lock()
+	running := job.running
+	job.lock.Unlock()
+
+	return WrapProgress{Running: running, WrapResult: job.result()}
+}
+
+type argon2idSHA512Encryptor struct {
+	inner Encryptor
+	outer Encryptor
+}
+
+// NewArgon2idSHA512Encryptor creates an encryptor of SHA512 hashes wrapped with Argon2id,
+// the same salt is used for both algorithms.
+func NewArgon2idSHA512Encryptor(params Argon2Params) Encryptor {
+	return &argon2idSHA512Encryptor{
+		inner: NewSHA512Encryptor(),
+		outer: NewArgon2idEncryptor(params),
+	}
+}
+
+func (e *argon2idSHA512Encryptor) Algorithm() domain.Algorithm {
+	return domain.AlgorithmArgon2idSHA512
+}
+
+func (e *argon2idSHA512Encryptor) Params() string {
+	return e.outer.Params()
+}
+
+func (e *argon2idSHA512Encryptor) SaltLen() int {
+	return e.inner.SaltLen()
+}
+
+func (e *argon2idSHA512Encryptor) Memory() uint64 {
+	return e.outer.Memory()
+}
+
+// Hash generates Argon2id key of the salted SHA512 hash of password
+func (e *argon2idSHA512Encryptor) Hash(password, salt []byte) ([]byte, error) {
+	digest, err := e.inner.Hash(password, salt)
+	if err != nil {
+		return nil, err
+	}
+	return e.Wrap(digest, salt)
+}
+
+// Wrap generates Argon2id key of the stored SHA512 hash, the password is not required
+func (e *argon2idSHA512Encryptor) Wrap(digest, salt []byte) ([]byte, error) {
+	return e.outer.Hash(digest, salt)
+}
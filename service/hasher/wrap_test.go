@@ -0,0 +1,199 @@
+package hasher_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/domain/repository"
+	"github.com/plar/hash/infra/persistence/memory"
+	"github.com/plar/hash/infra/pool"
+	"github.com/plar/hash/server/config"
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/stats"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/argon2"
+)
+
+func TestArgon2idSHA512Encryptor(t *testing.T) {
+	assert := assert.New(t)
+
+	e := hasher.NewArgon2idSHA512Encryptor(hasher.Argon2Params{Memory: 1024, Iterations: 1, Lanes: 1})
+	assert.Equal(domain.AlgorithmArgon2idSHA512, e.Algorithm())
+	assert.Equal("m=1024,t=1,p=1", e.Params())
+	assert.Equal(16, e.SaltLen())
+	assert.Equal(uint64(1024), e.Memory())
+
+	salt := []byte("saltsaltsaltsalt")
+	hash, err := e.Hash([]byte("angryMonkey"), salt)
+	assert.NoError(err)
+	assert.Equal(argon2.IDKey(saltedSHA512(salt, "angryMonkey"), salt, 1, 1024, 1, 32), hash)
+}
+
+type wrapConfig struct {
+	pepperConfig
+}
+
+func (c *wrapConfig) Argon2Memory() uint32 {
+	return 1024
+}
+
+// waitHash waits for the asynchronous hash calculation
+func waitHash(repo repository.HashRepository, id domain.HashID) domain.Hash {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if hash, err := repo.Load(id); err == nil {
+			return hash
+		}
+		time.Sleep(time.Millisecond)
+	}
+	panic(fmt.Sprintf("hash id=%v is not stored in time", id))
+}
+
+func TestWrap(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	secret, _ := base64.StdEncoding.DecodeString("c2VjcmV0c2VjcmV0c2VjcmV0")
+	cfg := &wrapConfig{pepperConfig{keys: []config.PepperKey{{ID: "k1", Secret: secret}}}}
+	svc := hasher.New(repo, cfg, stats.New())
+	defer svc.Stop()
+
+	passwords := map[domain.HashID]string{}
+	for _, password := range []string{"angryMonkey", "happyMonkey", "sadMonkey"} {
+		hashID, err := svc.Create(password, hasher.CreateOptions{})
+		assert.NoError(err)
+		waitHash(repo, hashID)
+		passwords[hashID] = password
+	}
+
+	// legacy hashes are not wrapped
+	legacyID, err := svc.Import("$1$saltsalt$qjXMvbEw8oaL.CzflDtaK/")
+	assert.NoError(err)
+
+	result := svc.Wrap()
+	assert.Equal(hasher.WrapResult{Wrapped: 3}, result)
+
+	for hashID, password := range passwords {
+		hash, err := repo.Load(hashID)
+		assert.NoError(err)
+		assert.Equal(domain.AlgorithmArgon2idSHA512, hash.Algorithm)
+		assert.Equal("m=1024,t=1,p=2", hash.Params)
+		assert.Equal("k1", hash.KeyID)
+
+		result, err := svc.Verify(hashID, password)
+		assert.NoError(err)
+		assert.True(result.Valid)
+
+		result, err = svc.Verify(hashID, "wrongMonkey")
+		assert.NoError(err)
+		assert.False(result.Valid)
+	}
+
+	hash, err := repo.Load(legacyID)
+	assert.NoError(err)
+	assert.Equal(domain.AlgorithmMD5Crypt, hash.Algorithm)
+
+	// nothing to wrap anymore
+	assert.Equal(hasher.WrapResult{}, svc.Wrap())
+}
+
+func TestWrapRehash(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, Config(), stats.New())
+	hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	waitHash(repo, hashID)
+	svc.Stop()
+
+	svc = hasher.New(repo, &argon2Config{}, stats.New())
+	defer svc.Stop()
+	assert.Equal(1, svc.Wrap().Wrapped)
+
+	// the wrapped hash is upgraded to plain Argon2id on the next login
+	result, err := svc.Verify(hashID, "angryMonkey")
+	assert.NoError(err)
+	assert.True(result.Valid)
+	assert.True(result.Rehashed)
+
+	hash, err := repo.Load(hashID)
+	assert.NoError(err)
+	assert.Equal(domain.AlgorithmArgon2id, hash.Algorithm)
+}
+
+func TestWrapStats(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	statsSvc := stats.New()
+	svc := hasher.NewInstrumentingService(hasher.New(repo, &wrapConfig{}, statsSvc), statsSvc)
+	defer svc.Stop()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+		assert.NoError(err)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			waitHash(repo, hashID)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(10, svc.Wrap().Wrapped)
+	assert.Equal(int64(10), statsSvc.Metric("Hasher.Wrap").Count())
+}
+
+func TestStartWrap(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, &wrapConfig{}, stats.New())
+	defer svc.Stop()
+
+	for i := 0; i < 3; i++ {
+		hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+		assert.NoError(err)
+		waitHash(repo, hashID)
+	}
+
+	assert.NoError(svc.StartWrap())
+	deadline := time.Now().Add(10 * time.Second)
+	for svc.WrapProgress().Running && time.Now().Before(deadline) {
+		// a second migration is rejected while the first one is running
+		if err := svc.StartWrap(); err != nil {
+			assert.Equal(hasher.ErrWrapRunning, err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(hasher.WrapProgress{WrapResult: hasher.WrapResult{Wrapped: 3}}, svc.WrapProgress())
+
+	// the next migration starts from scratch
+	assert.NoError(svc.StartWrap())
+	for svc.WrapProgress().Running && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(hasher.WrapProgress{}, svc.WrapProgress())
+}
+
+func TestWrapStopped(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := hasher.New(repo, &wrapConfig{}, stats.New())
+	hashID, err := svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.NoError(err)
+	waitHash(repo, hashID)
+	svc.Stop()
+
+	// the stopped pool rejects the tasks instead of dropping them
+	_, err = svc.Create("angryMonkey", hasher.CreateOptions{})
+	assert.Equal(pool.ErrStopped, err)
+	assert.Equal(hasher.WrapResult{}, svc.Wrap())
+}
@@ -1,19 +1,36 @@
 package health
 
 import (
+	"sync"
 	"sync/atomic"
 	"time"
 )
 
+// Readiness conditions, each condition is failed and recovered by its owner only
+const (
+	// ConditionProbe is owned by the synthetic prober
+	ConditionProbe = "probe"
+	// ConditionSelfTest is owned by the on-demand self-test
+	ConditionSelfTest = "selftest"
+)
+
 // Service interface declares health service methods.
 type Service interface {
 	Healthy()
 	Unhealthy()
 	IsHealthy() bool
 	Now() int64
+
+	// Fail marks the readiness condition failed, the service is unhealthy while any condition is failed
+	Fail(condition string)
+	// Recover clears the failed readiness condition
+	Recover(condition string)
 }
 type service struct {
 	healthy int32
+
+	lock   sync.Mutex
+	failed map[string]bool
 }
 
 var _ Service = &service{}
@@ -25,13 +42,25 @@ func (s *service) Unhealthy() {
 	atomic.StoreInt32(&s.healthy, 0)
 }
 func (s *service) IsHealthy() bool {
-	return atomic.LoadInt32(&s.healthy) == 1
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return atomic.LoadInt32(&s.healthy) == 1 && len(s.failed) == 0
 }
 func (s *service) Now() int64 {
 	return time.Now().Unix()
 }
+func (s *service) Fail(condition string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failed[condition] = true
+}
+func (s *service) Recover(condition string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.failed, condition)
+}
 
 // NewService creates a health service.
 func NewService() Service {
-	return &service{}
+	return &service{failed: make(map[string]bool)}
 }
@@ -38,6 +38,24 @@ func (s *HealthServiceSuit) TestNow() {
 	now := time.Now().Unix()
 	assert.GreaterOrEqual(s.svc.Now(), now)
 }
+func (s *HealthServiceSuit) TestConditions() {
+	assert := assert.New(s.T())
+	s.svc.Healthy()
+	s.svc.Fail(health.ConditionProbe)
+	s.svc.Fail(health.ConditionSelfTest)
+	assert.False(s.svc.IsHealthy())
+
+	// the probe recovery does not hide the self-test failure
+	s.svc.Recover(health.ConditionProbe)
+	assert.False(s.svc.IsHealthy())
+	s.svc.Recover(health.ConditionSelfTest)
+	assert.True(s.svc.IsHealthy())
+
+	// the conditions do not revive an unhealthy server
+	s.svc.Unhealthy()
+	s.svc.Recover(health.ConditionProbe)
+	assert.False(s.svc.IsHealthy())
+}
 func TestHealthHandlerSuit(t *testing.T) {
 	suite.Run(t, new(HealthServiceSuit))
 }
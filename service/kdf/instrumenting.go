@@ -0,0 +1,38 @@
+package kdf
+
+import (
+	"time"
+
+	"github.com/plar/hash/service/stats"
+)
+
+// Metrics of the key derivations
+const (
+	// StatsDerive tracks the derivation latency
+	StatsDerive = "KDF.Derive"
+	// StatsFailure counts the failed derivations
+	StatsFailure = "KDF.Failure"
+)
+
+type instrumentingService struct {
+	next  Service
+	stats stats.Service
+}
+
+// NewInstrumentingService creates a new instrumenting service
+func NewInstrumentingService(next Service, stats stats.Service) Service {
+	return &instrumentingService{
+		next:  next,
+		stats: stats,
+	}
+}
+
+func (s *instrumentingService) Derive(h Hash, secret, salt, info []byte, length int) (key []byte, err error) {
+	defer func(begin time.Time) {
+		s.stats.TrackMetric(StatsDerive, 1, time.Since(begin))
+		if err != nil {
+			s.stats.TrackMetric(StatsFailure, 1, time.Since(begin))
+		}
+	}(time.Now())
+	return s.next.Derive(h, secret, salt, info, length)
+}
@@ -0,0 +1,24 @@
+package kdf
+
+import (
+	"log"
+)
+
+type loggingService struct {
+	next Service
+}
+
+// NewLoggingService creates a new logging service
+func NewLoggingService(next Service) Service {
+	return &loggingService{
+		next: next,
+	}
+}
+
+func (s *loggingService) Derive(h Hash, secret, salt, info []byte, length int) (key []byte, err error) {
+	defer func() {
+		// never log the secret or the derived key
+		log.Printf("the kdf service method=Derive hash=%v length=%v => err=%v", h, length, err)
+	}()
+	return s.next.Derive(h, secret, salt, info, length)
+}
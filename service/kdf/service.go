@@ -0,0 +1,107 @@
+package kdf
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/plar/hash/server/config"
+	"golang.org/x/crypto/hkdf"
+)
+
+// ErrDisabled is returned when the key derivation is disabled
+var ErrDisabled = errors.New("Key derivation is disabled")
+
+// ErrUnknownHash is returned when the HKDF hash function is not supported
+var ErrUnknownHash = errors.New("Unknown HKDF hash")
+
+// ErrInvalidInput is returned when the secret, salt or info violate the limits
+var ErrInvalidInput = errors.New("Invalid HKDF input")
+
+// ErrInvalidLength is returned when the length of the derived key violates the limits
+var ErrInvalidLength = errors.New("Invalid HKDF key length")
+
+// Hash is the hash function of HKDF
+type Hash string
+
+// Supported hash functions
+const (
+	HashSHA256 Hash = "sha256"
+	HashSHA512 Hash = "sha512"
+)
+
+// Input limits in bytes
+const (
+	minSecretLen = 16
+	maxSecretLen = 1024
+	maxSaltLen   = 1024
+	maxInfoLen   = 1024
+)
+
+var hashes = map[Hash]func() hash.Hash{
+	HashSHA256: sha256.New,
+	HashSHA512: sha512.New,
+}
+
+// Service interface declares the key derivation service methods
+type Service interface {
+	// Derive derives a key of length bytes from the secret with HKDF (RFC 5869),
+	// the salt and info are optional and an empty hash selects SHA256
+	Derive(h Hash, secret, salt, info []byte, length int) ([]byte, error)
+}
+
+type service struct {
+	enabled   bool
+	maxLength int
+}
+
+var _ Service = &service{}
+
+// New creates a new key derivation service
+func New(cfg config.Config) Service {
+	return &service{
+		enabled:   cfg.KDFEnabled(),
+		maxLength: cfg.KDFMaxLength(),
+	}
+}
+
+func (s *service) Derive(h Hash, secret, salt, info []byte, length int) ([]byte, error) {
+	if !s.enabled {
+		return nil, ErrDisabled
+	}
+
+	if h == "" {
+		h = HashSHA256
+	}
+	newHash, ok := hashes[h]
+	if !ok {
+		return nil, fmt.Errorf("%w: '%v'", ErrUnknownHash, h)
+	}
+
+	switch {
+	case len(secret) < minSecretLen || len(secret) > maxSecretLen:
+		return nil, fmt.Errorf("%w: the secret length must be %v to %v bytes", ErrInvalidInput, minSecretLen, maxSecretLen)
+	case len(salt) > maxSaltLen:
+		return nil, fmt.Errorf("%w: the salt must be at most %v bytes", ErrInvalidInput, maxSaltLen)
+	case len(info) > maxInfoLen:
+		return nil, fmt.Errorf("%w: the info must be at most %v bytes", ErrInvalidInput, maxInfoLen)
+	}
+
+	// RFC 5869 limits the output to 255 blocks of the hash
+	maxLength := s.maxLength
+	if limit := 255 * newHash().Size(); limit < maxLength {
+		maxLength = limit
+	}
+	if length < 1 || length > maxLength {
+		return nil, fmt.Errorf("%w: the length must be 1 to %v bytes", ErrInvalidLength, maxLength)
+	}
+
+	key := make([]byte, length)
+	if _, err := io.ReadFull(hkdf.New(newHash, secret, salt, info), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,102 @@
+package kdf_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/plar/hash/server/config"
+	"github.com/plar/hash/service/kdf"
+	"github.com/stretchr/testify/assert"
+)
+
+type kdfConfig struct {
+	config.DefaultConfig
+	maxLength int
+}
+
+func (c *kdfConfig) KDFEnabled() bool {
+	return true
+}
+
+func (c *kdfConfig) KDFMaxLength() int {
+	return c.maxLength
+}
+
+func unhex(s string) []byte {
+	b, _ := hex.DecodeString(s)
+	return b
+}
+
+func TestDerive(t *testing.T) {
+	assert := assert.New(t)
+
+	svc := kdf.New(&kdfConfig{maxLength: 64})
+	secret := bytes.Repeat([]byte{0x0b}, 22)
+	salt := unhex("000102030405060708090a0b0c")
+	info := unhex("f0f1f2f3f4f5f6f7f8f9")
+
+	tests := []struct {
+		hash kdf.Hash
+		salt []byte
+		info []byte
+		okm  string
+	}{
+		// RFC 5869 A.1
+		{kdf.HashSHA256, salt, info, "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865"},
+		// RFC 5869 A.3, the default hash
+		{"", nil, nil, "8da4e775a563c18f715f802a063c5a31b8a11f5c5ee1879ec3454e5f3c738d2d9d201395faa4b61a96c8"},
+		{kdf.HashSHA512, salt, info, "832390086cda71fb47625bb5ceb168e4c8e26a1a16ed34d9fc7fe92c1481579338da362cb8d9f925d7cb"},
+	}
+	for _, tt := range tests {
+		key, err := svc.Derive(tt.hash, secret, tt.salt, tt.info, 42)
+		assert.NoError(err, tt.hash)
+		assert.Equal(tt.okm, hex.EncodeToString(key), tt.hash)
+	}
+}
+
+func TestDeriveLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	svc := kdf.New(&kdfConfig{maxLength: 8160})
+	secret := make([]byte, 16)
+
+	_, err := svc.Derive("md5", secret, nil, nil, 32)
+	assert.True(errors.Is(err, kdf.ErrUnknownHash))
+
+	for _, tt := range []struct{ secret, salt, info []byte }{
+		{secret[:15], nil, nil},
+		{make([]byte, 1025), nil, nil},
+		{secret, make([]byte, 1025), nil},
+		{secret, nil, make([]byte, 1025)},
+	} {
+		_, err := svc.Derive(kdf.HashSHA256, tt.secret, tt.salt, tt.info, 32)
+		assert.True(errors.Is(err, kdf.ErrInvalidInput))
+	}
+
+	// RFC 5869 limits the output to 255 blocks of the hash
+	key, err := svc.Derive(kdf.HashSHA256, secret, nil, nil, 255*32)
+	assert.NoError(err)
+	assert.Len(key, 255*32)
+	for _, length := range []int{-1, 0, 255*32 + 1} {
+		_, err := svc.Derive(kdf.HashSHA256, secret, nil, nil, length)
+		assert.True(errors.Is(err, kdf.ErrInvalidLength), length)
+	}
+
+	// the configured limit is lower than the HKDF-SHA512 limit
+	key, err = svc.Derive(kdf.HashSHA512, secret, nil, nil, 8160)
+	assert.NoError(err)
+	assert.Len(key, 8160)
+	_, err = svc.Derive(kdf.HashSHA512, secret, nil, nil, 8161)
+	assert.True(errors.Is(err, kdf.ErrInvalidLength))
+}
+
+func TestDeriveDisabled(t *testing.T) {
+	assert := assert.New(t)
+
+	svc := kdf.New(&config.DefaultConfig{})
+	key, err := svc.Derive(kdf.HashSHA256, make([]byte, 16), nil, nil, 32)
+	assert.True(errors.Is(err, kdf.ErrDisabled))
+	assert.Nil(key)
+}
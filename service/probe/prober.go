@@ -0,0 +1,103 @@
+package probe
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/plar/hash/server/config"
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/health"
+	"github.com/plar/hash/service/stats"
+)
+
+// StatsConsecutiveFailures is the gauge of the consecutive probe failures
+const StatsConsecutiveFailures = "Probe.ConsecutiveFailures"
+
+// Prober periodically runs the synthetic end-to-end probe of the hasher service.
+// Consecutive failures mark the service unhealthy until a probe succeeds again.
+type Prober struct {
+	hasherSvc hasher.Service
+	statsSvc  stats.Service
+	healthSvc health.Service
+
+	interval  time.Duration
+	timeout   time.Duration
+	threshold int
+
+	// failures is the number of consecutive failures,
+	// tripped is set if the prober has marked the service unhealthy
+	lock     sync.Mutex
+	failures int
+	tripped  bool
+
+	quit     chan struct{}
+	stopOnce sync.Once
+}
+
+// New creates a new prober, the hasher service should be instrumented to track the probe latency and failures
+func New(cfg config.Config, hasherSvc hasher.Service, statsSvc stats.Service, healthSvc health.Service) *Prober {
+	return &Prober{
+		hasherSvc: hasherSvc,
+		statsSvc:  statsSvc,
+		healthSvc: healthSvc,
+		interval:  cfg.ProbeInterval(),
+		timeout:   cfg.ProbeTimeout(),
+		threshold: cfg.ProbeFailures(),
+		quit:      make(chan struct{}),
+	}
+}
+
+// Start runs the probe loop in background, the prober is disabled for the zero interval
+func (p *Prober) Start() {
+	if p.interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.Probe()
+			case <-p.quit:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the probe loop, a running probe is not awaited
+func (p *Prober) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.quit)
+	})
+}
+
+// Probe runs a single probe and updates the service health
+func (p *Prober) Probe() error {
+	err := p.hasherSvc.Probe(p.timeout)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	// the prober owns the probe condition only, a failed self-test or the server shutdown stay unhealthy
+	if err != nil {
+		p.failures++
+		if p.failures >= p.threshold && !p.tripped {
+			log.Printf("the prober marks the service unhealthy after %v consecutive failures: %v", p.failures, err)
+			p.tripped = true
+			p.healthSvc.Fail(health.ConditionProbe)
+		}
+	} else {
+		p.failures = 0
+		if p.tripped {
+			log.Println("the prober clears the probe failure")
+			p.tripped = false
+			p.healthSvc.Recover(health.ConditionProbe)
+		}
+	}
+	p.statsSvc.SetGauge(StatsConsecutiveFailures, int64(p.failures))
+	return err
+}
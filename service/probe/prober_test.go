@@ -0,0 +1,124 @@
+package probe_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/plar/hash/server/config"
+	"github.com/plar/hash/service/hasher"
+	"github.com/plar/hash/service/health"
+	"github.com/plar/hash/service/probe"
+	"github.com/plar/hash/service/stats"
+	"github.com/stretchr/testify/assert"
+)
+
+type probeConfig struct {
+	config.DefaultConfig
+	interval time.Duration
+}
+
+func (c *probeConfig) ProbeInterval() time.Duration {
+	return c.interval
+}
+
+// fakeHasher fails the probe while failing is set
+type fakeHasher struct {
+	hasher.Service
+	failing int32
+	probes  int32
+}
+
+func (s *fakeHasher) Probe(timeout time.Duration) error {
+	atomic.AddInt32(&s.probes, 1)
+	if atomic.LoadInt32(&s.failing) != 0 {
+		return hasher.ErrProbe
+	}
+	return nil
+}
+
+func TestProber(t *testing.T) {
+	assert := assert.New(t)
+
+	hasherSvc := &fakeHasher{}
+	statsSvc := stats.New()
+	healthSvc := health.NewService()
+	healthSvc.Healthy()
+
+	p := probe.New(&probeConfig{}, hasherSvc, statsSvc, healthSvc)
+	assert.NoError(p.Probe())
+
+	// the default threshold is 3 consecutive failures
+	atomic.StoreInt32(&hasherSvc.failing, 1)
+	for i := 1; i <= 2; i++ {
+		assert.True(errors.Is(p.Probe(), hasher.ErrProbe))
+		assert.True(healthSvc.IsHealthy())
+		assert.Equal(int64(i), statsSvc.Gauge(probe.StatsConsecutiveFailures))
+	}
+	assert.Error(p.Probe())
+	assert.False(healthSvc.IsHealthy())
+
+	// recovered
+	atomic.StoreInt32(&hasherSvc.failing, 0)
+	assert.NoError(p.Probe())
+	assert.True(healthSvc.IsHealthy())
+	assert.Equal(int64(0), statsSvc.Gauge(probe.StatsConsecutiveFailures))
+}
+
+func TestProberKeepsSelfTestFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	hasherSvc := &fakeHasher{}
+	healthSvc := health.NewService()
+	healthSvc.Healthy()
+	healthSvc.Fail(health.ConditionSelfTest)
+
+	p := probe.New(&probeConfig{}, hasherSvc, stats.New(), healthSvc)
+	atomic.StoreInt32(&hasherSvc.failing, 1)
+	for i := 0; i < 3; i++ {
+		p.Probe()
+	}
+
+	// the probe success clears its own failure only
+	atomic.StoreInt32(&hasherSvc.failing, 0)
+	assert.NoError(p.Probe())
+	assert.False(healthSvc.IsHealthy())
+
+	healthSvc.Recover(health.ConditionSelfTest)
+	assert.True(healthSvc.IsHealthy())
+}
+
+func TestProberDoesNotReviveUnhealthy(t *testing.T) {
+	assert := assert.New(t)
+
+	// the server is shutting down, the prober has not tripped
+	healthSvc := health.NewService()
+	p := probe.New(&probeConfig{}, &fakeHasher{}, stats.New(), healthSvc)
+	assert.NoError(p.Probe())
+	assert.False(healthSvc.IsHealthy())
+}
+
+func TestProberLoop(t *testing.T) {
+	assert := assert.New(t)
+
+	hasherSvc := &fakeHasher{}
+	p := probe.New(&probeConfig{interval: 10 * time.Millisecond}, hasherSvc, stats.New(), health.NewService())
+	p.Start()
+	time.Sleep(55 * time.Millisecond)
+	p.Stop()
+	p.Stop()
+
+	probes := atomic.LoadInt32(&hasherSvc.probes)
+	assert.GreaterOrEqual(probes, int32(3))
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(probes, atomic.LoadInt32(&hasherSvc.probes))
+
+	// the zero interval disables the prober
+	hasherSvc = &fakeHasher{}
+	p = probe.New(&probeConfig{}, hasherSvc, stats.New(), health.NewService())
+	p.Start()
+	time.Sleep(20 * time.Millisecond)
+	p.Stop()
+	assert.Equal(int32(0), atomic.LoadInt32(&hasherSvc.probes))
+}
@@ -0,0 +1,61 @@
+package srp
+
+import (
+	"time"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/service/stats"
+)
+
+// Metrics of the SRP logins
+const (
+	// StatsAuthenticate tracks the login latency
+	StatsAuthenticate = "SRP.Authenticate"
+	// StatsAuthenticationFailure counts the failed logins
+	StatsAuthenticationFailure = "SRP.AuthenticationFailure"
+	// StatsHandshakes is the gauge of the pending handshakes
+	StatsHandshakes = "SRP.Handshakes"
+)
+
+type instrumentingService struct {
+	next  Service
+	stats stats.Service
+}
+
+// NewInstrumentingService creates a new instrumenting service
+func NewInstrumentingService(next Service, stats stats.Service) Service {
+	return &instrumentingService{
+		next:  next,
+		stats: stats,
+	}
+}
+
+func (s *instrumentingService) Register(salt, verifier []byte, group int) (domain.HashID, error) {
+	defer func(begin time.Time) {
+		s.stats.TrackMetric("SRP.Register", 1, time.Since(begin))
+	}(time.Now())
+	return s.next.Register(salt, verifier, group)
+}
+
+func (s *instrumentingService) Challenge(id domain.HashID, publicKey []byte) (Challenge, error) {
+	defer func(begin time.Time) {
+		s.stats.TrackMetric("SRP.Challenge", 1, time.Since(begin))
+		s.stats.SetGauge(StatsHandshakes, int64(s.next.Handshakes()))
+	}(time.Now())
+	return s.next.Challenge(id, publicKey)
+}
+
+func (s *instrumentingService) Authenticate(session string, proof []byte) (id domain.HashID, serverProof []byte, err error) {
+	defer func(begin time.Time) {
+		s.stats.TrackMetric(StatsAuthenticate, 1, time.Since(begin))
+		if err != nil {
+			s.stats.TrackMetric(StatsAuthenticationFailure, 1, time.Since(begin))
+		}
+		s.stats.SetGauge(StatsHandshakes, int64(s.next.Handshakes()))
+	}(time.Now())
+	return s.next.Authenticate(session, proof)
+}
+
+func (s *instrumentingService) Handshakes() int {
+	return s.next.Handshakes()
+}
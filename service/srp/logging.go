@@ -0,0 +1,43 @@
+package srp
+
+import (
+	"log"
+
+	"github.com/plar/hash/domain"
+)
+
+type loggingService struct {
+	next Service
+}
+
+// NewLoggingService creates a new logging service
+func NewLoggingService(next Service) Service {
+	return &loggingService{
+		next: next,
+	}
+}
+
+func (s *loggingService) Register(salt, verifier []byte, group int) (id domain.HashID, err error) {
+	defer func() {
+		log.Printf("the srp service method=Register group=%v => id=%v, err=%v", group, id, err)
+	}()
+	return s.next.Register(salt, verifier, group)
+}
+
+func (s *loggingService) Challenge(id domain.HashID, publicKey []byte) (challenge Challenge, err error) {
+	defer func() {
+		log.Printf("the srp service method=Challenge id=%v => group=%v, err=%v", id, challenge.Group, err)
+	}()
+	return s.next.Challenge(id, publicKey)
+}
+
+func (s *loggingService) Authenticate(session string, proof []byte) (id domain.HashID, serverProof []byte, err error) {
+	defer func() {
+		log.Printf("the srp service method=Authenticate => id=%v, err=%v", id, err)
+	}()
+	return s.next.Authenticate(session, proof)
+}
+
+func (s *loggingService) Handshakes() int {
+	return s.next.Handshakes()
+}
@@ -0,0 +1,260 @@
+package srp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/domain/repository"
+	"github.com/plar/hash/server/config"
+)
+
+// ErrUnknownGroup is returned when the SRP group is not supported
+var ErrUnknownGroup = errors.New("Unknown SRP group")
+
+// ErrInvalidVerifier is returned when the salt or verifier of a registration is invalid
+var ErrInvalidVerifier = errors.New("Invalid SRP verifier")
+
+// ErrInvalidPublicKey is returned when the client public key is not a valid group element
+var ErrInvalidPublicKey = errors.New("Invalid SRP public key")
+
+// ErrHandshakeNotFound is returned when the handshake is unknown, expired or already completed
+var ErrHandshakeNotFound = errors.New("SRP handshake is not found or expired")
+
+// ErrAuthentication is returned when the client proof is invalid
+var ErrAuthentication = errors.New("SRP authentication failed")
+
+// Salt length limits of SRP verifiers in bytes
+const (
+	minSaltLen = 16
+	maxSaltLen = 64
+)
+
+// Service interface declares the SRP service methods
+type Service interface {
+	// Register stores the salt and verifier v = g^x of a client, the group is the size of an RFC 5054 group,
+	// zero selects the default group
+	Register(salt, verifier []byte, group int) (domain.HashID, error)
+
+	// Challenge starts a login handshake with the client public key A of the verifier id,
+	// the handshake must be completed by Authenticate before it expires
+	Challenge(id domain.HashID, publicKey []byte) (Challenge, error)
+
+	// Authenticate completes the login handshake with the client proof M1 and returns the server proof M2,
+	// a handshake can be completed only once
+	Authenticate(session string, proof []byte) (domain.HashID, []byte, error)
+
+	// Handshakes returns the number of pending handshakes
+	Handshakes() int
+}
+
+// Challenge is the server response of the first login step
+type Challenge struct {
+	// Session identifies the handshake
+	Session string
+	// Group is the size of the RFC 5054 group of the verifier
+	Group int
+	Salt  []byte
+	// PublicKey is the server public key B
+	PublicKey []byte
+}
+
+// handshake is a pending login handshake
+type handshake struct {
+	id      domain.HashID
+	session session
+	expires time.Time
+}
+
+type service struct {
+	hashRepo repository.HashRepository
+	group    *Group
+	ttl      time.Duration
+	max      int
+
+	// secret derives the salts and verifiers of the unknown hashes,
+	// so callers cannot probe which verifiers exist
+	secret []byte
+
+	lock       sync.Mutex
+	handshakes map[string]handshake
+	// order is the session IDs in the order of creation, i.e. of expiration,
+	// it may contain the completed handshakes
+	order []string
+	now   func() time.Time
+}
+
+var _ Service = &service{}
+
+// New creates a new SRP service
+func New(cfg config.Config, hashRepo repository.HashRepository) Service {
+	secret := make([]byte, sha256.Size)
+	if _, err := rand.Read(secret); err != nil {
+		panic(fmt.Sprintf("srp: cannot generate the secret: %v", err))
+	}
+
+	return &service{
+		hashRepo:   hashRepo,
+		group:      GroupByBits(cfg.SRPGroup()),
+		ttl:        cfg.SRPHandshakeTTL(),
+		max:        cfg.SRPMaxHandshakes(),
+		secret:     secret,
+		handshakes: make(map[string]handshake),
+		now:        time.Now,
+	}
+}
+
+func (s *service) Register(salt, verifier []byte, group int) (domain.HashID, error) {
+	g := s.group
+	if group != 0 {
+		if g = GroupByBits(group); g == nil {
+			return 0, fmt.Errorf("%w: '%v'", ErrUnknownGroup, group)
+		}
+	}
+
+	if len(salt) < minSaltLen || len(salt) > maxSaltLen {
+		return 0, fmt.Errorf("%w: the salt length must be %v to %v bytes", ErrInvalidVerifier, minSaltLen, maxSaltLen)
+	}
+	v := new(big.Int).SetBytes(verifier)
+	if !g.isValidElement(v) || v.Cmp(big.NewInt(1)) == 0 {
+		return 0, fmt.Errorf("%w: the verifier is not an element of the %v-bit group", ErrInvalidVerifier, g.Bits)
+	}
+
+	hash := domain.Hash{
+		ID:        s.hashRepo.NewID(),
+		Algorithm: domain.AlgorithmSRP6a,
+		Params:    fmt.Sprintf("n=%d", g.Bits),
+		Salt:      append([]byte(nil), salt...),
+		Hash:      g.pad(v),
+	}
+	s.hashRepo.Save(hash)
+	return hash.ID, nil
+}
+
+func (s *service) Challenge(id domain.HashID, publicKey []byte) (Challenge, error) {
+	g, salt, v, err := s.loadVerifier(id)
+	if err != nil {
+		return Challenge{}, err
+	}
+
+	if len(publicKey) > g.size() {
+		return Challenge{}, ErrInvalidPublicKey
+	}
+	sess, ok, err := g.newSession(v, new(big.Int).SetBytes(publicKey))
+	if err != nil {
+		return Challenge{}, err
+	}
+	if !ok {
+		return Challenge{}, ErrInvalidPublicKey
+	}
+
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return Challenge{}, err
+	}
+	sessionID := base64.RawURLEncoding.EncodeToString(token)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// the oldest handshakes are evicted, so a flood of challenges cannot block the other logins
+	now := s.now()
+	s.expire(now)
+	for len(s.handshakes) >= s.max {
+		s.evictOldest()
+	}
+	s.handshakes[sessionID] = handshake{id: id, session: sess, expires: now.Add(s.ttl)}
+	s.order = append(s.order, sessionID)
+
+	return Challenge{
+		Session:   sessionID,
+		Group:     g.Bits,
+		Salt:      salt,
+		PublicKey: sess.publicKey,
+	}, nil
+}
+
+func (s *service) Authenticate(sessionID string, proof []byte) (domain.HashID, []byte, error) {
+	s.lock.Lock()
+	h, ok := s.handshakes[sessionID]
+	// a handshake allows a single guess
+	delete(s.handshakes, sessionID)
+	s.lock.Unlock()
+
+	if !ok || !s.now().Before(h.expires) {
+		return 0, nil, ErrHandshakeNotFound
+	}
+	if !h.session.verify(proof) {
+		return h.id, nil, ErrAuthentication
+	}
+	return h.id, h.session.serverProof, nil
+}
+
+func (s *service) Handshakes() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.handshakes)
+}
+
+// expire deletes the expired handshakes, the lock must be held
+func (s *service) expire(now time.Time) {
+	for len(s.order) > 0 {
+		h, ok := s.handshakes[s.order[0]]
+		if ok && now.Before(h.expires) {
+			return
+		}
+		delete(s.handshakes, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+// evictOldest deletes the oldest pending handshake, the lock must be held
+func (s *service) evictOldest() {
+	for len(s.order) > 0 {
+		sessionID := s.order[0]
+		s.order = s.order[1:]
+		if _, ok := s.handshakes[sessionID]; ok {
+			delete(s.handshakes, sessionID)
+			return
+		}
+	}
+}
+
+// loadVerifier loads the group, salt and verifier of the hash id.
+// A missing verifier or a hash of another algorithm is replaced with a fake verifier derived from the secret,
+// the handshake looks the same but can never be completed.
+func (s *service) loadVerifier(id domain.HashID) (*Group, []byte, *big.Int, error) {
+	hash, err := s.hashRepo.Load(id)
+	if errors.Is(err, repository.ErrHashNotFound) || (err == nil && (hash.Probe || !hash.Algorithm.IsSRP())) {
+		return s.group, s.derive("salt", id)[:minSaltLen], s.fakeVerifier(id), nil
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var bits int
+	if _, err := fmt.Sscanf(hash.Params, "n=%d", &bits); err != nil || GroupByBits(bits) == nil {
+		return nil, nil, nil, fmt.Errorf("%w: '%v'", ErrUnknownGroup, hash.Params)
+	}
+	return GroupByBits(bits), hash.Salt, new(big.Int).SetBytes(hash.Hash), nil
+}
+
+// fakeVerifier returns the verifier of an unknown password of the hash id
+func (s *service) fakeVerifier(id domain.HashID) *big.Int {
+	return new(big.Int).Exp(s.group.G, new(big.Int).SetBytes(s.derive("verifier", id)), s.group.N)
+}
+
+// derive returns HMAC-SHA256 of the label and the hash id keyed with the secret
+func (s *service) derive(label string, id domain.HashID) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(label))
+	mac.Write(id.Bytes())
+	return mac.Sum(nil)
+}
@@ -0,0 +1,269 @@
+package srp
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/plar/hash/domain"
+	"github.com/plar/hash/infra/persistence/memory"
+	"github.com/plar/hash/server/config"
+	"github.com/stretchr/testify/assert"
+)
+
+type srpConfig struct {
+	config.DefaultConfig
+	max int
+}
+
+func (c *srpConfig) SRPMaxHandshakes() int {
+	return c.max
+}
+
+// client is the client side of an SRP-6a exchange
+type client struct {
+	group    *Group
+	password []byte
+	a        *big.Int
+}
+
+func newClient(group *Group, password string) *client {
+	a, _ := group.randomExponent()
+	return &client{group: group, password: []byte(password), a: a}
+}
+
+func (c *client) publicKey() []byte {
+	return c.group.pad(new(big.Int).Exp(c.group.G, c.a, c.group.N))
+}
+
+// proofs returns the client proof M1 and the expected server proof M2
+func (c *client) proofs(salt, serverPublicKey []byte) ([]byte, []byte) {
+	g := c.group
+	A := new(big.Int).SetBytes(c.publicKey())
+	B := new(big.Int).SetBytes(serverPublicKey)
+	u := new(big.Int).SetBytes(hash(g.pad(A), g.pad(B)))
+	x := privateKey(salt, c.password)
+
+	// S = (B - k*g^x)^(a + u*x)
+	base := new(big.Int).Mul(g.multiplier(), new(big.Int).Exp(g.G, x, g.N))
+	base.Sub(B, base)
+	base.Mod(base, g.N)
+	exp := new(big.Int).Mul(u, x)
+	exp.Add(exp, c.a)
+	S := new(big.Int).Exp(base, exp, g.N)
+
+	K := hash(g.pad(S))
+	M1 := hash(g.pad(A), g.pad(B), K)
+	return M1, hash(g.pad(A), M1, K)
+}
+
+func register(svc Service, group *Group, password string) domain.HashID {
+	salt := []byte("0123456789abcdef")
+	id, _ := svc.Register(salt, group.verifier(salt, []byte(password)).Bytes(), group.Bits)
+	return id
+}
+
+func TestGroups(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, bits := range []int{2048, 3072, 4096} {
+		g := GroupByBits(bits)
+		assert.Equal(bits, g.N.BitLen())
+		// N is a safe prime
+		assert.True(g.N.ProbablyPrime(1), bits)
+		assert.True(new(big.Int).Rsh(g.N, 1).ProbablyPrime(1), bits)
+	}
+	assert.Nil(GroupByBits(1024))
+}
+
+func TestLogin(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := New(&srpConfig{max: 10}, repo)
+
+	for _, group := range []*Group{GroupByBits(2048), GroupByBits(3072)} {
+		id := register(svc, group, "pencil")
+		hash, err := repo.Load(id)
+		assert.NoError(err)
+		assert.Equal(domain.AlgorithmSRP6a, hash.Algorithm)
+		assert.Len(hash.Hash, group.size())
+
+		c := newClient(group, "pencil")
+		challenge, err := svc.Challenge(id, c.publicKey())
+		assert.NoError(err)
+		assert.Equal(group.Bits, challenge.Group)
+		assert.Equal([]byte("0123456789abcdef"), challenge.Salt)
+		assert.Equal(1, svc.Handshakes())
+
+		clientProof, serverProof := c.proofs(challenge.Salt, challenge.PublicKey)
+		authID, proof, err := svc.Authenticate(challenge.Session, clientProof)
+		assert.NoError(err)
+		assert.Equal(id, authID)
+		assert.Equal(serverProof, proof)
+
+		// a handshake can be completed only once
+		_, _, err = svc.Authenticate(challenge.Session, clientProof)
+		assert.True(errors.Is(err, ErrHandshakeNotFound))
+		assert.Equal(0, svc.Handshakes())
+	}
+}
+
+func TestLoginWrongPassword(t *testing.T) {
+	assert := assert.New(t)
+
+	svc := New(&srpConfig{max: 10}, memory.NewHashRepository())
+	group := GroupByBits(2048)
+	id := register(svc, group, "pencil")
+
+	c := newClient(group, "pencil!")
+	challenge, err := svc.Challenge(id, c.publicKey())
+	assert.NoError(err)
+
+	clientProof, _ := c.proofs(challenge.Salt, challenge.PublicKey)
+	authID, proof, err := svc.Authenticate(challenge.Session, clientProof)
+	assert.True(errors.Is(err, ErrAuthentication))
+	assert.Equal(id, authID)
+	assert.Nil(proof)
+}
+
+func TestLoginUnknownVerifier(t *testing.T) {
+	assert := assert.New(t)
+
+	svc := New(&srpConfig{max: 10}, memory.NewHashRepository())
+	c := newClient(GroupByBits(2048), "pencil")
+
+	// the fake challenge looks like a real one
+	challenge1, err := svc.Challenge(42, c.publicKey())
+	assert.NoError(err)
+	challenge2, err := svc.Challenge(42, c.publicKey())
+	assert.NoError(err)
+	assert.Equal(2048, challenge1.Group)
+	assert.Len(challenge1.Salt, minSaltLen)
+	assert.Equal(challenge1.Salt, challenge2.Salt)
+
+	clientProof, _ := c.proofs(challenge1.Salt, challenge1.PublicKey)
+	_, _, err = svc.Authenticate(challenge1.Session, clientProof)
+	assert.True(errors.Is(err, ErrAuthentication))
+}
+
+func TestChallengeErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	repo := memory.NewHashRepository()
+	svc := New(&srpConfig{max: 10}, repo)
+	group := GroupByBits(2048)
+	id := register(svc, group, "pencil")
+
+	for _, publicKey := range [][]byte{nil, {0}, group.N.Bytes(), append([]byte{1}, group.pad(big.NewInt(2))...)} {
+		_, err := svc.Challenge(id, publicKey)
+		assert.True(errors.Is(err, ErrInvalidPublicKey))
+	}
+
+	// a hash of another algorithm looks like a missing verifier
+	repo.Save(domain.Hash{ID: 100, Algorithm: domain.AlgorithmSHA512, Hash: []byte{1}})
+	c := newClient(group, "pencil")
+	challenge, err := svc.Challenge(100, c.publicKey())
+	assert.NoError(err)
+	missing, err := svc.Challenge(101, c.publicKey())
+	assert.NoError(err)
+	assert.Equal(missing.Group, challenge.Group)
+	assert.Len(challenge.Salt, len(missing.Salt))
+	assert.Len(challenge.PublicKey, len(missing.PublicKey))
+
+	again, err := svc.Challenge(100, c.publicKey())
+	assert.NoError(err)
+	assert.Equal(challenge.Salt, again.Salt)
+
+	clientProof, _ := c.proofs(challenge.Salt, challenge.PublicKey)
+	_, _, err = svc.Authenticate(challenge.Session, clientProof)
+	assert.True(errors.Is(err, ErrAuthentication))
+}
+
+func TestRegisterErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	svc := New(&srpConfig{max: 10}, memory.NewHashRepository())
+	salt := []byte("0123456789abcdef")
+	N := GroupByBits(2048).N
+
+	_, err := svc.Register(salt, []byte{2}, 1024)
+	assert.True(errors.Is(err, ErrUnknownGroup))
+
+	tests := []struct {
+		salt     []byte
+		verifier []byte
+	}{
+		{salt[:15], []byte{2}},
+		{make([]byte, 65), []byte{2}},
+		{salt, nil},
+		{salt, []byte{1}},
+		{salt, N.Bytes()},
+	}
+	for _, tt := range tests {
+		_, err := svc.Register(tt.salt, tt.verifier, 0)
+		assert.True(errors.Is(err, ErrInvalidVerifier))
+	}
+}
+
+func TestHandshakeLimits(t *testing.T) {
+	assert := assert.New(t)
+
+	svc := New(&srpConfig{max: 2}, memory.NewHashRepository()).(*service)
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+
+	group := GroupByBits(2048)
+	id := register(svc, group, "pencil")
+	c := newClient(group, "pencil")
+
+	evicted, err := svc.Challenge(id, c.publicKey())
+	assert.NoError(err)
+	expired, err := svc.Challenge(id, c.publicKey())
+	assert.NoError(err)
+
+	// the pending handshakes are bounded, the oldest one is evicted
+	now = now.Add(time.Second)
+	pending, err := svc.Challenge(id, c.publicKey())
+	assert.NoError(err)
+	assert.Equal(2, svc.Handshakes())
+	clientProof, _ := c.proofs(evicted.Salt, evicted.PublicKey)
+	_, _, err = svc.Authenticate(evicted.Session, clientProof)
+	assert.True(errors.Is(err, ErrHandshakeNotFound))
+
+	// the expired handshakes are deleted first
+	now = now.Add(svc.ttl - time.Second)
+	_, err = svc.Challenge(id, c.publicKey())
+	assert.NoError(err)
+	assert.Equal(2, svc.Handshakes())
+	clientProof, _ = c.proofs(expired.Salt, expired.PublicKey)
+	_, _, err = svc.Authenticate(expired.Session, clientProof)
+	assert.True(errors.Is(err, ErrHandshakeNotFound))
+
+	clientProof, _ = c.proofs(pending.Salt, pending.PublicKey)
+	_, _, err = svc.Authenticate(pending.Session, clientProof)
+	assert.NoError(err)
+	assert.Equal(1, svc.Handshakes())
+}
+
+func TestHandshakeExpired(t *testing.T) {
+	assert := assert.New(t)
+
+	svc := New(&srpConfig{max: 10}, memory.NewHashRepository()).(*service)
+	now := time.Now()
+	svc.now = func() time.Time { return now }
+
+	group := GroupByBits(2048)
+	id := register(svc, group, "pencil")
+	c := newClient(group, "pencil")
+
+	challenge, err := svc.Challenge(id, c.publicKey())
+	assert.NoError(err)
+
+	now = now.Add(svc.ttl)
+	clientProof, _ := c.proofs(challenge.Salt, challenge.PublicKey)
+	_, _, err = svc.Authenticate(challenge.Session, clientProof)
+	assert.True(errors.Is(err, ErrHandshakeNotFound))
+	assert.Equal(0, svc.Handshakes())
+}
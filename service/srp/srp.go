@@ -0,0 +1,113 @@
+package srp
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"math/big"
+)
+
+// The SRP-6a computations, H is SHA256 and PAD pads a number to the size of the prime N:
+//
+//	k  = H(N | PAD(g))
+//	x  = H(s | H(I | ":" | P)), the identity I is empty, the HashID identifies the verifier
+//	v  = g^x
+//	B  = k*v + g^b
+//	u  = H(PAD(A) | PAD(B))
+//	S  = (A * v^u)^b
+//	K  = H(PAD(S))
+//	M1 = H(PAD(A) | PAD(B) | K)
+//	M2 = H(PAD(A) | M1 | K)
+
+// hash returns SHA256 of the concatenated parts
+func hash(parts ...[]byte) []byte {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+// multiplier returns the SRP-6a multiplier k
+func (g *Group) multiplier() *big.Int {
+	return new(big.Int).SetBytes(hash(g.N.Bytes(), g.pad(g.G)))
+}
+
+// privateKey returns the client private key x of the salt and password
+func privateKey(salt, password []byte) *big.Int {
+	return new(big.Int).SetBytes(hash(salt, hash([]byte(":"), password)))
+}
+
+// verifier returns the verifier v of the salt and password
+func (g *Group) verifier(salt, password []byte) *big.Int {
+	return new(big.Int).Exp(g.G, privateKey(salt, password), g.N)
+}
+
+// isValidElement reports whether x is a non-zero element of the group
+func (g *Group) isValidElement(x *big.Int) bool {
+	return x.Sign() > 0 && x.Cmp(g.N) < 0
+}
+
+// randomExponent returns a random secret exponent in [1, N)
+func (g *Group) randomExponent() (*big.Int, error) {
+	for {
+		x, err := rand.Int(rand.Reader, g.N)
+		if err != nil {
+			return nil, err
+		}
+		if x.Sign() > 0 {
+			return x, nil
+		}
+	}
+}
+
+// session is the server side of an SRP-6a exchange
+type session struct {
+	// publicKey is the server public key B
+	publicKey []byte
+	// clientProof is the expected client proof M1
+	clientProof []byte
+	// serverProof is the server proof M2
+	serverProof []byte
+}
+
+// newSession computes the server public key and the proofs of the verifier v and the client public key A.
+// ok is false if A is not a valid public key.
+func (g *Group) newSession(v, A *big.Int) (s session, ok bool, err error) {
+	if !g.isValidElement(A) {
+		return session{}, false, nil
+	}
+
+	b, err := g.randomExponent()
+	if err != nil {
+		return session{}, false, err
+	}
+
+	// B = k*v + g^b
+	B := new(big.Int).Mul(g.multiplier(), v)
+	B.Add(B, new(big.Int).Exp(g.G, b, g.N))
+	B.Mod(B, g.N)
+
+	u := new(big.Int).SetBytes(hash(g.pad(A), g.pad(B)))
+	if u.Sign() == 0 {
+		return session{}, false, nil
+	}
+
+	// S = (A * v^u)^b
+	S := new(big.Int).Exp(v, u, g.N)
+	S.Mul(S, A)
+	S.Exp(S, b, g.N)
+
+	K := hash(g.pad(S))
+	M1 := hash(g.pad(A), g.pad(B), K)
+	return session{
+		publicKey:   g.pad(B),
+		clientProof: M1,
+		serverProof: hash(g.pad(A), M1, K),
+	}, true, nil
+}
+
+// verify reports whether the client proof M1 is valid
+func (s session) verify(clientProof []byte) bool {
+	return subtle.ConstantTimeCompare(s.clientProof, clientProof) == 1
+}
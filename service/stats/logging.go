@@ -32,3 +32,22 @@ func (s *loggingService) Metric(name string) (m domain.Metric) {
 	}()
 	return s.next.Metric(name)
 }
+
+func (s *loggingService) Metrics(prefix string) (metrics map[string]domain.Metric) {
+	defer func() {
+		log.Printf("the stats service method=Metrics prefix=%v => %v", prefix, metrics)
+	}()
+	return s.next.Metrics(prefix)
+}
+
+// SetGauge is not logged, the gauges are updated by every hash job
+func (s *loggingService) SetGauge(name string, value int64) {
+	s.next.SetGauge(name, value)
+}
+
+func (s *loggingService) Gauge(name string) (v int64) {
+	defer func() {
+		log.Printf("the stats service method=Gauge name=%v => %v", name, v)
+	}()
+	return s.next.Gauge(name)
+}
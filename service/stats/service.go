@@ -1,6 +1,7 @@
 package stats
 
 import (
+	"strings"
 	"sync"
 	"time"
 
@@ -11,6 +12,12 @@ import (
 type Service interface {
 	TrackMetric(name string, count int64, execTime time.Duration)
 	Metric(name string) domain.Metric
+	// Metrics returns all metrics with the name prefix, the names are returned without the prefix
+	Metrics(prefix string) map[string]domain.Metric
+
+	// SetGauge sets the current value of a gauge, e.g. the reserved memory
+	SetGauge(name string, value int64)
+	Gauge(name string) int64
 }
 
 var _ Service = &service{}
@@ -18,11 +25,13 @@ var _ Service = &service{}
 type service struct {
 	lock    sync.RWMutex
 	metrics map[string]domain.Metric
+	gauges  map[string]int64
 }
 
 func New() Service {
 	return &service{
 		metrics: make(map[string]domain.Metric, 0),
+		gauges:  make(map[string]int64, 0),
 	}
 }
 
@@ -45,3 +54,34 @@ func (s *service) Metric(name string) domain.Metric {
 	s.lock.RUnlock()
 	return m
 }
+
+func (s *service) Metrics(prefix string) map[string]domain.Metric {
+	s.lock.RLock()
+
+	metrics := make(map[string]domain.Metric)
+	for name, m := range s.metrics {
+		if strings.HasPrefix(name, prefix) {
+			metrics[strings.TrimPrefix(name, prefix)] = m
+		}
+	}
+
+	s.lock.RUnlock()
+	return metrics
+}
+
+func (s *service) SetGauge(name string, value int64) {
+	s.lock.Lock()
+
+	s.gauges[name] = value
+
+	s.lock.Unlock()
+}
+
+func (s *service) Gauge(name string) int64 {
+	s.lock.RLock()
+
+	v := s.gauges[name]
+
+	s.lock.RUnlock()
+	return v
+}